@@ -44,10 +44,6 @@ func (b *Block) mine() {
 	}
 }
 
-func persistBlock(b *Block) {
-	dbStorage.SaveBlock(b.Hash, utils.ToBytes(b))
-}
-
 func createBlock(previousHash string, height, difficulty int) *Block {
 	block := &Block{
 		Hash:         "",
@@ -56,10 +52,9 @@ func createBlock(previousHash string, height, difficulty int) *Block {
 		Difficulty:   difficulty,
 		Nonce:        0,
 	}
-	block.Transactions = Mempool().ConfirmTxs()
+	block.Transactions = Mempool().ConfirmTxs(height)
 	block.mine()
 	fmt.Printf("\nHeight: %d\nHash: %s\nDifficulty: %d\nNonce: %d\n\n", block.Height, block.Hash, block.Difficulty, block.Nonce)
-	persistBlock(block)
 	return block
 }
 
@@ -3,12 +3,10 @@ package blockchain
 import (
 	"reflect"
 	"testing"
-
-	"github.com/josh3021/nomadcoin/utils"
 )
 
 func TestCreateBlock(t *testing.T) {
-	dbStorage = fakeDB{}
+	dbStorage = memoryStorage(nil)
 	Mempool().Txs["test"] = &Tx{}
 	b := createBlock("x", 1, 1)
 	if reflect.TypeOf(b) != reflect.TypeOf(&Block{}) {
@@ -18,23 +16,14 @@ func TestCreateBlock(t *testing.T) {
 
 func TestFindBlock(t *testing.T) {
 	t.Run("Block should not be found.", func(t *testing.T) {
-		dbStorage = fakeDB{
-			fakeFindBlock: func() []byte {
-				return nil
-			},
-		}
+		dbStorage = memoryStorage(nil)
 		_, err := FindBlock("x")
 		if err == nil {
 			t.Error("Block should not be found.")
 		}
 	})
 	t.Run("Block should be found.", func(t *testing.T) {
-		dbStorage = fakeDB{
-			fakeFindBlock: func() []byte {
-				b := &Block{}
-				return utils.ToBytes(b)
-			},
-		}
+		dbStorage = memoryStorage(nil, &Block{Hash: "x"})
 		b, _ := FindBlock("x")
 		if reflect.TypeOf(b) != reflect.TypeOf(&Block{}) {
 			t.Error("Block should be found.")
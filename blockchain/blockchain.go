@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/josh3021/nomadcoin/db"
@@ -16,10 +17,15 @@ type blockchain struct {
 
 type storage interface {
 	FindBlock(hash string) []byte
+	ForEachBlock(fn func(hash string, data []byte))
 	SaveBlock(hash string, data []byte)
 	SaveBlockchain(data []byte)
 	LoadBlockchain() []byte
 	DeleteAllBlocks()
+	FindTxBlock(txID string) []byte
+	ForEachOutput(address string, fn func(outpoint string, data []byte))
+	FindSpender(outpoint string) []byte
+	Write(batch *db.Batch)
 }
 
 const (
@@ -44,35 +50,42 @@ func (b *blockchain) AddBlock() *Block {
 	b.NewestHash = block.Hash
 	b.Height = block.Height
 	b.CurrentDifficulty = block.Difficulty
-	persistBlockchain(b)
+	persistBlocks(b, false, block)
 	return block
 }
 
-func (b *blockchain) Replace(newBlocks []*Block) {
+// Replace replaces the chain with the blocks of a peer, newest first, once the signatures of all their txs verify.
+func (b *blockchain) Replace(newBlocks []*Block) error {
+	if len(newBlocks) == 0 {
+		return ErrNotFound
+	}
+	if err := verifyBlocks(newBlocks, nil); err != nil {
+		return err
+	}
 	b.m.Lock()
 	defer b.m.Unlock()
 	b.Height = len(newBlocks)
 	b.CurrentDifficulty = newBlocks[0].Difficulty
 	b.NewestHash = newBlocks[0].Hash
-	persistBlockchain(b)
-	dbStorage.DeleteAllBlocks()
-	for _, block := range newBlocks {
-		persistBlock(block)
-	}
+	persistBlocks(b, true, newBlocks...)
+	return nil
 }
 
-func (b *blockchain) AddPeerBlock(newBlock *Block) {
-	b.m.Lock()
+// AddPeerBlock adds a block mined by a peer on top of the chain, once the signatures of its txs verify.
+func (b *blockchain) AddPeerBlock(newBlock *Block) error {
+	if err := verifyBlocks([]*Block{newBlock}, func() []*Tx { return Txs(b) }); err != nil {
+		return err
+	}
 	m.m.Lock()
-	defer b.m.Unlock()
+	b.m.Lock()
 	defer m.m.Unlock()
+	defer b.m.Unlock()
 
 	b.Height++
 	b.CurrentDifficulty = newBlock.Difficulty
 	b.NewestHash = newBlock.Hash
 
-	persistBlockchain(b)
-	persistBlock(newBlock)
+	persistBlocks(b, false, newBlock)
 
 	for _, tx := range newBlock.Transactions {
 		_, ok := m.Txs[tx.ID]
@@ -80,10 +93,66 @@ func (b *blockchain) AddPeerBlock(newBlock *Block) {
 			delete(m.Txs, tx.ID)
 		}
 	}
+	return nil
+}
+
+// persistBlocks saves blocks, the index entries of their txs and the checkpoint of b in one batch,
+// after deleting all blocks and indexes if replace is set, so that a crash never leaves the checkpoint
+// pointing at a missing block or the indexes out of step with the chain.
+func persistBlocks(b *blockchain, replace bool, blocks ...*Block) {
+	batch := new(db.Batch)
+	if replace {
+		batch.DeleteAllBlocks()
+		batch.DeleteIndexes()
+	}
+	for _, block := range blocks {
+		batch.SaveBlock(block.Hash, utils.ToBytes(block))
+	}
+	indexBlocks(batch, blocks...)
+	batch.SaveBlockchain(utils.ToBytes(b))
+	dbStorage.Write(batch)
 }
 
-func persistBlockchain(b *blockchain) {
-	dbStorage.SaveBlockchain(utils.ToBytes(b))
+// outpoint returns the key of the output at index of a tx, in spent sets and in the indexes.
+func outpoint(txID string, index int) string {
+	return fmt.Sprintf("%s:%d", txID, index)
+}
+
+// indexBlocks adds the index entries of the txs of blocks to batch: the block of every tx,
+// the spendable outputs of every address and the tx spending every outpoint.
+// The indexes hold the txs of the chain only, so every write moving the tip goes through them.
+func indexBlocks(batch *db.Batch, blocks ...*Block) {
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			batch.IndexTx(tx.ID, block.Hash)
+			for _, txIn := range tx.TxIns {
+				if !txIn.isCoinbase() {
+					batch.IndexSpent(outpoint(txIn.TxID, txIn.Index), tx.ID)
+				}
+			}
+			for index, txOut := range tx.TxOuts {
+				if !txOut.isUnspendable() {
+					uTxOut := &UTxOut{TxID: tx.ID, Index: index, Amount: txOut.Amount}
+					batch.IndexOutput(txOut.Address, outpoint(tx.ID, index), utils.ToBytes(uTxOut))
+				}
+			}
+		}
+	}
+}
+
+// reindex rebuilds the indexes from the blocks of the chain of b.
+func reindex(b *blockchain) {
+	batch := new(db.Batch)
+	batch.DeleteIndexes()
+	indexBlocks(batch, Blocks(b)...)
+	dbStorage.Write(batch)
+}
+
+// indexed reports whether the txs of the newest block of b are indexed.
+// Databases written before the indexes have none, until the node rebuilds them.
+func indexed(b *blockchain) bool {
+	block, err := FindBlock(b.NewestHash)
+	return err != nil || len(block.Transactions) == 0 || dbStorage.FindTxBlock(block.Transactions[0].ID) != nil
 }
 
 func recalculateDifficulty(b *blockchain) int {
@@ -111,66 +180,123 @@ func getDifficulty(b *blockchain) int {
 	}
 }
 
-// Blocks returns all blocks
+// Blocks returns all blocks, newest first, stopping at the first one missing from the database
 func Blocks(b *blockchain) []*Block {
 	b.m.Lock()
 	defer b.m.Unlock()
 	var blocks []*Block
 	hashCursor := b.NewestHash
-	for {
-		block, _ := FindBlock(hashCursor)
-		blocks = append(blocks, block)
-		if block.PreviousHash != "" {
-			hashCursor = block.PreviousHash
-		} else {
+	for hashCursor != "" {
+		block, err := FindBlock(hashCursor)
+		if err != nil {
 			break
 		}
+		blocks = append(blocks, block)
+		hashCursor = block.PreviousHash
 	}
 	return blocks
 }
 
-// UTxOutsByAddress returns Unspent Transaction Outputs By Address
+// UTxOutsByAddress returns Unspent Transaction Outputs By Address, read from the output and spender indexes.
 func UTxOutsByAddress(b *blockchain, address string) []*UTxOut {
 	var uTxOuts []*UTxOut
-	var creatorTxs = make(map[string]bool)
-	for _, block := range Blocks(b) {
+	dbStorage.ForEachOutput(address, func(key string, data []byte) {
+		if dbStorage.FindSpender(key) != nil {
+			return
+		}
+		uTxOut := &UTxOut{}
+		utils.FromBytes(uTxOut, data)
+		if !isOnMempool(uTxOut) {
+			uTxOuts = append(uTxOuts, uTxOut)
+		}
+	})
+	return uTxOuts
+}
+
+// isSpent reports whether an output is spent on chain or in the mempool.
+func isSpent(b *blockchain, txID string, index int) bool {
+	if isOnMempool(&UTxOut{TxID: txID, Index: index}) {
+		return true
+	}
+	return dbStorage.FindSpender(outpoint(txID, index)) != nil
+}
+
+// GetBalanceByAddress returns balance of address
+func GetBalanceByAddress(b *blockchain, address string) int {
+	var balance int
+	ownedTxOuts := UTxOutsByAddress(b, address)
+	for _, ownedTxOut := range ownedTxOuts {
+		balance += ownedTxOut.Amount
+	}
+	return balance
+}
+
+// AddressTx is how a transaction on chain changed the balance of a set of addresses.
+type AddressTx struct {
+	TxID      string `json:"txId"`
+	BlockHash string `json:"blockHash"`
+	Height    int    `json:"height"`
+	Timestamp int    `json:"timestamp"`
+	Received  int    `json:"received"`
+	Sent      int    `json:"sent"`
+}
+
+// HistoryByAddresses returns the transactions that paid to or spent from any of addresses, newest first.
+func HistoryByAddresses(b *blockchain, addresses []string) []*AddressTx {
+	watched := make(map[string]bool)
+	for _, address := range addresses {
+		watched[address] = true
+	}
+	blocks := Blocks(b)
+	txs := make(map[string]*Tx)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			txs[tx.ID] = tx
+		}
+	}
+	history := []*AddressTx{}
+	for _, block := range blocks {
 		for _, tx := range block.Transactions {
+			entry := &AddressTx{TxID: tx.ID, BlockHash: block.Hash, Height: block.Height, Timestamp: tx.Timestamp}
+			involved := false
 			for _, txIn := range tx.TxIns {
-				if txIn.Signature == "COINBASE" {
+				if txIn.isCoinbase() {
 					break
 				}
-				if FindTx(b, txIn.TxID).TxOuts[txIn.Index].Address == address {
-					creatorTxs[txIn.TxID] = true
+				spent, ok := txs[txIn.TxID]
+				if !ok || txIn.Index < 0 || txIn.Index >= len(spent.TxOuts) {
+					continue
+				}
+				if watched[spent.TxOuts[txIn.Index].Address] {
+					entry.Sent += spent.TxOuts[txIn.Index].Amount
+					involved = true
 				}
 			}
-
-			for index, txOut := range tx.TxOuts {
-				if txOut.Address == address {
-					if _, ok := creatorTxs[tx.ID]; !ok {
-						uTxOut := &UTxOut{
-							TxID:   tx.ID,
-							Index:  index,
-							Amount: txOut.Amount,
-						}
-						if !isOnMempool(uTxOut) {
-							uTxOuts = append(uTxOuts, uTxOut)
-						}
-					}
+			for _, txOut := range tx.TxOuts {
+				if watched[txOut.Address] {
+					entry.Received += txOut.Amount
+					involved = true
 				}
 			}
+			if involved {
+				history = append(history, entry)
+			}
 		}
 	}
-	return uTxOuts
+	return history
 }
 
-// GetBalanceByAddress returns balance of address
-func GetBalanceByAddress(b *blockchain, address string) int {
-	var balance int
-	ownedTxOuts := UTxOutsByAddress(b, address)
-	for _, ownedTxOut := range ownedTxOuts {
-		balance += ownedTxOut.Amount
+// UsedAddresses returns whether an address received an output on chain, to scan wallets with.
+func UsedAddresses(b *blockchain) func(address string) bool {
+	used := make(map[string]bool)
+	for _, tx := range Txs(b) {
+		for _, txOut := range tx.TxOuts {
+			used[txOut.Address] = true
+		}
+	}
+	return func(address string) bool {
+		return used[address]
 	}
-	return balance
 }
 
 // Txs return all transactions
@@ -182,9 +308,17 @@ func Txs(b *blockchain) []*Tx {
 	return txs
 }
 
-// FindTx returns tx that want
+// FindTx returns tx that want, from the block the tx index points to
 func FindTx(b *blockchain, targetTxID string) *Tx {
-	for _, tx := range Txs(b) {
+	hash := dbStorage.FindTxBlock(targetTxID)
+	if hash == nil {
+		return nil
+	}
+	block, err := FindBlock(string(hash))
+	if err != nil {
+		return nil
+	}
+	for _, tx := range block.Transactions {
 		if tx.ID == targetTxID {
 			return tx
 		}
@@ -209,6 +343,10 @@ func Blockchain() *blockchain {
 			b.AddBlock()
 		} else {
 			b.restore(checkpoint)
+			if !indexed(b) {
+				fmt.Println("Indexing the txs of the blockchain...")
+				reindex(b)
+			}
 		}
 	})
 	return b
@@ -1,35 +1,45 @@
 package blockchain
 
 import (
+	"os"
 	"reflect"
 	"sync"
 	"testing"
 
+	"github.com/josh3021/nomadcoin/db"
 	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
 )
 
-type fakeDB struct {
-	fakeFindBlock      func() []byte
-	fakeLoadBlockChain func() []byte
+// TestMain keeps the wallet files the tests create, like the node wallet coinbase txs pay, out of the package directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "blockchain")
+	utils.HandleErr(err)
+	wallet.SetDir(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
 }
 
-func (f fakeDB) FindBlock(hash string) []byte {
-	return f.fakeFindBlock()
-}
-func (f fakeDB) LoadBlockchain() []byte {
-	return f.fakeLoadBlockChain()
+// memoryStorage returns a database in memory holding blocks, the index entries of their txs
+// and, unless bc is nil, the checkpoint of bc.
+func memoryStorage(bc *blockchain, blocks ...*Block) *db.Memory {
+	storage := db.NewMemory()
+	batch := new(db.Batch)
+	for _, block := range blocks {
+		batch.SaveBlock(block.Hash, utils.ToBytes(block))
+	}
+	indexBlocks(batch, blocks...)
+	if bc != nil {
+		batch.SaveBlockchain(utils.ToBytes(bc))
+	}
+	storage.Write(batch)
+	return storage
 }
-func (fakeDB) SaveBlock(hash string, data []byte) {}
-func (fakeDB) SaveBlockchain(data []byte)         {}
-func (fakeDB) DeleteAllBlocks()                   {}
 
 func TestBlockChain(t *testing.T) {
 	t.Run("Should create Blockchain", func(t *testing.T) {
-		dbStorage = fakeDB{
-			fakeLoadBlockChain: func() []byte {
-				return nil
-			},
-		}
+		dbStorage = memoryStorage(nil)
 		bc := Blockchain()
 		if bc.Height != 1 {
 			t.Error("Blockchain() should create blockchain.")
@@ -37,12 +47,7 @@ func TestBlockChain(t *testing.T) {
 	})
 	t.Run("Should restore Blockchain", func(t *testing.T) {
 		once = *new(sync.Once)
-		dbStorage = fakeDB{
-			fakeLoadBlockChain: func() []byte {
-				bc := &blockchain{Height: 2, CurrentDifficulty: 1, NewestHash: "XXXX"}
-				return utils.ToBytes(bc)
-			},
-		}
+		dbStorage = memoryStorage(&blockchain{Height: 2, CurrentDifficulty: 1, NewestHash: "XXXX"})
 		bc := Blockchain()
 		if bc.Height != 2 {
 			t.Errorf("Blockchain() should restore a blockchain with a height of %d, got %d", 2, bc.Height)
@@ -51,54 +56,49 @@ func TestBlockChain(t *testing.T) {
 }
 
 func TestBlocks(t *testing.T) {
-	blocks := []*Block{
-		{PreviousHash: "x"},
-		{PreviousHash: ""},
-	}
-	fakeBlock := 0
-	dbStorage = fakeDB{
-		fakeFindBlock: func() []byte {
-			defer func() {
-				fakeBlock++
-			}()
-			return utils.ToBytes(blocks[fakeBlock])
-		},
-	}
-	bc := &blockchain{}
-	blocksResult := Blocks(bc)
-	if reflect.TypeOf(blocksResult) != reflect.TypeOf([]*Block{}) {
-		t.Error("Blocks() should return a slice of blocks")
-	}
+	t.Run("Should return the blocks of the chain.", func(t *testing.T) {
+		dbStorage = memoryStorage(nil,
+			&Block{Hash: "y", PreviousHash: "x"},
+			&Block{Hash: "x", PreviousHash: ""},
+		)
+		bc := &blockchain{NewestHash: "y"}
+		blocksResult := Blocks(bc)
+		if reflect.TypeOf(blocksResult) != reflect.TypeOf([]*Block{}) {
+			t.Error("Blocks() should return a slice of blocks")
+		}
+	})
+	t.Run("Should stop at a missing block.", func(t *testing.T) {
+		dbStorage = memoryStorage(nil,
+			&Block{Hash: "z", PreviousHash: "y"},
+			&Block{Hash: "x", PreviousHash: ""},
+		)
+		blocks := Blocks(&blockchain{NewestHash: "z"})
+		if len(blocks) != 1 || blocks[0].Hash != "z" {
+			t.Errorf("Expected only block z, got %v", blocks)
+		}
+	})
 }
 
 func TestFindTx(t *testing.T) {
 	t.Run("Tx not found.", func(t *testing.T) {
-		dbStorage = fakeDB{
-			fakeFindBlock: func() []byte {
-				b := &Block{
-					Height:       2,
-					Transactions: []*Tx{},
-				}
-				return utils.ToBytes(b)
-			},
-		}
-		tx := FindTx(&blockchain{}, "test")
+		dbStorage = memoryStorage(nil, &Block{
+			Hash:         "test",
+			Height:       2,
+			Transactions: []*Tx{},
+		})
+		tx := FindTx(&blockchain{NewestHash: "test"}, "test")
 		if tx != nil {
 			t.Error("Tx should not be found.")
 		}
 	})
 	t.Run("Tx found.", func(t *testing.T) {
-		dbStorage = fakeDB{
-			fakeFindBlock: func() []byte {
-				b := &Block{
-					Height: 2,
-					Transactions: []*Tx{
-						{ID: "test"},
-					},
-				}
-				return utils.ToBytes(b)
+		dbStorage = memoryStorage(nil, &Block{
+			Hash:   "test",
+			Height: 2,
+			Transactions: []*Tx{
+				{ID: "test"},
 			},
-		}
+		})
 		tx := FindTx(&blockchain{NewestHash: "test"}, "test")
 		if tx == nil {
 			t.Error("Tx should be found.")
@@ -106,23 +106,75 @@ func TestFindTx(t *testing.T) {
 	})
 }
 
-func TestGetDifficulty(t *testing.T) {
-	blocks := []*Block{
-		{PreviousHash: "x"},
-		{PreviousHash: "x"},
-		{PreviousHash: "x"},
-		{PreviousHash: "x"},
-		{PreviousHash: ""},
-	}
-	fakeBlock := 0
-	dbStorage = fakeDB{
-		fakeFindBlock: func() []byte {
-			defer func() {
-				fakeBlock++
-			}()
-			return utils.ToBytes(blocks[fakeBlock])
-		},
+func TestUTxOutsByAddress(t *testing.T) {
+	first, second, _ := makeLedgerChain()
+	dbStorage = memoryStorage(nil, first, second)
+	bc := &blockchain{NewestHash: "b", Height: 2}
+	tests := []struct {
+		address  string
+		expected []UTxOut
+	}{
+		{"me", nil},
+		{"them", []UTxOut{{TxID: "spend", Index: 0, Amount: 30}}},
+		{"change", []UTxOut{{TxID: "spend", Index: 1, Amount: 20}}},
+	}
+	for _, test := range tests {
+		var got []UTxOut
+		for _, uTxOut := range UTxOutsByAddress(bc, test.address) {
+			got = append(got, *uTxOut)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected %v for %s, got %v", test.expected, test.address, got)
+		}
 	}
+	if !isSpent(bc, "coinbase", 0) || isSpent(bc, "spend", 0) {
+		t.Error("Expected only the output of the coinbase to be spent")
+	}
+}
+
+func TestReindex(t *testing.T) {
+	first, second, spend := makeLedgerChain()
+	storage := db.NewMemory()
+	storage.SaveBlock(first.Hash, utils.ToBytes(first))
+	storage.SaveBlock(second.Hash, utils.ToBytes(second))
+	storage.SaveBlockchain(utils.ToBytes(&blockchain{NewestHash: "b", Height: 2}))
+	dbStorage = storage
+	defer func(saved *blockchain) { b = saved }(b)
+	once = *new(sync.Once)
+	t.Run("Databases without indexes should be indexed once the chain restores.", func(t *testing.T) {
+		if tx := FindTx(b, spend.ID); tx != nil {
+			t.Fatalf("Expected no index before the chain restores, got %v", tx)
+		}
+		bc := Blockchain()
+		if tx := FindTx(bc, spend.ID); tx == nil || tx.ID != spend.ID {
+			t.Errorf("Expected %s, got %v", spend.ID, tx)
+		}
+		if balance := GetBalanceByAddress(bc, "change"); balance != 20 {
+			t.Errorf("Expected 20, got %d", balance)
+		}
+	})
+	t.Run("Replacing the chain should replace the indexes.", func(t *testing.T) {
+		bc := Blockchain()
+		other := &Tx{ID: "other", TxIns: []*TxIn{{Signature: "COINBASE", Index: -1}}, TxOuts: []*TxOut{{Address: "me", Amount: 50}}}
+		other.ID = other.hash()
+		persistBlocks(bc, true, &Block{Hash: "x", Height: 1, Transactions: []*Tx{other}})
+		if tx := FindTx(bc, spend.ID); tx != nil {
+			t.Errorf("Expected the txs of the old chain to be gone, got %v", tx)
+		}
+		if uTxOuts := UTxOutsByAddress(bc, "me"); len(uTxOuts) != 1 || uTxOuts[0].TxID != other.ID {
+			t.Errorf("Expected the output of %s, got %v", other.ID, uTxOuts)
+		}
+	})
+}
+
+func TestGetDifficulty(t *testing.T) {
+	dbStorage = memoryStorage(nil,
+		&Block{Hash: "5", PreviousHash: "4"},
+		&Block{Hash: "4", PreviousHash: "3"},
+		&Block{Hash: "3", PreviousHash: "2"},
+		&Block{Hash: "2", PreviousHash: "1"},
+		&Block{Hash: "1", PreviousHash: ""},
+	)
 	type test struct {
 		height int
 		want   int
@@ -133,7 +185,7 @@ func TestGetDifficulty(t *testing.T) {
 		{height: 5, want: 5},
 	}
 	for _, tc := range tests {
-		bc := &blockchain{Height: tc.height, CurrentDifficulty: defaultDifficulty}
+		bc := &blockchain{Height: tc.height, CurrentDifficulty: defaultDifficulty, NewestHash: "5"}
 		got := getDifficulty(bc)
 		if got != tc.want {
 			t.Errorf("getDifficulty() should return %d got %d", tc.want, got)
@@ -147,13 +199,13 @@ func TestAddPerrBlock(t *testing.T) {
 		CurrentDifficulty: 1,
 		NewestHash:        "test",
 	}
-	Mempool().Txs["test"] = &Tx{}
+	tx := &Tx{}
+	tx.getID()
+	Mempool().Txs[tx.ID] = tx
 	newBlock := &Block{
-		Difficulty: 2,
-		Hash:       "test",
-		Transactions: []*Tx{
-			{ID: "test"},
-		},
+		Difficulty:   2,
+		Hash:         "test",
+		Transactions: []*Tx{tx},
 	}
 	bc.AddPeerBlock(newBlock)
 	if bc.CurrentDifficulty != 2 || bc.Height != 2 || bc.NewestHash != "test" {
@@ -176,3 +228,25 @@ func TestReplace(t *testing.T) {
 		t.Error("Replace should replace blockchain")
 	}
 }
+
+func TestHistoryByAddresses(t *testing.T) {
+	blocks := []*Block{
+		{Hash: "b", PreviousHash: "a", Height: 2, Transactions: []*Tx{
+			{ID: "spend", TxIns: []*TxIn{{TxID: "coinbase", Index: 0}}, TxOuts: []*TxOut{{Address: "them", Amount: 30}, {Address: "change", Amount: 20}}},
+			{ID: "other", TxIns: []*TxIn{{Signature: "COINBASE"}}, TxOuts: []*TxOut{{Address: "them", Amount: 50}}},
+			{ID: "stray", TxIns: []*TxIn{{TxID: "coinbase", Index: 1}, {TxID: "coinbase", Index: -2}}, TxOuts: []*TxOut{{Address: "them", Amount: 5}}},
+		}},
+		{Hash: "a", Height: 1, Transactions: []*Tx{
+			{ID: "coinbase", TxIns: []*TxIn{{Signature: "COINBASE"}}, TxOuts: []*TxOut{{Address: "me", Amount: 50}}},
+		}},
+	}
+	dbStorage = memoryStorage(nil, blocks...)
+	history := HistoryByAddresses(&blockchain{NewestHash: "b"}, []string{"me", "change"})
+	expected := []*AddressTx{
+		{TxID: "spend", BlockHash: "b", Height: 2, Sent: 50, Received: 20},
+		{TxID: "coinbase", BlockHash: "a", Height: 1, Received: 50},
+	}
+	if !reflect.DeepEqual(history, expected) {
+		t.Errorf("Expected %v, got %v", expected, history)
+	}
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// CoinSelection names a strategy to pick the outputs a tx spends.
+type CoinSelection string
+
+const (
+	// BranchAndBound looks for outputs that pay the amount without change, and falls back to LargestFirst.
+	BranchAndBound CoinSelection = "branch-and-bound"
+	// LargestFirst spends the largest outputs first, making txs with few inputs.
+	LargestFirst CoinSelection = "largest-first"
+	// SmallestFirst spends the smallest outputs first, consolidating the wallet.
+	SmallestFirst CoinSelection = "smallest-first"
+	// RandomSelection spends outputs in random order, so the inputs tell less about the wallet.
+	RandomSelection CoinSelection = "random"
+)
+
+const (
+	// DustThreshold is the smallest change worth an output, smaller change is left to the tx as a fee.
+	DustThreshold int = 5
+
+	bnbMaxTries int = 100000
+)
+
+var (
+	errorUnknownCoinSelection = errors.New("unknown coin selection")
+	errorCoinNotSpendable     = errors.New("outpoint is not an unspent output of the wallet")
+)
+
+// Outpoint names an output of a tx.
+type Outpoint struct {
+	TxID  string `json:"txId"`
+	Index int    `json:"index"`
+}
+
+// TxOptions choose the outputs a tx spends. The zero value uses BranchAndBound over every output of the wallet.
+type TxOptions struct {
+	Selection CoinSelection `json:"selection,omitempty"`
+	// Include are spent whatever the selection, Exclude are never spent.
+	Include []Outpoint `json:"include,omitempty"`
+	Exclude []Outpoint `json:"exclude,omitempty"`
+}
+
+// coin is an unspent output with the address that can spend it.
+type coin struct {
+	*UTxOut
+	address string
+}
+
+func (c *coin) outpoint() Outpoint {
+	return Outpoint{TxID: c.TxID, Index: c.Index}
+}
+
+// coinSelector returns coins worth at least target, at least one of them, or false if there are not enough.
+type coinSelector func(coins []*coin, target int) ([]*coin, bool)
+
+var coinSelectors = map[CoinSelection]coinSelector{
+	BranchAndBound:  branchAndBound,
+	LargestFirst:    largestFirst,
+	SmallestFirst:   smallestFirst,
+	RandomSelection: randomSelection,
+}
+
+// walletCoins returns the unspent outputs of the addresses in "from".
+func walletCoins(from []string) []*coin {
+	var coins []*coin
+	for _, address := range from {
+		for _, uTxOut := range UTxOutsByAddress(Blockchain(), address) {
+			coins = append(coins, &coin{UTxOut: uTxOut, address: address})
+		}
+	}
+	return coins
+}
+
+// selectCoins returns the coins a tx paying amount spends, following options.
+func selectCoins(coins []*coin, amount int, options *TxOptions) ([]*coin, error) {
+	if options == nil {
+		options = &TxOptions{}
+	}
+	selection := options.Selection
+	if selection == "" {
+		selection = BranchAndBound
+	}
+	selector, ok := coinSelectors[selection]
+	if !ok {
+		return nil, errorUnknownCoinSelection
+	}
+	excluded := make(map[Outpoint]bool)
+	for _, outpoint := range options.Exclude {
+		excluded[outpoint] = true
+	}
+	included := make(map[Outpoint]bool)
+	for _, outpoint := range options.Include {
+		included[outpoint] = true
+	}
+	var selected, candidates []*coin
+	total := 0
+	for _, c := range coins {
+		switch {
+		case included[c.outpoint()]:
+			selected = append(selected, c)
+			total += c.Amount
+			delete(included, c.outpoint())
+		case !excluded[c.outpoint()]:
+			candidates = append(candidates, c)
+		}
+	}
+	if len(included) > 0 {
+		return nil, errorCoinNotSpendable
+	}
+	// spend at least one output so data-only txs are signed by the wallet
+	if total >= amount && len(selected) > 0 {
+		return selected, nil
+	}
+	more, ok := selector(candidates, amount-total)
+	if !ok {
+		return nil, errorNotEnoghMoney
+	}
+	return append(selected, more...), nil
+}
+
+// accumulate takes coins in order until they are worth target.
+func accumulate(coins []*coin, target int) ([]*coin, bool) {
+	var selected []*coin
+	total := 0
+	for _, c := range coins {
+		if total >= target && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, c)
+		total += c.Amount
+	}
+	return selected, len(selected) > 0 && total >= target
+}
+
+func sortedCoins(coins []*coin, less func(a, b *coin) bool) []*coin {
+	sorted := append([]*coin{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+func largestFirst(coins []*coin, target int) ([]*coin, bool) {
+	return accumulate(sortedCoins(coins, func(a, b *coin) bool { return a.Amount > b.Amount }), target)
+}
+
+func smallestFirst(coins []*coin, target int) ([]*coin, bool) {
+	return accumulate(sortedCoins(coins, func(a, b *coin) bool { return a.Amount < b.Amount }), target)
+}
+
+func randomSelection(coins []*coin, target int) ([]*coin, bool) {
+	shuffled := append([]*coin{}, coins...)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, false
+		}
+		shuffled[i], shuffled[j.Int64()] = shuffled[j.Int64()], shuffled[i]
+	}
+	return accumulate(shuffled, target)
+}
+
+// branchAndBound searches, largest outputs first, for the coins worth least over target, below dust,
+// so the tx needs no change output. It falls back to LargestFirst if there are none.
+func branchAndBound(coins []*coin, target int) ([]*coin, bool) {
+	sorted := sortedCoins(coins, func(a, b *coin) bool { return a.Amount > b.Amount })
+	// remaining[i] is what the coins from i on are worth
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Amount
+	}
+	var selected, best []*coin
+	bestTotal := target + DustThreshold
+	tries := 0
+	var search func(i, total int)
+	search = func(i, total int) {
+		tries++
+		if tries > bnbMaxTries || total >= bestTotal || bestTotal == target {
+			return
+		}
+		if total >= target && len(selected) > 0 {
+			best, bestTotal = append([]*coin{}, selected...), total
+			return
+		}
+		if i == len(sorted) || total+remaining[i] < target {
+			return
+		}
+		selected = append(selected, sorted[i])
+		search(i+1, total+sorted[i].Amount)
+		selected = selected[:len(selected)-1]
+		search(i+1, total)
+	}
+	search(0, 0)
+	if best != nil {
+		return best, true
+	}
+	return largestFirst(coins, target)
+}
@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func makeCoins(amounts ...int) []*coin {
+	var coins []*coin
+	for index, amount := range amounts {
+		coins = append(coins, &coin{UTxOut: &UTxOut{TxID: "tx", Index: index, Amount: amount}, address: "me"})
+	}
+	return coins
+}
+
+func amountsOf(coins []*coin) []int {
+	var amounts []int
+	for _, c := range coins {
+		amounts = append(amounts, c.Amount)
+	}
+	return amounts
+}
+
+func TestSelectCoins(t *testing.T) {
+	coins := makeCoins(50, 3, 20, 10, 7)
+	tests := []struct {
+		selection CoinSelection
+		amount    int
+		expected  string
+	}{
+		{LargestFirst, 55, "[50 20]"},
+		{SmallestFirst, 15, "[3 7 10]"},
+		// 20+10 pays 30 exactly where largest-first would spend 50
+		{BranchAndBound, 30, "[20 10]"},
+		// change below DustThreshold counts as a match
+		{BranchAndBound, 26, "[20 7]"},
+		// no match falls back to largest-first
+		{BranchAndBound, 89, "[50 20 10 7 3]"},
+		{"", 30, "[20 10]"},
+		// data-only txs spend one output
+		{SmallestFirst, 0, "[3]"},
+	}
+	for _, test := range tests {
+		selected, err := selectCoins(coins, test.amount, &TxOptions{Selection: test.selection})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amounts := fmt.Sprint(amountsOf(selected)); amounts != test.expected {
+			t.Errorf("Expected %s for %d with %s, got %s", test.expected, test.amount, test.selection, amounts)
+		}
+	}
+	t.Run("Random selection should cover the amount.", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			selected, err := selectCoins(coins, 60, &TxOptions{Selection: RandomSelection})
+			if err != nil {
+				t.Fatal(err)
+			}
+			total := 0
+			for _, amount := range amountsOf(selected) {
+				total += amount
+			}
+			if total < 60 {
+				t.Errorf("Expected at least 60, got %v", amountsOf(selected))
+			}
+		}
+	})
+	t.Run("Selection should fail without enough money.", func(t *testing.T) {
+		if _, err := selectCoins(coins, 91, nil); err != errorNotEnoghMoney {
+			t.Errorf("Expected %v, got %v", errorNotEnoghMoney, err)
+		}
+		if _, err := selectCoins(coins, 10, &TxOptions{Selection: "first-come"}); err != errorUnknownCoinSelection {
+			t.Errorf("Expected %v, got %v", errorUnknownCoinSelection, err)
+		}
+	})
+}
+
+func TestCoinControl(t *testing.T) {
+	coins := makeCoins(50, 3, 20, 10, 7)
+	t.Run("Included outputs should always be spent.", func(t *testing.T) {
+		options := &TxOptions{Selection: LargestFirst, Include: []Outpoint{{"tx", 1}}}
+		selected, _ := selectCoins(coins, 2, options)
+		if amounts := fmt.Sprint(amountsOf(selected)); amounts != "[3]" {
+			t.Errorf("Expected [3], got %s", amounts)
+		}
+		selected, _ = selectCoins(coins, 30, options)
+		if amounts := fmt.Sprint(amountsOf(selected)); amounts != "[3 50]" {
+			t.Errorf("Expected [3 50], got %s", amounts)
+		}
+	})
+	t.Run("Excluded outputs should never be spent.", func(t *testing.T) {
+		options := &TxOptions{Selection: LargestFirst, Exclude: []Outpoint{{"tx", 0}}}
+		selected, _ := selectCoins(coins, 30, options)
+		if amounts := fmt.Sprint(amountsOf(selected)); amounts != "[20 10]" {
+			t.Errorf("Expected [20 10], got %s", amounts)
+		}
+		if _, err := selectCoins(coins, 50, options); err != errorNotEnoghMoney {
+			t.Errorf("Expected %v, got %v", errorNotEnoghMoney, err)
+		}
+	})
+	t.Run("Unknown included outputs should be rejected.", func(t *testing.T) {
+		options := &TxOptions{Include: []Outpoint{{"other", 0}}}
+		if _, err := selectCoins(coins, 10, options); err != errorCoinNotSpendable {
+			t.Errorf("Expected %v, got %v", errorCoinNotSpendable, err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/db"
+)
+
+var errCrash = errors.New("crash")
+
+// crashDB panics at its crashAt-th write before storing anything, like a node killed midway.
+type crashDB struct {
+	*db.Memory
+	writes  int
+	crashAt int
+}
+
+func (c *crashDB) crash() {
+	c.writes++
+	if c.writes == c.crashAt {
+		panic(errCrash)
+	}
+}
+
+func (c *crashDB) SaveBlock(hash string, data []byte) { c.crash(); c.Memory.SaveBlock(hash, data) }
+func (c *crashDB) SaveBlockchain(data []byte)         { c.crash(); c.Memory.SaveBlockchain(data) }
+func (c *crashDB) DeleteAllBlocks()                   { c.crash(); c.Memory.DeleteAllBlocks() }
+func (c *crashDB) Write(batch *db.Batch)              { c.crash(); c.Memory.Write(batch) }
+
+// crashingRun mines two blocks, replaces the chain with the blocks of a peer and adds a peer block,
+// and reports whether storage crashed on the way.
+func crashingRun(storage *crashDB) (crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errCrash {
+				panic(r)
+			}
+			crashed = true
+		}
+	}()
+	once = *new(sync.Once)
+	dbStorage = storage
+	bc := Blockchain()
+	bc.AddBlock()
+	peerBlocks := []*Block{
+		{Hash: "0000p3", PreviousHash: "0000p2", Height: 3, Difficulty: defaultDifficulty},
+		{Hash: "0000p2", PreviousHash: "0000p1", Height: 2, Difficulty: defaultDifficulty},
+		{Hash: "0000p1", Height: 1, Difficulty: defaultDifficulty},
+	}
+	if err := bc.Replace(peerBlocks); err != nil {
+		panic(err)
+	}
+	if err := bc.AddPeerBlock(&Block{Hash: "0000p4", PreviousHash: "0000p3", Height: 4, Difficulty: defaultDifficulty}); err != nil {
+		panic(err)
+	}
+	return false
+}
+
+// unindexedTx returns the first tx of the stored chain that is not indexed to its block, or "".
+func unindexedTx(storage *crashDB) string {
+	checkpoint := storage.LoadBlockchain()
+	if checkpoint == nil {
+		return ""
+	}
+	saved := &blockchain{}
+	saved.restore(checkpoint)
+	for _, block := range Blocks(saved) {
+		for _, tx := range block.Transactions {
+			if string(storage.FindTxBlock(tx.ID)) != block.Hash {
+				return tx.ID
+			}
+		}
+	}
+	return ""
+}
+
+func TestCrashConsistency(t *testing.T) {
+	for crashAt := 1; ; crashAt++ {
+		storage := &crashDB{Memory: db.NewMemory(), crashAt: crashAt}
+		crashed := crashingRun(storage)
+		if report := CheckIntegrity(); !report.OK() {
+			t.Errorf("Expected the chain to be whole after crashing at write %d, got %s", crashAt, report.Problems[0].Reason)
+		}
+		if hash := unindexedTx(storage); hash != "" {
+			t.Errorf("Expected the txs of the chain to be indexed after crashing at write %d, %s is not", crashAt, hash)
+		}
+		if !crashed {
+			if crashAt == 1 {
+				t.Fatal("Expected the run to write")
+			}
+			return
+		}
+	}
+}
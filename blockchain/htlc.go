@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/josh3021/nomadcoin/script"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// HTLC contains information of an unspent hash time-locked output
+type HTLC struct {
+	TxID      string `json:"txId"`
+	Index     int    `json:"index"`
+	Amount    int    `json:"amount"`
+	Hash      string `json:"hash"`
+	Recipient string `json:"recipient"`
+	Refund    string `json:"refund"`
+	LockTime  int    `json:"lockTime"`
+}
+
+var errorHTLCNotFound = errors.New("htlc not found")
+var errorNotHTLCSigner = errors.New("wallet can not spend this htlc path")
+
+// AddHTLC locks amount of the node wallet to recipient until lockTime, claimable with the preimage of hash.
+func (m *mempool) AddHTLC(recipient, hash string, lockTime, amount int) (*Tx, error) {
+	if amount <= 0 {
+		return nil, errorInvalidAmount
+	}
+	w := wallet.Wallet()
+	from, err := w.PublicKey(w.Address)
+	if err != nil {
+		return nil, err
+	}
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, err
+	}
+	recipientKey, err := hex.DecodeString(recipient)
+	if err != nil {
+		return nil, err
+	}
+	refundKey, err := hex.DecodeString(from)
+	if err != nil {
+		return nil, err
+	}
+	lockingScript, err := script.HTLC(hashBytes, recipientKey, int64(lockTime), refundKey)
+	if err != nil {
+		return nil, err
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	tx, err := makeTx(w, nil, &TxOut{Amount: amount, Script: lockingScript.String()})
+	if err != nil {
+		return nil, err
+	}
+	m.add(tx)
+	return tx, nil
+}
+
+// ClaimHTLC spends an HTLC locked to the node wallet by revealing preimage.
+func (m *mempool) ClaimHTLC(txID string, index int, preimage string) (*Tx, error) {
+	preimageBytes, err := hex.DecodeString(preimage)
+	if err != nil {
+		return nil, err
+	}
+	return m.spendHTLC(txID, index, func(htlc *HTLC) string { return htlc.Recipient }, func(signature []byte) (script.Script, error) {
+		return script.HTLCClaim(signature, preimageBytes)
+	})
+}
+
+// RefundHTLC spends an expired HTLC back to the node wallet.
+func (m *mempool) RefundHTLC(txID string, index int) (*Tx, error) {
+	return m.spendHTLC(txID, index, func(htlc *HTLC) string { return htlc.Refund }, func(signature []byte) (script.Script, error) {
+		return script.HTLCRefund(signature)
+	})
+}
+
+func (m *mempool) spendHTLC(txID string, index int, signer func(*HTLC) string, unlock func([]byte) (script.Script, error)) (*Tx, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	htlc, err := findHTLC(Blockchain(), txID, index)
+	if err != nil {
+		return nil, err
+	}
+	w := wallet.Wallet()
+	address := signer(htlc)
+	if !w.HasAddress(address) {
+		return nil, errorNotHTLCSigner
+	}
+	txIn := &TxIn{TxID: txID, Index: index}
+	tx := &Tx{
+		Timestamp: int(time.Now().Unix()),
+		TxIns:     []*TxIn{txIn},
+		TxOuts:    []*TxOut{{Address: w.Address, Amount: htlc.Amount}},
+	}
+	tx.getID()
+	signed, err := wallet.SignFor(tx.ID, address, w)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(signed)
+	if err != nil {
+		return nil, err
+	}
+	unlockingScript, err := unlock(signature)
+	if err != nil {
+		return nil, err
+	}
+	txIn.Script = unlockingScript.String()
+	if !validate(tx) {
+		return nil, errorTxNotValid
+	}
+	m.add(tx)
+	return tx, nil
+}
+
+// HTLCs returns all unspent hash time-locked outputs
+func HTLCs(b *blockchain) []*HTLC {
+	var htlcs []*HTLC
+	for _, tx := range Txs(b) {
+		for index, txOut := range tx.TxOuts {
+			if htlc := parseHTLC(tx.ID, index, txOut); htlc != nil && !isSpent(b, tx.ID, index) {
+				htlcs = append(htlcs, htlc)
+			}
+		}
+	}
+	return htlcs
+}
+
+func findHTLC(b *blockchain, txID string, index int) (*HTLC, error) {
+	tx := FindTx(b, txID)
+	if tx == nil || index < 0 || index >= len(tx.TxOuts) || isSpent(b, txID, index) {
+		return nil, errorHTLCNotFound
+	}
+	htlc := parseHTLC(txID, index, tx.TxOuts[index])
+	if htlc == nil {
+		return nil, errorHTLCNotFound
+	}
+	return htlc, nil
+}
+
+func parseHTLC(txID string, index int, txOut *TxOut) *HTLC {
+	if txOut.Script == "" {
+		return nil
+	}
+	lockingScript, err := script.FromHex(txOut.Script)
+	if err != nil {
+		return nil
+	}
+	terms, ok := script.ParseHTLC(lockingScript)
+	if !ok {
+		return nil
+	}
+	return &HTLC{
+		TxID:      txID,
+		Index:     index,
+		Amount:    txOut.Amount,
+		Hash:      hex.EncodeToString(terms.Hash),
+		Recipient: hex.EncodeToString(terms.Recipient),
+		Refund:    hex.EncodeToString(terms.Refund),
+		LockTime:  int(terms.LockTime),
+	}
+}
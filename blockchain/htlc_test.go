@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/script"
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// encodeTestBigInts returns a and b, each left-padded to BigIntSize bytes, as keys and signatures encode them.
+func encodeTestBigInts(a, b *big.Int) []byte {
+	return append(a.FillBytes(make([]byte, utils.BigIntSize)), b.FillBytes(make([]byte, utils.BigIntSize))...)
+}
+
+func makeTestKey(t testing.TB) (*ecdsa.PrivateKey, []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, encodeTestBigInts(privateKey.X, privateKey.Y)
+}
+
+func signTestTx(t testing.TB, tx *Tx, privateKey *ecdsa.PrivateKey) []byte {
+	payload, _ := hex.DecodeString(tx.ID)
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeTestBigInts(r, s)
+}
+
+func TestVerifyHTLC(t *testing.T) {
+	once = *new(sync.Once)
+	dbStorage = memoryStorage(&blockchain{Height: 9, NewestHash: "x"})
+	recipientKey, recipient := makeTestKey(t)
+	refundKey, refund := makeTestKey(t)
+	preimage := []byte("swap secret")
+	makeHTLC := func(lockTime int64) *TxOut {
+		s, err := script.HTLC(script.Hash(preimage), recipient, lockTime, refund)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &TxOut{Amount: 10, Script: s.String()}
+	}
+	spendingTx := &Tx{ID: "765cd5fbc8bdb14616f299bb4e7952065cd5d153d3511e680d5ddd7cc74e1aaf"}
+	claim := func(key *ecdsa.PrivateKey, preimage []byte) *TxIn {
+		s, _ := script.HTLCClaim(signTestTx(t, spendingTx, key), preimage)
+		return &TxIn{Script: s.String()}
+	}
+	refundTxIn := func(key *ecdsa.PrivateKey) *TxIn {
+		s, _ := script.HTLCRefund(signTestTx(t, spendingTx, key))
+		return &TxIn{Script: s.String()}
+	}
+	type test struct {
+		name   string
+		txIn   *TxIn
+		txOut  *TxOut
+		expect bool
+	}
+	tests := []test{
+		{"recipient claims with preimage", claim(recipientKey, preimage), makeHTLC(100), true},
+		{"recipient can not claim with wrong preimage", claim(recipientKey, []byte("guess")), makeHTLC(100), false},
+		{"refund key can not claim with preimage", claim(refundKey, preimage), makeHTLC(100), false},
+		{"refund key refunds after timeout", refundTxIn(refundKey), makeHTLC(10), true},
+		{"refund key can not refund before timeout", refundTxIn(refundKey), makeHTLC(11), false},
+		{"recipient can not refund after timeout", refundTxIn(recipientKey), makeHTLC(10), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyTxIn(spendingTx, tc.txIn, tc.txOut)
+			if (err == nil) != tc.expect {
+				t.Errorf("Expected valid: %v, got %v", tc.expect, err)
+			}
+		})
+	}
+}
+
+func TestAddHTLC(t *testing.T) {
+	for _, amount := range []int{0, -10} {
+		if _, err := Mempool().AddHTLC("02", "01", 20, amount); err != errorInvalidAmount {
+			t.Errorf("Expected %v for %d, got %v", errorInvalidAmount, amount, err)
+		}
+	}
+}
+
+func TestParseHTLC(t *testing.T) {
+	s, _ := script.HTLC([]byte{0x01}, []byte{0x02}, 20, []byte{0x03})
+	htlc := parseHTLC("tx", 1, &TxOut{Amount: 5, Script: s.String()})
+	if htlc == nil || htlc.Hash != "01" || htlc.Recipient != "02" || htlc.Refund != "03" || htlc.LockTime != 20 || htlc.Amount != 5 {
+		t.Errorf("parseHTLC() returned wrong htlc %+v", htlc)
+	}
+	if parseHTLC("tx", 0, &TxOut{Address: "aa", Amount: 5}) != nil {
+		t.Error("parseHTLC() should ignore address outputs")
+	}
+}
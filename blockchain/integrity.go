@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"strings"
+
+	"github.com/josh3021/nomadcoin/db"
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// Reasons a stored block, or the checkpoint, is not valid.
+const (
+	reasonUnreadableCheckpoint string = "checkpoint can not be decoded"
+	reasonMissing              string = "block is missing"
+	reasonUnreadable           string = "block can not be decoded"
+	reasonHashMismatch         string = "hash does not match the key the block is stored under"
+	reasonDifficulty           string = "hash does not meet the difficulty of the block"
+	reasonHeight               string = "height does not follow the block it precedes"
+	reasonGenesis              string = "chain does not end at a genesis block of height 1"
+)
+
+var errorNoValidBlock = errors.New("no valid block to rewind to, delete the database to start over")
+
+// Problem is an inconsistency of a stored block.
+type Problem struct {
+	Hash   string `json:"hash,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// IntegrityReport is what CheckIntegrity found walking from the checkpoint to the genesis block.
+type IntegrityReport struct {
+	NewestHash string     `json:"newestHash"`
+	Height     int        `json:"height"`
+	Checked    int        `json:"checked"`
+	Problems   []*Problem `json:"problems"`
+}
+
+// OK reports whether no problem was found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// RepairReport is what Repair found, where it rewound the checkpoint to and how many txs it indexed again.
+type RepairReport struct {
+	Before     *IntegrityReport `json:"before"`
+	Rewound    bool             `json:"rewound"`
+	NewestHash string           `json:"newestHash,omitempty"`
+	Height     int              `json:"height,omitempty"`
+	Reindexed  int              `json:"reindexed"`
+	After      *IntegrityReport `json:"after"`
+}
+
+func decodeBlock(data []byte) (*Block, error) {
+	block := &Block{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// checkBlock returns why block, stored under hash, can not be at height, or "" if it can.
+// The hash of a block can not be computed again, as it covers the addresses of its txs in memory,
+// so it is checked against the key of the block and against its difficulty.
+func checkBlock(hash string, block *Block, height int) string {
+	switch {
+	case block.Hash != hash:
+		return reasonHashMismatch
+	case !strings.HasPrefix(block.Hash, strings.Repeat(prefixTarget, block.Difficulty)):
+		return reasonDifficulty
+	case block.Height != height:
+		return reasonHeight
+	case block.PreviousHash == "" && block.Height != 1:
+		return reasonGenesis
+	}
+	return ""
+}
+
+// CheckIntegrity walks the stored chain from the checkpoint to the genesis block, checking the links,
+// hashes and heights of its blocks, without decoding anything with a panic.
+func CheckIntegrity() *IntegrityReport {
+	report := &IntegrityReport{Problems: []*Problem{}}
+	checkpoint := dbStorage.LoadBlockchain()
+	if checkpoint == nil {
+		return report
+	}
+	saved := &blockchain{}
+	if err := gob.NewDecoder(bytes.NewReader(checkpoint)).Decode(saved); err != nil {
+		report.Problems = append(report.Problems, &Problem{Reason: reasonUnreadableCheckpoint})
+		return report
+	}
+	report.NewestHash, report.Height = saved.NewestHash, saved.Height
+	hash, height := saved.NewestHash, saved.Height
+	for hash != "" {
+		data := dbStorage.FindBlock(hash)
+		if data == nil {
+			report.Problems = append(report.Problems, &Problem{Hash: hash, Height: height, Reason: reasonMissing})
+			return report
+		}
+		block, err := decodeBlock(data)
+		if err != nil {
+			report.Problems = append(report.Problems, &Problem{Hash: hash, Height: height, Reason: reasonUnreadable})
+			return report
+		}
+		report.Checked++
+		if reason := checkBlock(hash, block, height); reason != "" {
+			report.Problems = append(report.Problems, &Problem{Hash: hash, Height: block.Height, Reason: reason})
+			return report
+		}
+		hash, height = block.PreviousHash, height-1
+	}
+	return report
+}
+
+// lastValidBlock returns the highest stored block whose chain down to the genesis block is valid.
+func lastValidBlock() *Block {
+	blocks := make(map[string]*Block)
+	dbStorage.ForEachBlock(func(hash string, data []byte) {
+		if block, err := decodeBlock(data); err == nil {
+			blocks[hash] = block
+		}
+	})
+	valid := make(map[string]bool)
+	var isValid func(hash string, height int) bool
+	isValid = func(hash string, height int) bool {
+		if ok, checked := valid[hash]; checked {
+			return ok
+		}
+		valid[hash] = false
+		block, ok := blocks[hash]
+		if !ok || checkBlock(hash, block, height) != "" {
+			return false
+		}
+		valid[hash] = block.PreviousHash == "" || isValid(block.PreviousHash, height-1)
+		return valid[hash]
+	}
+	var last *Block
+	for hash, block := range blocks {
+		if !isValid(hash, block.Height) {
+			continue
+		}
+		if last == nil || block.Height > last.Height || (block.Height == last.Height && hash < last.Hash) {
+			last = block
+		}
+	}
+	return last
+}
+
+// Repair rewinds the checkpoint to the last valid block if CheckIntegrity finds a problem,
+// and rebuilds the indexes from the blocks of the chain in the same batch, so that they hold
+// neither the txs of the blocks it rewound past nor stale entries of an interrupted write.
+// Blocks above the checkpoint are kept in the database, off the chain.
+func Repair() (*RepairReport, error) {
+	report := &RepairReport{Before: CheckIntegrity()}
+	chain := &blockchain{NewestHash: report.Before.NewestHash, Height: report.Before.Height}
+	batch := new(db.Batch)
+	if !report.Before.OK() {
+		last := lastValidBlock()
+		if last == nil {
+			return nil, errorNoValidBlock
+		}
+		chain = &blockchain{NewestHash: last.Hash, Height: last.Height, CurrentDifficulty: last.Difficulty}
+		batch.SaveBlockchain(utils.ToBytes(chain))
+		report.Rewound, report.NewestHash, report.Height = true, last.Hash, last.Height
+	}
+	blocks := Blocks(chain)
+	batch.DeleteIndexes()
+	indexBlocks(batch, blocks...)
+	dbStorage.Write(batch)
+	for _, block := range blocks {
+		report.Reindexed += len(block.Transactions)
+	}
+	report.After = CheckIntegrity()
+	return report, nil
+}
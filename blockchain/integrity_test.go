@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/db"
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// makeIntegrityChain returns a storage of a valid chain of height blocks, with the checkpoint at the newest one.
+func makeIntegrityChain(height int) *db.Memory {
+	var blocks []*Block
+	previousHash := ""
+	for i := 1; i <= height; i++ {
+		block := &Block{Hash: fmt.Sprintf("0%d", i), PreviousHash: previousHash, Height: i, Difficulty: 1}
+		blocks = append(blocks, block)
+		previousHash = block.Hash
+	}
+	return memoryStorage(&blockchain{NewestHash: previousHash, Height: height, CurrentDifficulty: 1}, blocks...)
+}
+
+// deleteBlock deletes the block hash from storage, by deleting all blocks and saving the others again.
+func deleteBlock(storage *db.Memory, hash string) {
+	batch := new(db.Batch)
+	batch.DeleteAllBlocks()
+	storage.ForEachBlock(func(other string, data []byte) {
+		if other != hash {
+			batch.SaveBlock(other, data)
+		}
+	})
+	storage.Write(batch)
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(storage *db.Memory)
+		problem *Problem
+	}{
+		{"valid chain", func(*db.Memory) {}, nil},
+		{"missing newest block", func(s *db.Memory) { deleteBlock(s, "04") }, &Problem{Hash: "04", Height: 4, Reason: reasonMissing}},
+		{"missing block", func(s *db.Memory) { deleteBlock(s, "02") }, &Problem{Hash: "02", Height: 2, Reason: reasonMissing}},
+		{"unreadable block", func(s *db.Memory) { s.SaveBlock("03", []byte("winter")) }, &Problem{Hash: "03", Height: 3, Reason: reasonUnreadable}},
+		{"unreadable checkpoint", func(s *db.Memory) { s.SaveBlockchain([]byte("winter")) }, &Problem{Reason: reasonUnreadableCheckpoint}},
+		{"block under another key", func(s *db.Memory) {
+			s.SaveBlock("03", utils.ToBytes(&Block{Hash: "0x", PreviousHash: "02", Height: 3, Difficulty: 1}))
+		}, &Problem{Hash: "03", Height: 3, Reason: reasonHashMismatch}},
+		{"easy hash", func(s *db.Memory) {
+			s.SaveBlock("03", utils.ToBytes(&Block{Hash: "03", PreviousHash: "02", Height: 3, Difficulty: 2}))
+		}, &Problem{Hash: "03", Height: 3, Reason: reasonDifficulty}},
+		{"wrong height", func(s *db.Memory) {
+			s.SaveBlock("03", utils.ToBytes(&Block{Hash: "03", PreviousHash: "02", Height: 7, Difficulty: 1}))
+		}, &Problem{Hash: "03", Height: 7, Reason: reasonHeight}},
+		{"no genesis", func(s *db.Memory) {
+			s.SaveBlock("02", utils.ToBytes(&Block{Hash: "02", Height: 2, Difficulty: 1}))
+		}, &Problem{Hash: "02", Height: 2, Reason: reasonGenesis}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := makeIntegrityChain(4)
+			test.damage(storage)
+			dbStorage = storage
+			report := CheckIntegrity()
+			if test.problem == nil {
+				if !report.OK() || report.Checked != 4 {
+					t.Errorf("Expected 4 valid blocks, got %d and %v", report.Checked, report.Problems)
+				}
+				return
+			}
+			if len(report.Problems) != 1 || *report.Problems[0] != *test.problem {
+				t.Errorf("Expected %v, got %v", test.problem, report.Problems)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(storage *db.Memory)
+		height int
+	}{
+		{"missing newest block", func(s *db.Memory) { deleteBlock(s, "04") }, 3},
+		{"missing block", func(s *db.Memory) { deleteBlock(s, "02") }, 1},
+		{"unreadable checkpoint", func(s *db.Memory) { s.SaveBlockchain([]byte("winter")) }, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := makeIntegrityChain(4)
+			test.damage(storage)
+			dbStorage = storage
+			report, err := Repair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Rewound || report.Height != test.height || report.NewestHash != fmt.Sprintf("0%d", test.height) {
+				t.Errorf("Expected the checkpoint at height %d, got %d", test.height, report.Height)
+			}
+			if report.Before.OK() || !report.After.OK() || report.After.Checked != test.height {
+				t.Errorf("Expected the chain to be valid after the repair, got %v", report.After.Problems)
+			}
+		})
+	}
+	t.Run("Valid chains should be left alone.", func(t *testing.T) {
+		storage := makeIntegrityChain(2)
+		checkpoint := storage.LoadBlockchain()
+		dbStorage = storage
+		report, err := Repair()
+		if err != nil || report.Rewound || string(storage.LoadBlockchain()) != string(checkpoint) {
+			t.Errorf("Expected nothing to be done, got %v", err)
+		}
+	})
+	t.Run("Repairs should rebuild the indexes from the blocks of the chain.", func(t *testing.T) {
+		blocks := []*Block{
+			{Hash: "04", PreviousHash: "03", Height: 4, Difficulty: 1, Transactions: []*Tx{{ID: "t4"}}},
+			{Hash: "03", PreviousHash: "02", Height: 3, Difficulty: 1},
+			{Hash: "01", Height: 1, Difficulty: 1},
+		}
+		storage := memoryStorage(&blockchain{NewestHash: "04", Height: 4, CurrentDifficulty: 1}, blocks...)
+		// the tx of block 02 was never indexed, and block 03 is too easy for its difficulty
+		storage.SaveBlock("02", utils.ToBytes(&Block{Hash: "02", PreviousHash: "01", Height: 2, Difficulty: 1, Transactions: []*Tx{{ID: "t2"}}}))
+		storage.SaveBlock("03", utils.ToBytes(&Block{Hash: "03", PreviousHash: "02", Height: 3, Difficulty: 2}))
+		dbStorage = storage
+		report, err := Repair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Height != 2 || report.Reindexed != 1 {
+			t.Errorf("Expected 1 tx indexed at height 2, got %d at height %d", report.Reindexed, report.Height)
+		}
+		if tx := FindTx(&blockchain{}, "t2"); tx == nil {
+			t.Error("Expected t2 to be indexed")
+		}
+		if tx := FindTx(&blockchain{}, "t4"); tx != nil {
+			t.Errorf("Expected the tx of a block off the chain to be unindexed, got %v", tx)
+		}
+	})
+	t.Run("Chains without a valid block should not be repaired.", func(t *testing.T) {
+		storage := makeIntegrityChain(2)
+		deleteBlock(storage, "01")
+		dbStorage = storage
+		if _, err := Repair(); err != errorNoValidBlock {
+			t.Errorf("Expected %v, got %v", errorNoValidBlock, err)
+		}
+	})
+}
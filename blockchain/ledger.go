@@ -0,0 +1,269 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+)
+
+// TxStatus is where a wallet tx stands.
+type TxStatus string
+
+const (
+	// TxPending txs are in the mempool.
+	TxPending TxStatus = "pending"
+	// TxConfirmed txs are in a block of the chain.
+	TxConfirmed TxStatus = "confirmed"
+	// TxConflicted txs spend an output another tx on chain spent, and can never confirm.
+	TxConflicted TxStatus = "conflicted"
+	// TxAbandoned txs left the chain or the mempool without a conflict, and could still be broadcast again.
+	TxAbandoned TxStatus = "abandoned"
+)
+
+// WalletTx is a tx that paid to or spent from a set of addresses.
+type WalletTx struct {
+	TxID          string   `json:"txId"`
+	Status        TxStatus `json:"status"`
+	BlockHash     string   `json:"blockHash,omitempty"`
+	Height        int      `json:"height,omitempty"`
+	Confirmations int      `json:"confirmations"`
+	Timestamp     int      `json:"timestamp"`
+	Received      int      `json:"received"`
+	Sent          int      `json:"sent"`
+}
+
+// ledgerTx is a tx the ledger saw, with what it paid to and spent from each address.
+type ledgerTx struct {
+	tx        *Tx
+	received  map[string]int
+	sent      map[string]int
+	blockHash string
+	height    int
+}
+
+// connectedBlock is a block the ledger connected, with the txs to disconnect if it leaves the chain.
+type connectedBlock struct {
+	hash   string
+	height int
+	txIDs  []string
+}
+
+// ledger follows the chain block by block and remembers every tx per address,
+// so wallet histories survive reorgs and do not rescan the chain.
+type ledger struct {
+	txs        map[string]*ledgerTx
+	unresolved map[string]*ledgerTx
+	byAddress  map[string]map[string]bool
+	outputs    map[Outpoint]*TxOut
+	spentBy    map[Outpoint]string
+	connected  []*connectedBlock
+	index      map[string]int
+	m          sync.Mutex
+}
+
+var l *ledger
+var ledgerOnce sync.Once
+
+func newLedger() *ledger {
+	return &ledger{
+		txs:        make(map[string]*ledgerTx),
+		unresolved: make(map[string]*ledgerTx),
+		byAddress:  make(map[string]map[string]bool),
+		outputs:    make(map[Outpoint]*TxOut),
+		spentBy:    make(map[Outpoint]string),
+		index:      make(map[string]int),
+	}
+}
+
+func txLedger() *ledger {
+	ledgerOnce.Do(func() {
+		l = newLedger()
+	})
+	return l
+}
+
+// record remembers tx and the addresses it pays to and spends from.
+func (l *ledger) record(tx *Tx) *ledgerTx {
+	if recorded, ok := l.txs[tx.ID]; ok {
+		return recorded
+	}
+	recorded := &ledgerTx{tx: tx, received: make(map[string]int), sent: make(map[string]int)}
+	l.txs[tx.ID] = recorded
+	l.resolve(recorded)
+	for index, txOut := range tx.TxOuts {
+		l.outputs[Outpoint{tx.ID, index}] = txOut
+		if txOut.Address != "" {
+			recorded.received[txOut.Address] += txOut.Amount
+			l.indexAddress(txOut.Address, tx.ID)
+		}
+	}
+	return recorded
+}
+
+// resolve counts what recorded spent from each address, once the outputs it spends are known.
+// Txs can enter the mempool before the ledger connected the blocks of their inputs.
+func (l *ledger) resolve(recorded *ledgerTx) {
+	sent := make(map[string]int)
+	for _, txIn := range recorded.tx.TxIns {
+		if txIn.isCoinbase() {
+			break
+		}
+		spent, ok := l.outputs[Outpoint{txIn.TxID, txIn.Index}]
+		if !ok {
+			l.unresolved[recorded.tx.ID] = recorded
+			return
+		}
+		sent[spent.Address] += spent.Amount
+	}
+	for address := range sent {
+		l.indexAddress(address, recorded.tx.ID)
+	}
+	recorded.sent = sent
+	delete(l.unresolved, recorded.tx.ID)
+}
+
+func (l *ledger) indexAddress(address, txID string) {
+	if l.byAddress[address] == nil {
+		l.byAddress[address] = make(map[string]bool)
+	}
+	l.byAddress[address][txID] = true
+}
+
+// addPending records a tx entering the mempool.
+func (l *ledger) addPending(tx *Tx) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.record(tx)
+}
+
+func (l *ledger) connectBlock(block *Block) {
+	connected := &connectedBlock{hash: block.Hash, height: block.Height}
+	for _, tx := range block.Transactions {
+		recorded := l.record(tx)
+		recorded.blockHash, recorded.height = block.Hash, block.Height
+		for _, txIn := range tx.TxIns {
+			if !txIn.isCoinbase() {
+				l.spentBy[Outpoint{txIn.TxID, txIn.Index}] = tx.ID
+			}
+		}
+		connected.txIDs = append(connected.txIDs, tx.ID)
+	}
+	l.index[block.Hash] = len(l.connected)
+	l.connected = append(l.connected, connected)
+}
+
+func (l *ledger) disconnectBlock() {
+	disconnected := l.connected[len(l.connected)-1]
+	l.connected = l.connected[:len(l.connected)-1]
+	delete(l.index, disconnected.hash)
+	for _, txID := range disconnected.txIDs {
+		recorded := l.txs[txID]
+		recorded.blockHash, recorded.height = "", 0
+		for _, txIn := range recorded.tx.TxIns {
+			outpoint := Outpoint{txIn.TxID, txIn.Index}
+			if l.spentBy[outpoint] == txID {
+				delete(l.spentBy, outpoint)
+			}
+		}
+	}
+}
+
+// sync connects the blocks added since the last sync, after disconnecting the blocks a reorg replaced.
+func (l *ledger) sync(b *blockchain) {
+	var added []*Block
+	b.m.Lock()
+	hash := b.NewestHash
+	b.m.Unlock()
+	for hash != "" {
+		if _, ok := l.index[hash]; ok {
+			break
+		}
+		block, err := FindBlock(hash)
+		if err != nil {
+			// the chain is being replaced, the next sync catches up
+			return
+		}
+		added = append(added, block)
+		hash = block.PreviousHash
+	}
+	for len(l.connected) > 0 && l.connected[len(l.connected)-1].hash != hash {
+		l.disconnectBlock()
+	}
+	for i := len(added) - 1; i >= 0; i-- {
+		l.connectBlock(added[i])
+	}
+	for _, recorded := range l.unresolved {
+		l.resolve(recorded)
+	}
+}
+
+// status returns the status of recorded and its confirmations. The mempool has to be locked.
+func (l *ledger) status(recorded *ledgerTx) (TxStatus, int) {
+	if recorded.height > 0 {
+		return TxConfirmed, l.connected[len(l.connected)-1].height - recorded.height + 1
+	}
+	if _, ok := Mempool().Txs[recorded.tx.ID]; ok {
+		return TxPending, 0
+	}
+	for _, txIn := range recorded.tx.TxIns {
+		if spender, ok := l.spentBy[Outpoint{txIn.TxID, txIn.Index}]; ok && spender != recorded.tx.ID {
+			return TxConflicted, 0
+		}
+	}
+	return TxAbandoned, 0
+}
+
+// WalletTxs returns every tx that paid to or spent from addresses, pending ones first, then newest first.
+func WalletTxs(b *blockchain, addresses []string) []*WalletTx {
+	// the mempool is locked before the ledger, as adding a tx to it does
+	mem := Mempool()
+	mem.m.Lock()
+	defer mem.m.Unlock()
+	l := txLedger()
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.sync(b)
+	return l.walletTxs(addresses)
+}
+
+func (l *ledger) walletTxs(addresses []string) []*WalletTx {
+	owned := make(map[string]bool)
+	txIDs := make(map[string]bool)
+	for _, address := range addresses {
+		owned[address] = true
+		for txID := range l.byAddress[address] {
+			txIDs[txID] = true
+		}
+	}
+	walletTxs := []*WalletTx{}
+	for txID := range txIDs {
+		recorded := l.txs[txID]
+		status, confirmations := l.status(recorded)
+		walletTx := &WalletTx{
+			TxID:          txID,
+			Status:        status,
+			BlockHash:     recorded.blockHash,
+			Height:        recorded.height,
+			Confirmations: confirmations,
+			Timestamp:     recorded.tx.Timestamp,
+		}
+		for address := range owned {
+			walletTx.Received += recorded.received[address]
+			walletTx.Sent += recorded.sent[address]
+		}
+		walletTxs = append(walletTxs, walletTx)
+	}
+	sort.Slice(walletTxs, func(i, j int) bool {
+		x, y := walletTxs[i], walletTxs[j]
+		if (x.Height == 0) != (y.Height == 0) {
+			return x.Height == 0
+		}
+		if x.Height != y.Height {
+			return x.Height > y.Height
+		}
+		if x.Timestamp != y.Timestamp {
+			return x.Timestamp > y.Timestamp
+		}
+		return x.TxID < y.TxID
+	})
+	return walletTxs
+}
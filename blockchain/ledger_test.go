@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func makeLedgerChain() (*Block, *Block, *Tx) {
+	coinbase := &Tx{ID: "coinbase", TxIns: []*TxIn{{Signature: "COINBASE", Index: -1}}, TxOuts: []*TxOut{{Address: "me", Amount: 50}}}
+	spend := &Tx{ID: "spend", Timestamp: 1, TxIns: []*TxIn{{TxID: "coinbase", Index: 0}}, TxOuts: []*TxOut{{Address: "them", Amount: 30}, {Address: "change", Amount: 20}}}
+	first := &Block{Hash: "a", Height: 1, Transactions: []*Tx{coinbase}}
+	second := &Block{Hash: "b", PreviousHash: "a", Height: 2, Transactions: []*Tx{spend}}
+	return first, second, spend
+}
+
+func statusOf(txs []*WalletTx, txID string) *WalletTx {
+	for _, tx := range txs {
+		if tx.TxID == txID {
+			return tx
+		}
+	}
+	return nil
+}
+
+func TestLedger(t *testing.T) {
+	first, second, spend := makeLedgerChain()
+	dbStorage = memoryStorage(nil)
+	bc := &blockchain{NewestHash: "b", Height: 2}
+	persistBlocks(bc, false, first, second)
+	l := newLedger()
+	addresses := []string{"me", "change"}
+	t.Run("Connected txs should be confirmed.", func(t *testing.T) {
+		l.sync(bc)
+		txs := l.walletTxs(addresses)
+		if len(txs) != 2 {
+			t.Fatalf("Expected 2 txs, got %d", len(txs))
+		}
+		tests := []WalletTx{
+			{TxID: "spend", Status: TxConfirmed, BlockHash: "b", Height: 2, Confirmations: 1, Timestamp: 1, Received: 20, Sent: 50},
+			{TxID: "coinbase", Status: TxConfirmed, BlockHash: "a", Height: 1, Confirmations: 2, Received: 50},
+		}
+		for i, expected := range tests {
+			if *txs[i] != expected {
+				t.Errorf("Expected %v, got %v", expected, *txs[i])
+			}
+		}
+	})
+	t.Run("Txs of disconnected blocks should be pending or abandoned.", func(t *testing.T) {
+		bc.NewestHash = "a"
+		l.sync(bc)
+		if tx := statusOf(l.walletTxs(addresses), "spend"); tx.Status != TxAbandoned || tx.Height != 0 {
+			t.Errorf("Expected %s, got %v", TxAbandoned, tx)
+		}
+		Mempool().Txs[spend.ID] = spend
+		defer delete(Mempool().Txs, spend.ID)
+		if tx := statusOf(l.walletTxs(addresses), "spend"); tx.Status != TxPending {
+			t.Errorf("Expected %s, got %v", TxPending, tx)
+		}
+	})
+	t.Run("Txs double spent by a reorg should be conflicted.", func(t *testing.T) {
+		doubleSpend := &Tx{ID: "double", TxIns: []*TxIn{{TxID: "coinbase", Index: 0}}, TxOuts: []*TxOut{{Address: "them", Amount: 50}}}
+		bc.NewestHash = "c"
+		persistBlocks(bc, false, &Block{Hash: "c", PreviousHash: "a", Height: 2, Transactions: []*Tx{doubleSpend}})
+		l.sync(bc)
+		txs := l.walletTxs(addresses)
+		if tx := statusOf(txs, "spend"); tx.Status != TxConflicted {
+			t.Errorf("Expected %s, got %v", TxConflicted, tx)
+		}
+		if tx := statusOf(txs, "double"); tx.Status != TxConfirmed || tx.Sent != 50 || tx.Received != 0 {
+			t.Errorf("Expected confirmed double spend of 50, got %v", tx)
+		}
+	})
+	t.Run("Pending txs should be resolved once their inputs connect.", func(t *testing.T) {
+		unknown := &Tx{ID: "unknown", TxIns: []*TxIn{{TxID: "later", Index: 0}}, TxOuts: []*TxOut{{Address: "them", Amount: 5}}}
+		l.addPending(unknown)
+		later := &Tx{ID: "later", TxIns: []*TxIn{{Signature: "COINBASE", Index: -1}}, TxOuts: []*TxOut{{Address: "me", Amount: 5}}}
+		bc.NewestHash = "d"
+		persistBlocks(bc, false, &Block{Hash: "d", PreviousHash: "c", Height: 3, Transactions: []*Tx{later}})
+		l.sync(bc)
+		if tx := statusOf(l.walletTxs(addresses), "unknown"); tx == nil || tx.Sent != 5 {
+			t.Errorf("Expected a tx sending 5, got %v", tx)
+		}
+	})
+	t.Run("Wallet txs should be read while txs enter the mempool.", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			tx := &Tx{ID: fmt.Sprintf("pending%d", i), TxIns: []*TxIn{{TxID: "coinbase", Index: 0}}, TxOuts: []*TxOut{{Address: "me", Amount: 1}}}
+			defer delete(Mempool().Txs, tx.ID)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				Mempool().AddPeerTx(tx)
+			}()
+			go func() {
+				defer wg.Done()
+				WalletTxs(bc, addresses)
+			}()
+		}
+		wg.Wait()
+		if tx := statusOf(WalletTxs(bc, addresses), "pending3"); tx == nil || tx.Status != TxPending {
+			t.Errorf("Expected %s, got %v", TxPending, tx)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+var errorNotMultisig = errors.New("address is not a multisig address")
+var errorTxMismatch = errors.New("transactions to combine are not the same tx")
+
+// MakeMultisigTx returns an unsigned tx spending the outputs of a multisig address.
+func MakeMultisigTx(from, to string, amount int) (*Tx, error) {
+	if !wallet.IsMultisigAddress(from) {
+		return nil, errorNotMultisig
+	}
+	_, addresses, err := wallet.ParseMultisigAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := buildTx([]string{from}, from, nil, &TxOut{Address: to, Amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	for _, txIn := range tx.TxIns {
+		txIn.Signature = ""
+		txIn.Signatures = make([]string, len(addresses))
+	}
+	return tx, nil
+}
+
+// SignMultisigTx adds the signature of the node wallet to every multisig input of tx.
+func SignMultisigTx(tx *Tx) error {
+	for _, txIn := range tx.TxIns {
+		address, err := spentAddress(txIn)
+		if err != nil {
+			return err
+		}
+		signatures, err := wallet.SignMultisig(tx.ID, address, txIn.Signatures, wallet.Wallet())
+		if err != nil {
+			return err
+		}
+		txIn.Signatures = signatures
+	}
+	return nil
+}
+
+// CombineMultisigTxs merges partially signed copies of the same multisig tx.
+func CombineMultisigTxs(txs []*Tx) (*Tx, error) {
+	if len(txs) == 0 {
+		return nil, errorTxMismatch
+	}
+	combined := txs[0]
+	for _, tx := range txs[1:] {
+		if tx.ID != combined.ID || len(tx.TxIns) != len(combined.TxIns) {
+			return nil, errorTxMismatch
+		}
+		for index, txIn := range tx.TxIns {
+			target := combined.TxIns[index]
+			if txIn.TxID != target.TxID || txIn.Index != target.Index {
+				return nil, errorTxMismatch
+			}
+			signatures, err := wallet.CombineSignatures(target.Signatures, txIn.Signatures)
+			if err != nil {
+				return nil, err
+			}
+			target.Signatures = signatures
+		}
+	}
+	return combined, nil
+}
+
+// spentAddress returns the multisig address of the output spent by txIn.
+func spentAddress(txIn *TxIn) (string, error) {
+	prevTx := FindTx(Blockchain(), txIn.TxID)
+	if prevTx == nil || txIn.Index < 0 || txIn.Index >= len(prevTx.TxOuts) {
+		return "", errorTxNotValid
+	}
+	address := prevTx.TxOuts[txIn.Index].Address
+	if !wallet.IsMultisigAddress(address) {
+		return "", errorNotMultisig
+	}
+	return address, nil
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import "testing"
+
+func TestCombineMultisigTxs(t *testing.T) {
+	makePartial := func(signatures ...string) *Tx {
+		return &Tx{ID: "test", TxIns: []*TxIn{{TxID: "x", Index: 0, Signatures: signatures}}}
+	}
+	t.Run("Partial signatures should be combined.", func(t *testing.T) {
+		tx, err := CombineMultisigTxs([]*Tx{makePartial("aa", "", ""), makePartial("", "", "cc")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signatures := tx.TxIns[0].Signatures
+		if signatures[0] != "aa" || signatures[1] != "" || signatures[2] != "cc" {
+			t.Errorf("Expected [aa  cc], got %v", signatures)
+		}
+	})
+	t.Run("Different txs should not be combined.", func(t *testing.T) {
+		other := makePartial("", "bb", "")
+		other.ID = "other"
+		_, err := CombineMultisigTxs([]*Tx{makePartial("aa", "", ""), other})
+		if err != errorTxMismatch {
+			t.Errorf("Expected %v, got %v", errorTxMismatch, err)
+		}
+	})
+}
+
+func TestCombineMuSigTxs(t *testing.T) {
+	makePartial := func(nonces map[string]string) *MuSigTx {
+		return &MuSigTx{
+			Tx:                &Tx{ID: "test", TxIns: []*TxIn{{TxID: "x", Index: 0}}},
+			Nonces:            []map[string]string{nonces},
+			PartialSignatures: []map[string]string{{}},
+		}
+	}
+	t.Run("Nonces should be combined.", func(t *testing.T) {
+		m, err := CombineMuSigTxs([]*MuSigTx{makePartial(map[string]string{"a": "aa"}), makePartial(map[string]string{"c": "cc"})})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nonces := m.Nonces[0]; len(nonces) != 2 || nonces["a"] != "aa" || nonces["c"] != "cc" {
+			t.Errorf("Expected map[a:aa c:cc], got %v", nonces)
+		}
+	})
+	t.Run("Conflicting nonces should not be combined.", func(t *testing.T) {
+		_, err := CombineMuSigTxs([]*MuSigTx{makePartial(map[string]string{"a": "aa"}), makePartial(map[string]string{"a": "bb"})})
+		if err != errorMuSigConflict {
+			t.Errorf("Expected %v, got %v", errorMuSigConflict, err)
+		}
+	})
+	t.Run("Different txs should not be combined.", func(t *testing.T) {
+		other := makePartial(map[string]string{})
+		other.Tx.ID = "other"
+		if _, err := CombineMuSigTxs([]*MuSigTx{makePartial(map[string]string{}), other}); err != errorTxMismatch {
+			t.Errorf("Expected %v, got %v", errorTxMismatch, err)
+		}
+	})
+}
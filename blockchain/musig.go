@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+var errorNotMuSigSigner = errors.New("wallet is not a signer of the musig address")
+var errorMuSigConflict = errors.New("conflicting musig nonces or partial signatures")
+
+// MuSigTx is a tx spending the outputs of a MuSig address while its signers sign it together.
+// Every input collects the public nonce and then the partial signature of every address of the MuSig address,
+// keyed by that address. On chain the tx is an ordinary Schnorr spend, so the signers stay hidden.
+type MuSigTx struct {
+	Tx                *Tx                 `json:"tx"`
+	Addresses         []string            `json:"addresses"`
+	Nonces            []map[string]string `json:"nonces"`
+	PartialSignatures []map[string]string `json:"partialSignatures"`
+}
+
+type muSigSigner interface {
+	HasAddress(address string) bool
+	MuSigNonce(address string) (string, error)
+	MuSigSign(payload, address string, addresses []string, nonces map[string]string) (string, error)
+}
+
+// MakeMuSigTx returns an unsigned tx spending the outputs of the MuSig address of addresses, sending the change back to it.
+func MakeMuSigTx(addresses []string, to string, amount int) (*MuSigTx, error) {
+	from, err := wallet.MuSigAddress(addresses)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := buildTx([]string{from}, from, nil, &TxOut{Address: to, Amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	muSigTx := &MuSigTx{Tx: tx, Addresses: addresses}
+	for _, txIn := range tx.TxIns {
+		txIn.Signature = ""
+		muSigTx.Nonces = append(muSigTx.Nonces, map[string]string{})
+		muSigTx.PartialSignatures = append(muSigTx.PartialSignatures, map[string]string{})
+	}
+	return muSigTx, nil
+}
+
+// signers returns the addresses of the MuSig address whose keys w holds, after checking that m is well formed.
+func (m *MuSigTx) signers(w muSigSigner) ([]string, error) {
+	if m.Tx == nil || m.Tx.ID != m.Tx.hash() || len(m.Nonces) != len(m.Tx.TxIns) || len(m.PartialSignatures) != len(m.Tx.TxIns) {
+		return nil, errorTxNotValid
+	}
+	var signers []string
+	for _, address := range m.Addresses {
+		if w.HasAddress(address) {
+			signers = append(signers, address)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, errorNotMuSigSigner
+	}
+	return signers, nil
+}
+
+// AddNonces adds a new public nonce of every address of w to every input of m.
+func (m *MuSigTx) AddNonces(w muSigSigner) error {
+	signers, err := m.signers(w)
+	if err != nil {
+		return err
+	}
+	for index := range m.Tx.TxIns {
+		for _, address := range signers {
+			nonce, err := w.MuSigNonce(address)
+			if err != nil {
+				return err
+			}
+			m.Nonces[index][address] = nonce
+		}
+	}
+	return nil
+}
+
+// Sign adds the partial signature of every address of w to every input of m, once m holds the nonces of all addresses.
+func (m *MuSigTx) Sign(w muSigSigner) error {
+	signers, err := m.signers(w)
+	if err != nil {
+		return err
+	}
+	for index := range m.Tx.TxIns {
+		for _, address := range signers {
+			partial, err := w.MuSigSign(m.Tx.ID, address, m.Addresses, m.Nonces[index])
+			if err != nil {
+				return err
+			}
+			m.PartialSignatures[index][address] = partial
+		}
+	}
+	return nil
+}
+
+// SignedTx aggregates the partial signatures of every input into its signature, and returns the signed tx.
+func (m *MuSigTx) SignedTx() (*Tx, error) {
+	if m.Tx == nil || len(m.Nonces) != len(m.Tx.TxIns) || len(m.PartialSignatures) != len(m.Tx.TxIns) {
+		return nil, errorTxNotValid
+	}
+	for index, txIn := range m.Tx.TxIns {
+		signature, err := wallet.AggregateSignatures(m.Tx.ID, m.Addresses, m.Nonces[index], m.PartialSignatures[index])
+		if err != nil {
+			return nil, err
+		}
+		txIn.Signature = signature
+	}
+	return m.Tx, nil
+}
+
+// CombineMuSigTxs merges the nonces and partial signatures of copies of the same MuSig tx.
+func CombineMuSigTxs(txs []*MuSigTx) (*MuSigTx, error) {
+	if len(txs) == 0 || txs[0].Tx == nil {
+		return nil, errorTxMismatch
+	}
+	combined := txs[0]
+	for _, m := range txs[1:] {
+		if m.Tx == nil || m.Tx.ID != combined.Tx.ID || len(m.Nonces) != len(combined.Nonces) || len(m.PartialSignatures) != len(combined.PartialSignatures) {
+			return nil, errorTxMismatch
+		}
+		for index := range m.Nonces {
+			if err := mergeMuSigValues(combined.Nonces[index], m.Nonces[index]); err != nil {
+				return nil, err
+			}
+		}
+		for index := range m.PartialSignatures {
+			if err := mergeMuSigValues(combined.PartialSignatures[index], m.PartialSignatures[index]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return combined, nil
+}
+
+func mergeMuSigValues(target, values map[string]string) error {
+	for address, value := range values {
+		if known, ok := target[address]; ok && known != value {
+			return errorMuSigConflict
+		}
+		target[address] = value
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/josh3021/nomadcoin/script"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// SpentTxOut contains the output spent by a tx input, so it can be signed without the chain.
+type SpentTxOut struct {
+	TxID  string `json:"txId"`
+	Index int    `json:"index"`
+	TxOut *TxOut `json:"txOut"`
+}
+
+var (
+	errorCanNotSign  = errors.New("wallet can not sign this input")
+	errorSpentTxOuts = errors.New("spent outputs do not match the inputs of the tx")
+)
+
+// BuildTx returns an unsigned tx paying amount and data from the outputs of "from" that options choose, with the outputs it spends.
+func BuildTx(from, to string, amount int, data string, options *TxOptions) (*Tx, []*SpentTxOut, error) {
+	outputs := []*TxOut{}
+	if data != "" {
+		dataScript, err := script.NullData([]byte(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, &TxOut{Amount: 0, Script: dataScript.String()})
+	}
+	if to != "" {
+		outputs = append(outputs, &TxOut{Address: to, Amount: amount})
+	}
+	tx, err := buildTx([]string{from}, from, options, outputs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	spent, err := SpentTxOuts(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, txIn := range tx.TxIns {
+		txIn.Signature = ""
+	}
+	return tx, spent, nil
+}
+
+// SpentTxOuts returns the outputs spent by the inputs of tx.
+func SpentTxOuts(tx *Tx) ([]*SpentTxOut, error) {
+	var spent []*SpentTxOut
+	for _, txIn := range tx.TxIns {
+		prevTx := FindTx(Blockchain(), txIn.TxID)
+		if prevTx == nil || txIn.Index < 0 || txIn.Index >= len(prevTx.TxOuts) {
+			return nil, errorTxNotValid
+		}
+		spent = append(spent, &SpentTxOut{TxID: txIn.TxID, Index: txIn.Index, TxOut: prevTx.TxOuts[txIn.Index]})
+	}
+	return spent, nil
+}
+
+// SignTx signs every input of tx spending an output of the node wallet.
+func SignTx(tx *Tx) error {
+	if tx.ID != tx.hash() {
+		return errorTxNotValid
+	}
+	spent, err := SpentTxOuts(tx)
+	if err != nil {
+		return err
+	}
+	return signSpent(tx, spent, "")
+}
+
+// SignTxOffline signs every input of tx spending an output of the named wallet, or of the node wallet if name is empty,
+// taking the outputs the inputs spend from spent instead of the chain. The tx ID does not commit to the amounts
+// of spent, so whoever signs must check the fee they imply.
+func SignTxOffline(tx *Tx, spent []*SpentTxOut, name string) error {
+	if tx.ID != tx.hash() {
+		return errorTxNotValid
+	}
+	if len(spent) != len(tx.TxIns) {
+		return errorSpentTxOuts
+	}
+	for index, txIn := range tx.TxIns {
+		if spent[index] == nil || spent[index].TxOut == nil || spent[index].TxID != txIn.TxID || spent[index].Index != txIn.Index {
+			return errorSpentTxOuts
+		}
+	}
+	return signSpent(tx, spent, name)
+}
+
+func signSpent(tx *Tx, spent []*SpentTxOut, name string) error {
+	w, err := wallet.Manager().Get(name)
+	if err != nil {
+		return err
+	}
+	for index, txIn := range tx.TxIns {
+		prevTxOut := spent[index]
+		address := prevTxOut.TxOut.Address
+		switch {
+		case prevTxOut.TxOut.Script != "":
+			return errorCanNotSign
+		case wallet.IsMultisigAddress(address):
+			signatures, err := wallet.SignMultisig(tx.ID, address, txIn.Signatures, w)
+			if err != nil {
+				return err
+			}
+			txIn.Signatures = signatures
+		case w.HasAddress(address):
+			signature, err := wallet.SignFor(tx.ID, address, w)
+			if err != nil {
+				return err
+			}
+			if _, ok := wallet.AddressPublicKey(address); !ok {
+				if txIn.PublicKey, err = w.PublicKey(address); err != nil {
+					return err
+				}
+			}
+			txIn.Signature = signature
+		default:
+			return errorCanNotSign
+		}
+	}
+	return nil
+}
+
+// AddSignedTx validates a fully signed tx and adds it to the mempool.
+func (m *mempool) AddSignedTx(tx *Tx) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if !validate(tx) {
+		return errorTxNotValid
+	}
+	m.add(tx)
+	return nil
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+func TestSignTxOffline(t *testing.T) {
+	_, pubKey := makeTestKey(t)
+	stranger := hex.EncodeToString(pubKey)
+	_, address := importTestWallet(t, "offline")
+	makeTx := func(address string) (*Tx, []*SpentTxOut) {
+		spent := &SpentTxOut{TxID: "coinbase", Index: 1, TxOut: &TxOut{Address: address, Amount: 10}}
+		tx := &Tx{Timestamp: 1, TxIns: []*TxIn{{TxID: spent.TxID, Index: spent.Index}}, TxOuts: []*TxOut{{Address: stranger, Amount: 9}}}
+		tx.getID()
+		return tx, []*SpentTxOut{spent}
+	}
+	t.Run("Inputs of the wallet should be signed without the chain.", func(t *testing.T) {
+		tx, spent := makeTx(address)
+		if err := SignTxOffline(tx, spent, "offline"); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if err := verifyTxInAt(tx, tx.TxIns[0], spent[0].TxOut, 2); err != nil {
+			t.Errorf("Expected the signed input to verify, got %v", err)
+		}
+	})
+	t.Run("Spent outputs should match the inputs.", func(t *testing.T) {
+		tx, spent := makeTx(address)
+		tampered, _ := makeTx(address)
+		tampered.TxOuts[0].Amount = 1
+		tests := []struct {
+			name  string
+			tx    *Tx
+			spent []*SpentTxOut
+			err   error
+		}{
+			{"missing spent output", tx, nil, errorSpentTxOuts},
+			{"other output", tx, []*SpentTxOut{{TxID: "coinbase", Index: 0, TxOut: spent[0].TxOut}}, errorSpentTxOuts},
+			{"no tx output", tx, []*SpentTxOut{{TxID: "coinbase", Index: 1}}, errorSpentTxOuts},
+			{"altered tx", tampered, spent, errorTxNotValid},
+		}
+		for _, test := range tests {
+			if err := SignTxOffline(test.tx, test.spent, "offline"); err != test.err {
+				t.Errorf("Expected %v for %s, got %v", test.err, test.name, err)
+			}
+		}
+	})
+	t.Run("Inputs of other keys should not be signed.", func(t *testing.T) {
+		tx, spent := makeTx(stranger)
+		if err := SignTxOffline(tx, spent, "offline"); err != errorCanNotSign {
+			t.Errorf("Expected %v, got %v", errorCanNotSign, err)
+		}
+		if err := SignTxOffline(tx, spent, "cold"); err != wallet.ErrWalletNotLoaded {
+			t.Errorf("Expected %v, got %v", wallet.ErrWalletNotLoaded, err)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/josh3021/nomadcoin/script"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+var errorKeyTypeMismatch = errors.New("public key is not of the key type of the address")
+
+// txChecker checks the signatures and lock times of a script against the spending tx.
+// Legacy checkers also accept the unpadded signatures of old txs.
+type txChecker struct {
+	tx     *Tx
+	height int
+	legacy bool
+}
+
+func (c txChecker) CheckSig(signature, pubKey []byte) bool {
+	return sigCache.verify(c.tx.ID, signature, pubKey, c.legacy)
+}
+
+func (c txChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= int64(c.height)
+}
+
+// lockingScript returns the script of txOut, or the standard script of its address if it has none.
+func (txOut *TxOut) lockingScript() (script.Script, error) {
+	if txOut.Script != "" {
+		return script.FromHex(txOut.Script)
+	}
+	if wallet.IsMultisigAddress(txOut.Address) {
+		required, addresses, err := wallet.ParseMultisigAddress(txOut.Address)
+		if err != nil {
+			return nil, err
+		}
+		var pubKeys [][]byte
+		for _, address := range addresses {
+			pubKey, err := hex.DecodeString(address)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys = append(pubKeys, pubKey)
+		}
+		return script.Multisig(required, pubKeys)
+	}
+	if publicKey, ok := wallet.AddressPublicKey(txOut.Address); ok {
+		pubKey, err := hex.DecodeString(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return script.PayToPubKey(pubKey)
+	}
+	pubKeyHash, err := wallet.DecodeAddress(txOut.Address)
+	if err != nil {
+		return nil, err
+	}
+	return script.PayToPubKeyHash(pubKeyHash)
+}
+
+// Data returns the data carried by txOut, or "" if it is not a data-carrying output.
+func (txOut *TxOut) Data() string {
+	if txOut.Script == "" {
+		return ""
+	}
+	s, err := script.FromHex(txOut.Script)
+	if err != nil {
+		return ""
+	}
+	data, _ := script.ParseNullData(s)
+	return string(data)
+}
+
+// isUnspendable reports whether txOut can never be spent and so never enters the UTXO set.
+func (txOut *TxOut) isUnspendable() bool {
+	s, err := script.FromHex(txOut.Script)
+	return err == nil && s.IsUnspendable()
+}
+
+// validateTxOut checks that outputs pay to valid addresses and data-carrying outputs hold no coins and respect the data size limit.
+func validateTxOut(txOut *TxOut) bool {
+	if txOut.Amount < 0 {
+		return false
+	}
+	if txOut.Script == "" {
+		return wallet.ValidateAddress(txOut.Address) == nil
+	}
+	if !txOut.isUnspendable() {
+		return true
+	}
+	s, _ := script.FromHex(txOut.Script)
+	data, ok := script.ParseNullData(s)
+	return ok && txOut.Amount == 0 && len(data) <= script.MaxDataSize
+}
+
+// unlockingScript returns the script of txIn, or a push of its signatures and public key if it has none.
+func (txIn *TxIn) unlockingScript() (script.Script, error) {
+	if txIn.Script != "" {
+		return script.FromHex(txIn.Script)
+	}
+	signatures := txIn.Signatures
+	if len(signatures) == 0 {
+		signatures = []string{txIn.Signature}
+	}
+	var pushes [][]byte
+	for _, signature := range signatures {
+		if signature == "" {
+			continue
+		}
+		signatureBytes, err := hex.DecodeString(signature)
+		if err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, signatureBytes)
+	}
+	if txIn.PublicKey != "" {
+		pubKey, err := hex.DecodeString(txIn.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, pubKey)
+	}
+	return script.PushOnly(pushes...)
+}
+
+// checkKeyType checks that the public key of txIn is of the key type its checksummed address names.
+// Addresses holding their public key pay to that key, so there is nothing to check.
+func checkKeyType(txIn *TxIn, spent *TxOut) error {
+	if spent.Script != "" || wallet.IsMultisigAddress(spent.Address) {
+		return nil
+	}
+	if _, ok := wallet.AddressPublicKey(spent.Address); ok {
+		return nil
+	}
+	addressKeyType, err := wallet.AddressKeyType(spent.Address)
+	if err != nil {
+		return err
+	}
+	if keyType, err := wallet.PublicKeyType(txIn.PublicKey); err != nil || keyType != addressKeyType {
+		return errorKeyTypeMismatch
+	}
+	return nil
+}
+
+// verifyTxIn runs the scripts unlocking the output spent by txIn, for tx in the next block.
+func verifyTxIn(tx *Tx, txIn *TxIn, spent *TxOut) error {
+	return verifyTxInAt(tx, txIn, spent, Blockchain().Height+1)
+}
+
+// verifyTxInAt runs the scripts unlocking the output spent by txIn, for tx in the block at height.
+func verifyTxInAt(tx *Tx, txIn *TxIn, spent *TxOut, height int) error {
+	return runScripts(txChecker{tx: tx, height: height}, txIn, spent)
+}
+
+// runScripts runs the scripts unlocking the output spent by txIn, checking them with checker.
+func runScripts(checker txChecker, txIn *TxIn, spent *TxOut) error {
+	if err := checkKeyType(txIn, spent); err != nil {
+		return err
+	}
+	locking, err := spent.lockingScript()
+	if err != nil {
+		return err
+	}
+	unlocking, err := txIn.unlockingScript()
+	if err != nil {
+		return err
+	}
+	return script.Execute(unlocking, locking, checker)
+}
@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/josh3021/nomadcoin/script"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+func TestLockingScript(t *testing.T) {
+	_, pubKey := makeTestKey(t)
+	legacy := hex.EncodeToString(pubKey)
+	t.Run("Legacy address should lock with pay-to-pubkey.", func(t *testing.T) {
+		s, err := (&TxOut{Address: legacy}).lockingScript()
+		if want := legacy + " OP_CHECKSIG"; err != nil || s.Disassemble() != want {
+			t.Errorf("Expected %s, got %s (%v)", want, s.Disassemble(), err)
+		}
+	})
+	t.Run("Address should lock with pay-to-pubkey-hash.", func(t *testing.T) {
+		address, _ := wallet.AddressFromPublicKey(legacy)
+		s, err := (&TxOut{Address: address}).lockingScript()
+		want := fmt.Sprintf("OP_DUP OP_SHA256 %x OP_EQUALVERIFY OP_CHECKSIG", script.Hash(pubKey))
+		if err != nil || s.Disassemble() != want {
+			t.Errorf("Expected %s, got %s (%v)", want, s.Disassemble(), err)
+		}
+	})
+	t.Run("Multisig address should lock with multisig.", func(t *testing.T) {
+		_, otherPubKey := makeTestKey(t)
+		other := hex.EncodeToString(otherPubKey)
+		s, err := (&TxOut{Address: "multisig:1:" + legacy + ":" + other}).lockingScript()
+		if want := "OP_1 " + legacy + " " + other + " OP_2 OP_CHECKMULTISIG"; err != nil || s.Disassemble() != want {
+			t.Errorf("Expected %s, got %s (%v)", want, s.Disassemble(), err)
+		}
+	})
+	t.Run("Invalid address should not lock.", func(t *testing.T) {
+		if _, err := (&TxOut{Address: "winter"}).lockingScript(); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+	t.Run("Script should take precedence over address.", func(t *testing.T) {
+		s, err := (&TxOut{Address: "aabb", Script: "51"}).lockingScript()
+		if err != nil || s.Disassemble() != "OP_1" {
+			t.Errorf("Expected OP_1, got %s (%v)", s.Disassemble(), err)
+		}
+	})
+}
+
+func TestUnlockingScript(t *testing.T) {
+	s, err := (&TxIn{Signatures: []string{"aa", "", "cc"}}).unlockingScript()
+	if err != nil || s.Disassemble() != "aa cc" {
+		t.Errorf("Expected aa cc, got %s (%v)", s.Disassemble(), err)
+	}
+	s, err = (&TxIn{Signature: "aa", PublicKey: "bb"}).unlockingScript()
+	if err != nil || s.Disassemble() != "aa bb" {
+		t.Errorf("Expected aa bb, got %s (%v)", s.Disassemble(), err)
+	}
+}
+
+func TestVerifyTxIn(t *testing.T) {
+	once = *new(sync.Once)
+	dbStorage = memoryStorage(&blockchain{Height: 9, NewestHash: "x"})
+	hashLock, _ := script.HashLock(script.Hash([]byte("secret")))
+	timeLock, _ := script.NewBuilder().AddInt(10).AddOp(script.OpCheckLockTimeVerify).Script()
+	type test struct {
+		name   string
+		txIn   *TxIn
+		txOut  *TxOut
+		expect bool
+	}
+	privateKey, pubKey := makeTestKey(t)
+	address, _ := wallet.AddressFromPublicKey(hex.EncodeToString(pubKey))
+	tx := &Tx{ID: "aa"}
+	signature := hex.EncodeToString(signTestTx(t, tx, privateKey))
+	tests := []test{
+		{"signature and public key unlock address", &TxIn{Signature: signature, PublicKey: hex.EncodeToString(pubKey)}, &TxOut{Address: address}, true},
+		{"signature without public key does not unlock address", &TxIn{Signature: signature}, &TxOut{Address: address}, false},
+		{"preimage unlocks hash lock", &TxIn{Script: "06736563726574"}, &TxOut{Script: hashLock.String()}, true},
+		{"wrong preimage does not unlock hash lock", &TxIn{Script: "056775657373"}, &TxOut{Script: hashLock.String()}, false},
+		{"next block height unlocks time lock", &TxIn{}, &TxOut{Script: timeLock.String()}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyTxIn(tx, tc.txIn, tc.txOut)
+			if (err == nil) != tc.expect {
+				t.Errorf("Expected valid: %v, got %v", tc.expect, err)
+			}
+		})
+	}
+}
+
+func TestValidateTxOut(t *testing.T) {
+	data, _ := script.NullData([]byte("document hash"))
+	_, pubKey := makeTestKey(t)
+	legacy := hex.EncodeToString(pubKey)
+	address, _ := wallet.AddressFromPublicKey(legacy)
+	typo := []byte(address)
+	typo[len(typo)/2] ^= 'a' ^ 'b'
+	type test struct {
+		name  string
+		txOut *TxOut
+		want  bool
+	}
+	tests := []test{
+		{"address output", &TxOut{Address: address, Amount: 5}, true},
+		{"legacy address output", &TxOut{Address: legacy, Amount: 5}, true},
+		{"mistyped address output", &TxOut{Address: string(typo), Amount: 5}, false},
+		{"invalid address output", &TxOut{Address: "winter", Amount: 5}, false},
+		{"negative amount", &TxOut{Address: address, Amount: -5}, false},
+		{"data output", &TxOut{Script: data.String()}, true},
+		{"data output holding coins", &TxOut{Amount: 1, Script: data.String()}, false},
+		{"oversized data output", &TxOut{Script: "6a4c51" + strings.Repeat("00", script.MaxDataSize+1)}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := validateTxOut(tc.txOut); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTxOutData(t *testing.T) {
+	data, _ := script.NullData([]byte("document hash"))
+	txOut := &TxOut{Script: data.String()}
+	if txOut.Data() != "document hash" || !txOut.isUnspendable() {
+		t.Errorf("Expected unspendable output with data, got %q", txOut.Data())
+	}
+	if (&TxOut{Address: "aa"}).Data() != "" {
+		t.Error("Address outputs should not carry data")
+	}
+}
+
+// hardwareSigner emulates the secp256k1 hardware-signing tooling, which signs raw ECDSA r||s outside of any wallet,
+// here with crypto/ecdsa rather than the library the wallet signs with.
+type hardwareSigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func (h hardwareSigner) publicKey() string {
+	return "01" + hex.EncodeToString(encodeTestBigInts(h.privateKey.X, h.privateKey.Y))
+}
+
+func (h hardwareSigner) sign(t *testing.T, tx *Tx) string {
+	return "01" + hex.EncodeToString(signTestTx(t, tx, h.privateKey))
+}
+
+func TestVerifyTxInKeyTypes(t *testing.T) {
+	once = *new(sync.Once)
+	dbStorage = memoryStorage(&blockchain{Height: 9, NewestHash: "x"})
+	tx := &Tx{ID: "aa"}
+	secp256k1Key, err := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := hardwareSigner{secp256k1Key}
+	signerAddress, _ := wallet.AddressFromPublicKey(signer.publicKey())
+	edPublicKey, edPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	edPublic := "02" + hex.EncodeToString(edPublicKey)
+	edAddress, _ := wallet.AddressFromPublicKey(edPublic)
+	payload, _ := hex.DecodeString(tx.ID)
+	edSignature := "02" + hex.EncodeToString(ed25519.Sign(edPrivateKey, payload))
+	p256Key, p256PubKey := makeTestKey(t)
+	p256Signature := hex.EncodeToString(signTestTx(t, tx, p256Key))
+	tests := []struct {
+		name   string
+		txIn   *TxIn
+		txOut  *TxOut
+		expect bool
+	}{
+		{"hardware signer unlocks its secp256k1 address", &TxIn{Signature: signer.sign(t, tx), PublicKey: signer.publicKey()}, &TxOut{Address: signerAddress}, true},
+		{"untagged secp256k1 signature does not unlock", &TxIn{Signature: signer.sign(t, tx)[2:], PublicKey: signer.publicKey()}, &TxOut{Address: signerAddress}, false},
+		{"ed25519 key unlocks its address", &TxIn{Signature: edSignature, PublicKey: edPublic}, &TxOut{Address: edAddress}, true},
+		{"ed25519 signature tagged as secp256k1 does not unlock", &TxIn{Signature: "01" + edSignature[2:], PublicKey: edPublic}, &TxOut{Address: edAddress}, false},
+		{"p256 key does not unlock a secp256k1 address", &TxIn{Signature: p256Signature, PublicKey: hex.EncodeToString(p256PubKey)}, &TxOut{Address: signerAddress}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyTxIn(tx, tc.txIn, tc.txOut)
+			if (err == nil) != tc.expect {
+				t.Errorf("Expected valid: %v, got %v", tc.expect, err)
+			}
+		})
+	}
+	if err := verifyTxIn(tx, &TxIn{Signature: p256Signature, PublicKey: hex.EncodeToString(p256PubKey)}, &TxOut{Address: signerAddress}); err != errorKeyTypeMismatch {
+		t.Errorf("Expected %v, got %v", errorKeyTypeMismatch, err)
+	}
+}
+
+func TestVerifyTxInSchnorr(t *testing.T) {
+	once = *new(sync.Once)
+	dbStorage = memoryStorage(&blockchain{Height: 9, NewestHash: "x"})
+	// test vector 1 of BIP340
+	tx := &Tx{ID: "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89"}
+	publicKey := "03dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659"
+	signature := "036896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a"
+	address, err := wallet.AddressFromPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, ok := wallet.AddressPublicKey(address); !ok || held != publicKey {
+		t.Fatalf("Expected %s to hold %s, got %s", address, publicKey, held)
+	}
+	tests := []struct {
+		name   string
+		txIn   *TxIn
+		expect bool
+	}{
+		{"signature alone unlocks the Schnorr address", &TxIn{Signature: signature}, true},
+		{"altered signature does not unlock", &TxIn{Signature: signature[:len(signature)-2] + "0b"}, false},
+		{"signature tagged as ed25519 does not unlock", &TxIn{Signature: "02" + signature[2:]}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyTxIn(tx, tc.txIn, &TxOut{Address: address})
+			if (err == nil) != tc.expect {
+				t.Errorf("Expected valid: %v, got %v", tc.expect, err)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/josh3021/nomadcoin/script"
 	"github.com/josh3021/nomadcoin/utils"
 	"github.com/josh3021/nomadcoin/wallet"
 )
@@ -21,15 +23,19 @@ type Tx struct {
 
 // TxIn contains information of transactions input
 type TxIn struct {
-	TxID      string `json:"txId"`
-	Index     int    `json:"index"`
-	Signature string `json:"signature"`
+	TxID       string   `json:"txId"`
+	Index      int      `json:"index"`
+	Signature  string   `json:"signature"`
+	Signatures []string `json:"signatures,omitempty"`
+	PublicKey  string   `json:"publicKey,omitempty"`
+	Script     string   `json:"script,omitempty"`
 }
 
 // TxOut contains information of transactions Output
 type TxOut struct {
 	Address string `json:"address"`
 	Amount  int    `json:"amount"`
+	Script  string `json:"script,omitempty"`
 }
 
 // UTxOut contains information of Unconfirmed transactions Output
@@ -39,34 +45,152 @@ type UTxOut struct {
 	Amount int    `json:"amount"`
 }
 
+// txContents are the parts of a tx its ID commits to.
+type txContents struct {
+	Timestamp int
+	TxIns     []TxIn
+	TxOuts    []TxOut
+}
+
 func (tx *Tx) getID() {
-	tx.ID = utils.Hash(tx)
+	tx.ID = tx.hash()
 }
 
-func (tx *Tx) sign() {
+// hash returns the hash of tx without the unlocking data of its inputs, so signing does not change it.
+func (tx *Tx) hash() string {
+	contents := txContents{Timestamp: tx.Timestamp}
 	for _, txIn := range tx.TxIns {
-		txIn.Signature = wallet.Sign(tx.ID, wallet.Wallet())
+		if txIn.isCoinbase() {
+			contents.TxIns = append(contents.TxIns, *txIn)
+		} else {
+			contents.TxIns = append(contents.TxIns, TxIn{TxID: txIn.TxID, Index: txIn.Index})
+		}
 	}
+	for _, txOut := range tx.TxOuts {
+		contents.TxOuts = append(contents.TxOuts, *txOut)
+	}
+	return utils.Hash(contents)
+}
+
+func (txIn *TxIn) isCoinbase() bool {
+	return txIn.Signature == "COINBASE"
+}
+
+// keyStore is the part of a wallet txs are paid from and signed with.
+type keyStore interface {
+	Addresses() []string
+	ChangeAddress() string
+	UseChangeAddress()
+	SignFor(payload, address string) (string, error)
+	PublicKey(address string) (string, error)
+}
+
+// sign replaces the address placeholder of every input with its signature by the key of that address.
+func (tx *Tx) sign(w keyStore) error {
+	for _, txIn := range tx.TxIns {
+		address := txIn.Signature
+		signature, err := w.SignFor(tx.ID, address)
+		if err != nil {
+			return err
+		}
+		if _, ok := wallet.AddressPublicKey(address); !ok {
+			if txIn.PublicKey, err = w.PublicKey(address); err != nil {
+				return err
+			}
+		}
+		txIn.Signature = signature
+	}
+	return nil
 }
 
 type mempool struct {
 	// Txs []*Tx `json:"txs"`
 	Txs map[string]*Tx `json:"txs"`
-	m   sync.Mutex
+	// m guards Txs from checking that the coins of a tx are unspent until it is added.
+	// It is taken before the lock of the blockchain, which selecting coins takes.
+	m sync.Mutex
 }
 
 func (m *mempool) AddTx(to string, amount int) (*Tx, error) {
-	tx, err := makeTx(wallet.Wallet().Address, to, amount)
+	return m.AddTxFrom(wallet.Wallet(), to, amount, nil)
+}
+
+// AddTxFrom creates a tx paying amount from the wallet w, spending the outputs options choose.
+func (m *mempool) AddTxFrom(w keyStore, to string, amount int, options *TxOptions) (*Tx, error) {
+	if amount <= 0 {
+		return nil, errorInvalidAmount
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	tx, err := makeTx(w, options, &TxOut{Address: to, Amount: amount})
 	if err != nil {
 		return nil, err
 	}
 	// m.Txs = append(m.Txs, tx)
-	m.Txs[tx.ID] = tx
+	m.add(tx)
 	return tx, nil
 }
 
-func (m *mempool) ConfirmTxs() []*Tx {
-	coinbase := makeCoinbaseTx(wallet.Wallet().Address)
+// Recipient is an address and the amount a batch tx pays it.
+type Recipient struct {
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+// AddBatchTxFrom creates one tx paying every recipient from the wallet w, spending the outputs options choose.
+func (m *mempool) AddBatchTxFrom(w keyStore, recipients []*Recipient, options *TxOptions) (*Tx, error) {
+	if len(recipients) == 0 {
+		return nil, errorNoRecipients
+	}
+	var outputs []*TxOut
+	for i, recipient := range recipients {
+		if recipient.Amount <= 0 {
+			return nil, fmt.Errorf("recipient %d: %w", i, errorInvalidAmount)
+		}
+		if err := wallet.ValidateAddress(recipient.To); err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i, err)
+		}
+		outputs = append(outputs, &TxOut{Address: recipient.To, Amount: recipient.Amount})
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	tx, err := makeTx(w, options, outputs...)
+	if err != nil {
+		return nil, err
+	}
+	m.add(tx)
+	return tx, nil
+}
+
+// AddDataTx creates a tx anchoring data on chain, optionally paying amount to "to".
+func (m *mempool) AddDataTx(to string, amount int, data string) (*Tx, error) {
+	return m.AddDataTxFrom(wallet.Wallet(), to, amount, data, nil)
+}
+
+// AddDataTxFrom creates a tx of the wallet w anchoring data on chain, optionally paying amount to "to".
+func (m *mempool) AddDataTxFrom(w keyStore, to string, amount int, data string, options *TxOptions) (*Tx, error) {
+	dataScript, err := script.NullData([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	outputs := []*TxOut{{Amount: 0, Script: dataScript.String()}}
+	if to != "" {
+		outputs = append(outputs, &TxOut{Address: to, Amount: amount})
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	tx, err := makeTx(w, options, outputs...)
+	if err != nil {
+		return nil, err
+	}
+	m.add(tx)
+	return tx, nil
+}
+
+func (m *mempool) ConfirmTxs(height int) []*Tx {
+	m.m.Lock()
+	defer m.m.Unlock()
+	coinbase := makeCoinbaseTx(wallet.Wallet().Address, height)
 	var txs []*Tx
 	for _, tx := range m.Txs {
 		txs = append(txs, tx)
@@ -80,7 +204,13 @@ func (m *mempool) AddPeerTx(tx *Tx) {
 	m.m.Lock()
 	defer m.m.Unlock()
 	// m.Txs = append(m.Txs, tx)
+	m.add(tx)
+}
+
+// add puts tx in the mempool and records it as pending in the wallet ledger.
+func (m *mempool) add(tx *Tx) {
 	m.Txs[tx.ID] = tx
+	txLedger().addPending(tx)
 }
 
 // Mempool contains not confirmed transactions
@@ -117,61 +247,104 @@ Outer:
 }
 
 func validate(tx *Tx) bool {
-	valid := true
+	if tx.ID != tx.hash() {
+		return false
+	}
+	outputs := 0
+	for _, txOut := range tx.TxOuts {
+		if !validateTxOut(txOut) {
+			return false
+		}
+		outputs += txOut.Amount
+	}
+	inputs := 0
+	spent := make(map[string]bool)
+	var checks []*txInCheck
 	for _, txIn := range tx.TxIns {
-		prevTx := FindTx(Blockchain(), txIn.TxID)
-		if prevTx == nil {
-			valid = false
-			break
+		key := outpoint(txIn.TxID, txIn.Index)
+		if spent[key] || isSpent(Blockchain(), txIn.TxID, txIn.Index) {
+			return false
 		}
-		address := prevTx.TxOuts[txIn.Index].Address
-		valid = wallet.Verify(txIn.Signature, tx.ID, address)
-		if !valid {
-			break
+		spent[key] = true
+		prevTx := FindTx(Blockchain(), txIn.TxID)
+		if prevTx == nil || txIn.Index < 0 || txIn.Index >= len(prevTx.TxOuts) {
+			return false
 		}
+		prevTxOut := prevTx.TxOuts[txIn.Index]
+		checks = append(checks, &txInCheck{tx: tx, txIn: txIn, spent: prevTxOut, height: Blockchain().Height + 1})
+		inputs += prevTxOut.Amount
 	}
-	return valid
+	return len(tx.TxIns) > 0 && inputs >= outputs && verifyTxIns(checks) == nil
 }
 
 var errorNotEnoghMoney = errors.New("not enough money")
 var errorTxNotValid = errors.New("tx not valid")
+var errorNoRecipients = errors.New("no recipients")
+var errorInvalidAmount = errors.New("amount has to be positive")
+
+// makeTx returns a signed tx paying outputs from the wallet w, spending the outputs options choose.
+func makeTx(w keyStore, options *TxOptions, outputs ...*TxOut) (*Tx, error) {
+	change := w.ChangeAddress()
+	tx, err := buildTx(w.Addresses(), change, options, outputs...)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.sign(w); err != nil {
+		return nil, err
+	}
+	if !validate(tx) {
+		return nil, errorTxNotValid
+	}
+	useChangeAddress(w, tx, change)
+	return tx, nil
+}
 
-func makeTx(from, to string, amount int) (*Tx, error) {
-	if GetBalanceByAddress(Blockchain(), from) < amount {
-		return nil, errorNotEnoghMoney
+// buildTx returns an unsigned tx paying outputs from the outputs of the addresses in "from" that options choose,
+// sending the change to "change" unless it is dust. Every input holds the address it spends as a placeholder signature.
+func buildTx(from []string, change string, options *TxOptions, outputs ...*TxOut) (*Tx, error) {
+	amount := 0
+	for _, output := range outputs {
+		amount += output.Amount
+	}
+	coins, err := selectCoins(walletCoins(from), amount, options)
+	if err != nil {
+		return nil, err
 	}
 	var txIns []*TxIn
 	var txOuts []*TxOut
 	total := 0
-	uTxOuts := UTxOutsByAddress(Blockchain(), from)
-	for _, uTxOut := range uTxOuts {
-		if total >= amount {
-			break
-		}
-		txIn := &TxIn{TxID: uTxOut.TxID, Index: uTxOut.Index, Signature: from}
-		txIns = append(txIns, txIn)
-		total += uTxOut.Amount
+	for _, c := range coins {
+		txIns = append(txIns, &TxIn{TxID: c.TxID, Index: c.Index, Signature: c.address})
+		total += c.Amount
 	}
 
 	// 거스름돈
-	if change := total - amount; change != 0 {
-		changeTxOut := &TxOut{Address: from, Amount: change}
+	if changeAmount := total - amount; changeAmount >= DustThreshold {
+		changeTxOut := &TxOut{Address: change, Amount: changeAmount}
 		txOuts = append(txOuts, changeTxOut)
 	}
-	txOut := &TxOut{Address: to, Amount: amount}
-	txOuts = append(txOuts, txOut)
+	txOuts = append(txOuts, outputs...)
 	tx := &Tx{ID: "", Timestamp: int(time.Now().Unix()), TxIns: txIns, TxOuts: txOuts}
 	tx.getID()
-	tx.sign()
-	if !validate(tx) {
-		return nil, errorTxNotValid
-	}
 	return tx, nil
 }
 
-func makeCoinbaseTx(address string) *Tx {
+// useChangeAddress hands out the change address of the wallet if tx pays to it.
+func useChangeAddress(w keyStore, tx *Tx, change string) {
+	for _, txOut := range tx.TxOuts {
+		if txOut.Address == change {
+			w.UseChangeAddress()
+			return
+		}
+	}
+}
+
+func makeCoinbaseTx(address string, height int) *Tx {
+	// the height makes coinbase txs of the same miner in the same second unique
+	heightScript, err := script.NewBuilder().AddInt(int64(height)).Script()
+	utils.HandleErr(err)
 	txIns := []*TxIn{
-		{Signature: "COINBASE", TxID: "", Index: -1},
+		{Signature: "COINBASE", TxID: "", Index: -1, Script: heightScript.String()},
 	}
 	txOuts := []*TxOut{
 		{Address: address, Amount: minerReward},
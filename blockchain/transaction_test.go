@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// importTestWallet loads a named wallet of a key from makeTestKey, and returns it with its address.
+func importTestWallet(t *testing.T, name string) (keyStore, string) {
+	privateKey, _ := makeTestKey(t)
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := wallet.Manager().ImportKey(name, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { wallet.Manager().Unload(name) })
+	return w, w.Address
+}
+
+func TestAddTxFrom(t *testing.T) {
+	_, pubKey := makeTestKey(t)
+	address, _ := wallet.AddressFromPublicKey(hex.EncodeToString(pubKey))
+	for _, amount := range []int{0, -10} {
+		if _, err := Mempool().AddTxFrom(nil, address, amount, nil); err != errorInvalidAmount {
+			t.Errorf("Expected %v for %d, got %v", errorInvalidAmount, amount, err)
+		}
+	}
+}
+
+func TestAddBatchTx(t *testing.T) {
+	_, pubKey := makeTestKey(t)
+	address, _ := wallet.AddressFromPublicKey(hex.EncodeToString(pubKey))
+	tests := []struct {
+		name       string
+		recipients []*Recipient
+		err        error
+	}{
+		{"no recipients", nil, errorNoRecipients},
+		{"zero amount", []*Recipient{{To: address, Amount: 10}, {To: address, Amount: 0}}, errorInvalidAmount},
+		{"invalid address", []*Recipient{{To: "winter", Amount: 10}}, wallet.ErrInvalidAddress},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Mempool().AddBatchTxFrom(nil, test.recipients, nil)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentSends(t *testing.T) {
+	w, address := importTestWallet(t, "concurrent")
+	_, pubKey := makeTestKey(t)
+	stranger := hex.EncodeToString(pubKey)
+	coinbase := makeCoinbaseTx(address, 1)
+	genesis := &Block{Hash: "0000c1", Height: 1, Difficulty: defaultDifficulty, Transactions: []*Tx{coinbase}}
+	once = *new(sync.Once)
+	dbStorage = memoryStorage(&blockchain{NewestHash: genesis.Hash, Height: 1, CurrentDifficulty: defaultDifficulty}, genesis)
+	Mempool().Txs = make(map[string]*Tx)
+	defer func() { Mempool().Txs = make(map[string]*Tx) }()
+
+	var wg sync.WaitGroup
+	sent := make(chan *Tx, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tx, err := Mempool().AddTxFrom(w, stranger, 30, nil); err == nil {
+				sent <- tx
+			}
+		}()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Mempool().AddPeerTx(&Tx{ID: fmt.Sprintf("peer%d", i)})
+		}(i)
+	}
+	wg.Wait()
+	close(sent)
+	if len(sent) != 1 {
+		t.Errorf("Expected the coin of 50 to pay one send of 30, got %d sends", len(sent))
+	}
+}
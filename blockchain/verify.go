@@ -0,0 +1,210 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// maxSigCacheEntries bounds the signature cache, at 32 bytes an entry plus the map overhead, a few MB.
+// It holds the signatures of many more txs than a mempool and the blocks that confirm them, so entries
+// are evicted at random rather than in LRU order: a miss only costs verifying the signature again,
+// and a peer can not choose which entries go.
+const maxSigCacheEntries int = 100000
+
+// signatureCache remembers signatures that verified, keyed by the hash of their sighash, public key and signature.
+// A signature checked when its tx entered the mempool is not checked again when a block confirms the tx.
+// Only valid signatures are cached, so a peer can not fill the cache with garbage that later verifies.
+type signatureCache struct {
+	entries map[[sha256.Size]byte]struct{}
+	m       sync.RWMutex
+}
+
+var sigCache = newSignatureCache()
+
+func newSignatureCache() *signatureCache {
+	return &signatureCache{entries: make(map[[sha256.Size]byte]struct{})}
+}
+
+func sigCacheKey(sighash string, signature, pubKey []byte) [sha256.Size]byte {
+	h := sha256.New()
+	length := make([]byte, binary.MaxVarintLen64)
+	for _, part := range [][]byte{[]byte(sighash), signature, pubKey} {
+		h.Write(length[:binary.PutUvarint(length, uint64(len(part)))])
+		h.Write(part)
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func (c *signatureCache) contains(key [sha256.Size]byte) bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+// add caches key, evicting an entry at random, the first one map iteration yields, once the cache is full.
+func (c *signatureCache) add(key [sha256.Size]byte) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if len(c.entries) >= maxSigCacheEntries {
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+	c.entries[key] = struct{}{}
+}
+
+// verify reports whether signature is a signature of sighash by pubKey, verifying it only if it is not cached.
+// With legacy set, the unpadded signatures of old txs verify too, but are not cached, so that a cached signature
+// is always in the one encoding new txs may use.
+func (c *signatureCache) verify(sighash string, signature, pubKey []byte, legacy bool) bool {
+	key := sigCacheKey(sighash, signature, pubKey)
+	if c.contains(key) {
+		return true
+	}
+	if wallet.Verify(hex.EncodeToString(signature), sighash, hex.EncodeToString(pubKey)) {
+		c.add(key)
+		return true
+	}
+	return legacy && wallet.VerifyLegacy(hex.EncodeToString(signature), sighash, hex.EncodeToString(pubKey))
+}
+
+// txInCheck is an input to verify, with the output it spends and the height of the block of its tx.
+// Inputs of txs in blocks are legacy, as they can be signed before signatures had a fixed width.
+type txInCheck struct {
+	tx     *Tx
+	txIn   *TxIn
+	spent  *TxOut
+	height int
+	legacy bool
+}
+
+// verifyTxIns verifies checks on a pool of one worker per CPU, and returns the error of a failed check.
+// Once a check fails the remaining ones are skipped.
+func verifyTxIns(checks []*txInCheck) error {
+	workers := runtime.NumCPU()
+	if workers > len(checks) {
+		workers = len(checks)
+	}
+	jobs := make(chan *txInCheck)
+	var failed int32
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for check := range jobs {
+				if atomic.LoadInt32(&failed) == 1 {
+					continue
+				}
+				if err := runScripts(txChecker{tx: check.tx, height: check.height, legacy: check.legacy}, check.txIn, check.spent); err != nil {
+					once.Do(func() { firstErr = err })
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for _, check := range checks {
+		jobs <- check
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// verifyBlocks verifies every tx of blocks at once: its ID is its hash, its outputs are valid, its inputs spend
+// existing outputs that no other input of blocks or of the chain spends, they add up to at least its outputs
+// unless it is a coinbase tx, and their signatures verify. The outputs they spend are either in blocks or in
+// the txs chainTxs returns, which is only called if blocks spend outputs from outside of them.
+// Signatures only cover the ID of a tx, so a tx whose ID is not its hash is rejected before its inputs are.
+func verifyBlocks(blocks []*Block, chainTxs func() []*Tx) error {
+	txs := make(map[string]*Tx)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if tx.ID != tx.hash() {
+				return errorTxNotValid
+			}
+			txs[tx.ID] = tx
+		}
+	}
+	spent := make(map[string]bool)
+	spend := func(txIn *TxIn) error {
+		key := outpoint(txIn.TxID, txIn.Index)
+		if spent[key] {
+			return errorTxNotValid
+		}
+		spent[key] = true
+		return nil
+	}
+	// loadChain adds the txs of the chain, and the outputs they spend, once an input needs them
+	loadChain := func() error {
+		if chainTxs == nil {
+			return nil
+		}
+		onChain := chainTxs()
+		chainTxs = nil
+		for _, chainTx := range onChain {
+			if _, ok := txs[chainTx.ID]; !ok {
+				txs[chainTx.ID] = chainTx
+			}
+			for _, txIn := range chainTx.TxIns {
+				if txIn.isCoinbase() {
+					continue
+				}
+				if err := spend(txIn); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	var checks []*txInCheck
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			outputs := 0
+			for _, txOut := range tx.TxOuts {
+				if !validateTxOut(txOut) {
+					return errorTxNotValid
+				}
+				outputs += txOut.Amount
+			}
+			inputs, coinbase := 0, false
+			for _, txIn := range tx.TxIns {
+				if txIn.isCoinbase() {
+					coinbase = true
+					continue
+				}
+				prevTx, ok := txs[txIn.TxID]
+				if !ok && chainTxs != nil {
+					if err := loadChain(); err != nil {
+						return err
+					}
+					prevTx, ok = txs[txIn.TxID]
+				}
+				if !ok || txIn.Index < 0 || txIn.Index >= len(prevTx.TxOuts) {
+					return errorTxNotValid
+				}
+				if err := spend(txIn); err != nil {
+					return err
+				}
+				inputs += prevTx.TxOuts[txIn.Index].Amount
+				checks = append(checks, &txInCheck{tx: tx, txIn: txIn, spent: prevTx.TxOuts[txIn.Index], height: block.Height, legacy: true})
+			}
+			if !coinbase && inputs < outputs {
+				return errorTxNotValid
+			}
+		}
+	}
+	return verifyTxIns(checks)
+}
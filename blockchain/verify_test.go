@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// makeSignedBlock returns a block of n txs, each spending one output of a coinbase tx with a P256 signature.
+func makeSignedBlock(t testing.TB, n int) (*Block, *Tx) {
+	privateKey, pubKey := makeTestKey(t)
+	address := hex.EncodeToString(pubKey)
+	coinbase := &Tx{TxIns: []*TxIn{{Signature: "COINBASE"}}}
+	for i := 0; i < n; i++ {
+		coinbase.TxOuts = append(coinbase.TxOuts, &TxOut{Address: address, Amount: 1})
+	}
+	coinbase.getID()
+	block := &Block{Height: 2}
+	for i := 0; i < n; i++ {
+		tx := &Tx{Timestamp: i, TxIns: []*TxIn{{TxID: coinbase.ID, Index: i}}, TxOuts: []*TxOut{{Address: address, Amount: 1}}}
+		tx.getID()
+		tx.TxIns[0].Signature = hex.EncodeToString(signTestTx(t, tx, privateKey))
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block, coinbase
+}
+
+// spendTestTx returns a tx spending the output at index of prevTx to address, signed with privateKey.
+// Txs spending the same output differ by their timestamp.
+func spendTestTx(t testing.TB, privateKey *ecdsa.PrivateKey, prevTx *Tx, index int, address string, amount, timestamp int) *Tx {
+	tx := &Tx{Timestamp: timestamp, TxIns: []*TxIn{{TxID: prevTx.ID, Index: index}}, TxOuts: []*TxOut{{Address: address, Amount: amount}}}
+	tx.getID()
+	tx.TxIns[0].Signature = hex.EncodeToString(signTestTx(t, tx, privateKey))
+	return tx
+}
+
+func TestVerifyBlocks(t *testing.T) {
+	block, coinbase := makeSignedBlock(t, 20)
+	genesis := &Block{Height: 1, Transactions: []*Tx{coinbase}}
+	t.Run("Signed blocks should verify.", func(t *testing.T) {
+		if err := verifyBlocks([]*Block{block, genesis}, nil); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		chainTxs := func() []*Tx { return []*Tx{coinbase} }
+		if err := verifyBlocks([]*Block{block}, chainTxs); err != nil {
+			t.Errorf("Expected the spent outputs to be found on chain, got %v", err)
+		}
+	})
+	t.Run("Chain txs should only be loaded when needed.", func(t *testing.T) {
+		chainTxs := func() []*Tx {
+			t.Error("Expected the chain not to be loaded")
+			return nil
+		}
+		if err := verifyBlocks([]*Block{genesis}, chainTxs); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+	t.Run("Blocks spending unknown outputs should not verify.", func(t *testing.T) {
+		if err := verifyBlocks([]*Block{block}, nil); err != errorTxNotValid {
+			t.Errorf("Expected %v, got %v", errorTxNotValid, err)
+		}
+	})
+	t.Run("Txs whose outputs were swapped should not verify.", func(t *testing.T) {
+		_, thief := makeTestKey(t)
+		tx := block.Transactions[7]
+		txOuts := tx.TxOuts
+		tx.TxOuts = []*TxOut{{Address: hex.EncodeToString(thief), Amount: 1}}
+		defer func() { tx.TxOuts = txOuts }()
+		if err := verifyBlocks([]*Block{block, genesis}, nil); err != errorTxNotValid {
+			t.Errorf("Expected %v, got %v", errorTxNotValid, err)
+		}
+	})
+	t.Run("Txs breaking the ledger should not verify.", func(t *testing.T) {
+		privateKey, pubKey := makeTestKey(t)
+		address := hex.EncodeToString(pubKey)
+		coinbase := &Tx{TxIns: []*TxIn{{Signature: "COINBASE"}}, TxOuts: []*TxOut{{Address: address, Amount: 10}, {Address: address, Amount: 10}}}
+		coinbase.getID()
+		genesis := &Block{Height: 1, Transactions: []*Tx{coinbase}}
+		first := spendTestTx(t, privateKey, coinbase, 0, address, 10, 1)
+		second := spendTestTx(t, privateKey, coinbase, 0, address, 10, 2)
+		tests := []struct {
+			name     string
+			blocks   []*Block
+			chainTxs []*Tx
+		}{
+			{"double spend in a block", []*Block{{Height: 2, Transactions: []*Tx{first, second}}, genesis}, nil},
+			{"double spend across blocks", []*Block{{Height: 3, Transactions: []*Tx{second}}, {Height: 2, Transactions: []*Tx{first}}, genesis}, nil},
+			{"double spend of the chain", []*Block{{Height: 3, Transactions: []*Tx{second}}}, []*Tx{coinbase, first}},
+			{"outputs above inputs", []*Block{{Height: 2, Transactions: []*Tx{spendTestTx(t, privateKey, coinbase, 1, address, 11, 3)}}, genesis}, nil},
+			{"negative output", []*Block{{Height: 2, Transactions: []*Tx{spendTestTx(t, privateKey, coinbase, 1, address, -1, 4)}}, genesis}, nil},
+			{"invalid address", []*Block{{Height: 2, Transactions: []*Tx{spendTestTx(t, privateKey, coinbase, 1, "winter", 10, 5)}}, genesis}, nil},
+		}
+		for _, test := range tests {
+			var chainTxs func() []*Tx
+			if test.chainTxs != nil {
+				chainTxs = func() []*Tx { return test.chainTxs }
+			}
+			if err := verifyBlocks(test.blocks, chainTxs); err != errorTxNotValid {
+				t.Errorf("Expected %v for a %s, got %v", errorTxNotValid, test.name, err)
+			}
+		}
+		fee := spendTestTx(t, privateKey, coinbase, 1, address, 9, 6)
+		if err := verifyBlocks([]*Block{{Height: 2, Transactions: []*Tx{first, fee}}, genesis}, nil); err != nil {
+			t.Errorf("Expected txs paying a fee to verify, got %v", err)
+		}
+	})
+	t.Run("A bad signature should fail the whole batch.", func(t *testing.T) {
+		txIn := block.Transactions[13].TxIns[0]
+		signature := txIn.Signature
+		txIn.Signature = block.Transactions[12].TxIns[0].Signature
+		defer func() { txIn.Signature = signature }()
+		if err := verifyBlocks([]*Block{block, genesis}, nil); err == nil {
+			t.Error("Expected ERROR, got nil")
+		}
+		bc := &blockchain{Height: 1, NewestHash: "test"}
+		if err := bc.Replace([]*Block{block, genesis}); err == nil || bc.Height != 1 || bc.NewestHash != "test" {
+			t.Errorf("Expected the chain to be kept, got %v", err)
+		}
+	})
+}
+
+func TestSignatureCache(t *testing.T) {
+	sigCache = newSignatureCache()
+	block, coinbase := makeSignedBlock(t, 1)
+	tx := block.Transactions[0]
+	signature, _ := hex.DecodeString(tx.TxIns[0].Signature)
+	pubKey, _ := hex.DecodeString(coinbase.TxOuts[0].Address)
+	if !sigCache.verify(tx.ID, signature, pubKey, false) {
+		t.Fatal("Expected the signature to verify")
+	}
+	if !sigCache.contains(sigCacheKey(tx.ID, signature, pubKey)) {
+		t.Error("Expected a valid signature to be cached")
+	}
+	if sigCache.verify(coinbase.ID, signature, pubKey, false) {
+		t.Error("Expected the signature not to verify another sighash")
+	}
+	if len(sigCache.entries) != 1 {
+		t.Errorf("Expected only valid signatures to be cached, got %d entries", len(sigCache.entries))
+	}
+	if sigCacheKey("ab", []byte{0xcd}, nil) == sigCacheKey("abcd", nil, nil) {
+		t.Error("Expected the parts of a key not to run into each other")
+	}
+	t.Run("Unpadded signatures should only verify as legacy, uncached.", func(t *testing.T) {
+		privateKey, pubKey := makeTestKey(t)
+		payload, _ := hex.DecodeString(tx.ID)
+		var unpadded []byte
+		for len(unpadded) == 0 || len(unpadded) == 2*utils.BigIntSize {
+			r, s, err := ecdsa.Sign(rand.Reader, privateKey, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unpadded = append(r.Bytes(), s.Bytes()...)
+		}
+		if sigCache.verify(tx.ID, unpadded, pubKey, false) {
+			t.Error("Expected an unpadded signature not to verify for new txs")
+		}
+		if !sigCache.verify(tx.ID, unpadded, pubKey, true) {
+			t.Error("Expected an unpadded signature to verify as legacy")
+		}
+		if sigCache.contains(sigCacheKey(tx.ID, unpadded, pubKey)) {
+			t.Error("Expected an unpadded signature not to be cached")
+		}
+	})
+}
+
+func BenchmarkVerifyBlocks(b *testing.B) {
+	for _, n := range []int{1, 100} {
+		block, coinbase := makeSignedBlock(b, n)
+		blocks := []*Block{block, {Height: 1, Transactions: []*Tx{coinbase}}}
+		b.Run(fmt.Sprintf("sequential/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sigCache = newSignatureCache()
+				for _, tx := range block.Transactions {
+					if err := verifyTxInAt(tx, tx.TxIns[0], coinbase.TxOuts[tx.TxIns[0].Index], block.Height); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("parallel/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sigCache = newSignatureCache()
+				if err := verifyBlocks(blocks, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("cached/%d", n), func(b *testing.B) {
+			sigCache = newSignatureCache()
+			for i := 0; i < b.N; i++ {
+				if err := verifyBlocks(blocks, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/josh3021/nomadcoin/blockchain"
+	"github.com/josh3021/nomadcoin/datadir"
+	"github.com/josh3021/nomadcoin/db"
 	"github.com/josh3021/nomadcoin/explorer"
 	"github.com/josh3021/nomadcoin/rest"
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
 )
 
 func usage() {
@@ -14,23 +19,110 @@ func usage() {
 	fmt.Printf("Please use the following flags:\n\n")
 	fmt.Printf("-restPort:		Sets the \"port\" of the REST API SERVER.\n")
 	fmt.Printf("-htmlPort:		Sets the \"port\" of the HTML EXPLORER SERVER.\n")
-	fmt.Printf("-mode:		Choose between \"html\" and \"rest\" and \"both\".\n\n")
+	fmt.Printf("-mode:		Choose between \"html\" and \"rest\" and \"both\".\n")
+	fmt.Printf("-network:		Choose between \"mainnet\" and \"testnet\" addresses.\n")
+	fmt.Printf("-datadir:		Keeps the database and wallets in a \"directory\", one per network.\n")
+	fmt.Printf("-dbbackend:		Choose between \"bolt\", \"leveldb\" and \"memory\" to store the blockchain.\n")
+	fmt.Printf("-keyType:		Choose between \"p256\", \"secp256k1\", \"ed25519\" and \"schnorr\" keys for new wallets.\n")
+	fmt.Printf("-encryptWallet:	Encrypts the wallet file with a passphrase.\n")
+	fmt.Printf("-unlock:		Unlocks the wallet for a duration (e.g. \"10m\").\n\n")
+	fmt.Printf("Or one of the following commands:\n\n")
+	fmt.Printf("sign-message:		Signs a message with a key of the wallet.\n")
+	fmt.Printf("verify-message:		Verifies a signed message.\n")
+	fmt.Printf("export-key:		Prints the public or private key of an address of the wallet.\n")
+	fmt.Printf("import-key:		Creates a named wallet of a private key in PEM or WIF.\n")
+	fmt.Printf("sign-offline:		Signs an unsigned tx with a wallet file, without the database or network.\n")
+	fmt.Printf("repair:			Rewinds a damaged blockchain database to its last valid block.\n\n")
 	os.Exit(0)
 }
 
+// dataDir returns the directory of network in the data directory root, or the working directory
+// if root is empty, and keeps the wallets there.
+func dataDir(root, network string, port int) datadir.Dir {
+	dir := datadir.Legacy(port)
+	if root != "" {
+		var err error
+		dir, err = datadir.New(root, network)
+		utils.HandleErr(err)
+	}
+	wallet.SetDir(dir.Wallets())
+	return dir
+}
+
 // Start CLI.
 func Start() {
 	if len(os.Args) == 1 {
 		usage()
 	}
 
+	switch os.Args[1] {
+	case "sign-message":
+		signMessage(os.Args[2:])
+		return
+	case "verify-message":
+		verifyMessage(os.Args[2:])
+		return
+	case "export-key":
+		exportKey(os.Args[2:])
+		return
+	case "import-key":
+		importKey(os.Args[2:])
+		return
+	case "sign-offline":
+		signOffline(os.Args[2:])
+		return
+	case "repair":
+		repair(os.Args[2:])
+		return
+	}
+
 	// rest := flag.NewFlagSet("rest", flag.ExitOnError)
 	// portFlag := rest.Int("port", 4000, "Sets the port of the server")
 	restPort := flag.Int("restPort", 4000, "Sets the \"port\" of the REST API SERVER.")
 	htmlPort := flag.Int("htmlPort", 3000, "Sets the \"port\" of the HTML EXPLORER SERVER.")
 	mode := flag.String("mode", "both", "Sets the \"mode\" of the server.")
+	network := flag.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses.")
+	root := flag.String("datadir", "", "Sets the data \"directory\" (the working directory by default).")
+	dbBackend := flag.String("dbbackend", "bolt", "Sets the storage \"backend\" of the blockchain.")
+	keyType := flag.String("keyType", string(wallet.P256), "Sets the \"key type\" of new wallets.")
+	encryptWallet := flag.Bool("encryptWallet", false, "Encrypts the wallet file with a passphrase.")
+	unlock := flag.Duration("unlock", 0, "Unlocks the wallet for a duration.")
 	flag.Parse()
 
+	if err := wallet.SetNetwork(*network); err != nil {
+		usage()
+	}
+	if err := wallet.SetKeyType(*keyType); err != nil {
+		usage()
+	}
+	if err := db.SetBackend(*dbBackend); err != nil {
+		usage()
+	}
+	dir := dataDir(*root, *network, *restPort)
+
+	if *encryptWallet {
+		passphrase := readPassphrase("New passphrase: ")
+		if readPassphrase("Repeat passphrase: ") != passphrase {
+			fmt.Println("Passphrases do not match.")
+			os.Exit(1)
+		}
+		utils.HandleErr(wallet.Wallet().Encrypt(passphrase))
+		fmt.Println("Wallet encrypted.")
+	}
+	if *unlock > 0 {
+		utils.HandleErr(wallet.Wallet().Unlock(readPassphrase("Passphrase: "), *unlock))
+	}
+
+	// only the node and repair open the database, other commands must not touch it
+	defer db.Close()
+	db.InitDB(dir.ChainDB())
+	if report := blockchain.CheckIntegrity(); !report.OK() {
+		fmt.Println(string(utils.ToJSON(report)))
+		fmt.Println("The blockchain database is damaged, run \"repair\" with the same flags to rewind it to its last valid block.")
+		db.Close()
+		os.Exit(1)
+	}
+
 	switch *mode {
 	case "both":
 		go rest.Start(*restPort)
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
+	"golang.org/x/term"
+)
+
+const exportConfirmation string = "export"
+
+// exportKey prints the public key, or after a confirmation the private key, of an address of the node wallet or of a named wallet.
+func exportKey(args []string) {
+	command := flag.NewFlagSet("export-key", flag.ExitOnError)
+	address := command.String("address", "", "Exports the key of this \"address\" (the wallet address by default).")
+	format := command.String("format", string(wallet.WIF), "Sets the \"format\" of the private key, \"pem\" or \"wif\".")
+	public := command.Bool("public", false, "Exports the public key instead of the private key.")
+	yes := command.Bool("yes", false, "Exports the private key without asking for a confirmation.")
+	name := command.String("wallet", "", "Exports from the named \"wallet\" instead of the node wallet.")
+	root := command.String("datadir", "", "Reads the wallets from the data \"directory\" (the working directory by default).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses and keys.")
+	utils.HandleErr(command.Parse(args))
+	keyFormat, err := wallet.ParseKeyFormat(*format)
+	if err != nil || wallet.SetNetwork(*network) != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+	dataDir(*root, *network, 0)
+
+	w := wallet.Wallet()
+	if *name != "" {
+		loaded, err := wallet.Manager().Load(*name)
+		utils.HandleErr(err)
+		w = loaded
+	}
+	if *address == "" {
+		*address = w.Address
+	}
+	if *public {
+		publicKey, err := w.PublicKey(*address)
+		utils.HandleErr(err)
+		fmt.Println(publicKey)
+		return
+	}
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "Whoever holds the private key of %s can spend its coins.\n", *address)
+		fmt.Fprintf(os.Stderr, "Type %q to print it: ", exportConfirmation)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != exportConfirmation {
+			fmt.Fprintln(os.Stderr, "Not exported.")
+			os.Exit(1)
+		}
+	}
+	if w.IsLocked() {
+		utils.HandleErr(w.Unlock(readPassphrase("Passphrase: "), 0))
+	}
+	privateKey, err := w.ExportPrivateKey(*address, keyFormat)
+	utils.HandleErr(err)
+	fmt.Println(strings.TrimSpace(privateKey))
+}
+
+// importKey creates a named wallet of a private key in PEM or WIF, read from a file, from stdin, or typed in without echo.
+func importKey(args []string) {
+	command := flag.NewFlagSet("import-key", flag.ExitOnError)
+	name := command.String("wallet", "", "Sets the \"name\" of the new wallet.")
+	file := command.String("file", "", "Reads the private key from this \"file\" instead of stdin.")
+	encrypt := command.Bool("encrypt", false, "Encrypts the new wallet file with a passphrase.")
+	root := command.String("datadir", "", "Reads the wallets from the data \"directory\" (the working directory by default).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses and keys.")
+	utils.HandleErr(command.Parse(args))
+	if *name == "" || wallet.SetNetwork(*network) != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+	dataDir(*root, *network, 0)
+
+	var privateKey string
+	switch {
+	case *file != "":
+		keyBytes, err := os.ReadFile(*file)
+		utils.HandleErr(err)
+		privateKey = string(keyBytes)
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		privateKey = readPassphrase("Private key (WIF): ")
+	default:
+		keyBytes, err := io.ReadAll(os.Stdin)
+		utils.HandleErr(err)
+		privateKey = string(keyBytes)
+	}
+	passphrase := ""
+	if *encrypt {
+		passphrase = readPassphrase("New passphrase: ")
+		if readPassphrase("Repeat passphrase: ") != passphrase {
+			fmt.Println("Passphrases do not match.")
+			os.Exit(1)
+		}
+	}
+	imported, err := wallet.Manager().ImportKey(*name, privateKey, passphrase)
+	utils.HandleErr(err)
+	fmt.Printf("Imported %s into the wallet %s.\n", imported.Address, *name)
+}
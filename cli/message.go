@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// signMessage signs a message with a key of the node wallet, or of a named wallet, and prints it as JSON.
+func signMessage(args []string) {
+	command := flag.NewFlagSet("sign-message", flag.ExitOnError)
+	address := command.String("address", "", "Signs with the key of this \"address\" (the wallet address by default).")
+	message := command.String("message", "", "Sets the \"message\" to sign.")
+	name := command.String("wallet", "", "Signs with the named \"wallet\" instead of the node wallet.")
+	root := command.String("datadir", "", "Reads the wallets from the data \"directory\" (the working directory by default).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses.")
+	utils.HandleErr(command.Parse(args))
+	if err := wallet.SetNetwork(*network); err != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+	dataDir(*root, *network, 0)
+
+	w := wallet.Wallet()
+	if *name != "" {
+		loaded, err := wallet.Manager().Load(*name)
+		utils.HandleErr(err)
+		w = loaded
+	}
+	if w.IsLocked() {
+		utils.HandleErr(w.Unlock(readPassphrase("Passphrase: "), 0))
+	}
+	signed, err := w.SignMessage(*address, *message)
+	utils.HandleErr(err)
+	fmt.Println(string(utils.ToJSON(signed)))
+}
+
+// verifyMessage checks a signed message and exits with 1 if it is not valid.
+func verifyMessage(args []string) {
+	command := flag.NewFlagSet("verify-message", flag.ExitOnError)
+	var signed wallet.SignedMessage
+	command.StringVar(&signed.Address, "address", "", "Sets the \"address\" that signed the message.")
+	command.StringVar(&signed.Message, "message", "", "Sets the signed \"message\".")
+	command.StringVar(&signed.Signature, "signature", "", "Sets the \"signature\" of the message.")
+	command.StringVar(&signed.PublicKey, "publicKey", "", "Sets the \"public key\" of the address (not needed for legacy addresses).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses.")
+	utils.HandleErr(command.Parse(args))
+	if err := wallet.SetNetwork(*network); err != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+
+	if err := wallet.VerifyMessage(&signed); err != nil {
+		fmt.Printf("Invalid: %s.\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Valid.")
+}
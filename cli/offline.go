@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/josh3021/nomadcoin/blockchain"
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// unsignedTx is what POST /transactions/build responds, an unsigned tx with the outputs it spends.
+type unsignedTx struct {
+	Tx    *blockchain.Tx           `json:"tx"`
+	Spent []*blockchain.SpentTxOut `json:"spent"`
+}
+
+// signOffline signs an unsigned tx with a local wallet file, without the database or the network,
+// and writes the signed tx to a file to submit on a node.
+func signOffline(args []string) {
+	command := flag.NewFlagSet("sign-offline", flag.ExitOnError)
+	in := command.String("in", "", "Reads the unsigned tx and the outputs it spends from this \"file\".")
+	out := command.String("out", "", "Writes the signed tx to this \"file\".")
+	name := command.String("wallet", "", "Signs with the named \"wallet\" instead of the node wallet.")
+	root := command.String("datadir", "", "Reads the wallets from the data \"directory\" (the working directory by default).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of addresses.")
+	utils.HandleErr(command.Parse(args))
+	if *in == "" || *out == "" || wallet.SetNetwork(*network) != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+	dataDir(*root, *network, 0)
+
+	data, err := os.ReadFile(*in)
+	utils.HandleErr(err)
+	var unsigned unsignedTx
+	if err := json.Unmarshal(data, &unsigned); err != nil || unsigned.Tx == nil {
+		fmt.Printf("%s is not an unsigned tx.\n", *in)
+		os.Exit(1)
+	}
+
+	w := wallet.Wallet()
+	if *name != "" {
+		loaded, err := wallet.Manager().Load(*name)
+		utils.HandleErr(err)
+		w = loaded
+	}
+	if w.IsLocked() {
+		utils.HandleErr(w.Unlock(readPassphrase("Passphrase: "), 0))
+	}
+	utils.HandleErr(blockchain.SignTxOffline(unsigned.Tx, unsigned.Spent, *name))
+
+	var spent, paid int
+	for _, spentTxOut := range unsigned.Spent {
+		spent += spentTxOut.TxOut.Amount
+	}
+	for _, txOut := range unsigned.Tx.TxOuts {
+		paid += txOut.Amount
+	}
+	utils.HandleErr(os.WriteFile(*out, utils.ToJSON(unsigned.Tx), 0644))
+	fmt.Printf("Signed %s spending %d in %d inputs, paying %d with a fee of %d.\n", unsigned.Tx.ID, spent, len(unsigned.Spent), paid, spent-paid)
+	fmt.Printf("Submit %s to POST /transactions/submit on a node.\n", *out)
+}
@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/josh3021/nomadcoin/utils"
+	"golang.org/x/term"
+)
+
+// readPassphrase prompts for a passphrase without echoing it.
+func readPassphrase(prompt string) string {
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	utils.HandleErr(err)
+	return string(passphrase)
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/josh3021/nomadcoin/blockchain"
+	"github.com/josh3021/nomadcoin/db"
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/josh3021/nomadcoin/wallet"
+)
+
+// repair checks the database of a stopped node, rewinds its checkpoint to the last valid block
+// and rebuilds its indexes, printing what it found and did as JSON.
+func repair(args []string) {
+	command := flag.NewFlagSet("repair", flag.ExitOnError)
+	restPort := command.Int("restPort", 4000, "Repairs the database of the node of this \"port\" (without -datadir).")
+	root := command.String("datadir", "", "Sets the data \"directory\" (the working directory by default).")
+	network := command.String("network", wallet.MainNet.Name, "Sets the \"network\" of the database.")
+	dbBackend := command.String("dbbackend", "bolt", "Sets the storage \"backend\" of the blockchain.")
+	check := command.Bool("check", false, "Only reports the problems of the database, and exits with 1 if there are.")
+	utils.HandleErr(command.Parse(args))
+	if wallet.SetNetwork(*network) != nil || db.SetBackend(*dbBackend) != nil {
+		command.Usage()
+		os.Exit(2)
+	}
+	dir := dataDir(*root, *network, *restPort)
+	db.InitDB(dir.ChainDB())
+
+	if *check {
+		report := blockchain.CheckIntegrity()
+		fmt.Println(string(utils.ToJSON(report)))
+		db.Close()
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return
+	}
+	report, err := blockchain.Repair()
+	db.Close()
+	utils.HandleErr(err)
+	fmt.Println(string(utils.ToJSON(report)))
+}
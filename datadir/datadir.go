@@ -0,0 +1,52 @@
+// Package datadir lays out the files of a node. In a data directory every network has its own directory:
+//
+//	<datadir>/<network>/chain.db          the blockchain database
+//	<datadir>/<network>/nomadcoin.wallet  the node wallet
+//	<datadir>/<network>/wallets/          the named wallets
+//
+// Without a data directory a node keeps its files in the working directory, as it always did.
+package datadir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	chainDBName   string = "chain.db"
+	legacyDBName  string = "blockchain"
+	legacyDBExt   string = ".db"
+	dirPermission        = 0700
+)
+
+// Dir is where a node keeps its files on one network.
+type Dir struct {
+	path    string
+	chainDB string
+}
+
+// New returns the directory of network in the data directory root, creating it.
+func New(root, network string) (Dir, error) {
+	path := filepath.Join(root, network)
+	if err := os.MkdirAll(path, dirPermission); err != nil {
+		return Dir{}, err
+	}
+	return Dir{path: path, chainDB: filepath.Join(path, chainDBName)}, nil
+}
+
+// Legacy returns the working directory, with a database named after the REST port
+// so that nodes running side by side do not open the same file.
+func Legacy(port int) Dir {
+	return Dir{chainDB: fmt.Sprintf("%s_%d%s", legacyDBName, port, legacyDBExt)}
+}
+
+// ChainDB returns the path of the blockchain database.
+func (d Dir) ChainDB() string {
+	return d.chainDB
+}
+
+// Wallets returns the directory of the node wallet file and of the named wallets.
+func (d Dir) Wallets() string {
+	return d.path
+}
@@ -0,0 +1,42 @@
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	root := t.TempDir()
+	dir, err := New(root, "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := filepath.Join(root, "testnet")
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{dir.ChainDB(), filepath.Join(network, "chain.db")},
+		{dir.Wallets(), network},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, test.got)
+		}
+	}
+	if info, err := os.Stat(dir.Wallets()); err != nil || !info.IsDir() {
+		t.Errorf("Expected the directory to be created, got %v", err)
+	}
+	mainnet, _ := New(root, "mainnet")
+	if mainnet.ChainDB() == dir.ChainDB() || mainnet.Wallets() == dir.Wallets() {
+		t.Error("Expected every network to have its own files")
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	dir := Legacy(4000)
+	if dir.ChainDB() != "blockchain_4000.db" || dir.Wallets() != "" {
+		t.Errorf("Expected the files of the working directory, got %s and %q", dir.ChainDB(), dir.Wallets())
+	}
+}
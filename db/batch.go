@@ -0,0 +1,98 @@
+package db
+
+// Writer is where a batch replays its writes.
+type Writer interface {
+	SaveBlock(hash string, data []byte)
+	SaveBlockchain(data []byte)
+	DeleteAllBlocks()
+	IndexTx(txID, blockHash string)
+	IndexOutput(address, outpoint string, data []byte)
+	IndexSpent(outpoint, txID string)
+	DeleteIndexes()
+}
+
+type batchOp int
+
+const (
+	saveBlockOp batchOp = iota
+	saveBlockchainOp
+	deleteAllBlocksOp
+	indexTxOp
+	indexOutputOp
+	indexSpentOp
+	deleteIndexesOp
+)
+
+type batchWrite struct {
+	op   batchOp
+	hash string
+	// key and address are the entry of index writes
+	key     string
+	address string
+	data    []byte
+}
+
+// Batch collects writes that a store commits at once: after a crash either all of them are stored or none.
+// The writes apply in the order they were added.
+type Batch struct {
+	writes []batchWrite
+	// version is the schema version a migration moves the database to with its writes, 0 to keep it
+	version int
+}
+
+// SaveBlock adds saving a block to the batch.
+func (b *Batch) SaveBlock(hash string, data []byte) {
+	b.writes = append(b.writes, batchWrite{op: saveBlockOp, hash: hash, data: copyBytes(data)})
+}
+
+// SaveBlockchain adds saving the checkpoint to the batch.
+func (b *Batch) SaveBlockchain(data []byte) {
+	b.writes = append(b.writes, batchWrite{op: saveBlockchainOp, data: copyBytes(data)})
+}
+
+// DeleteAllBlocks adds deleting every block, also those saved earlier in the batch, to the batch.
+func (b *Batch) DeleteAllBlocks() {
+	b.writes = append(b.writes, batchWrite{op: deleteAllBlocksOp})
+}
+
+// IndexTx adds indexing the block holding a tx to the batch.
+func (b *Batch) IndexTx(txID, blockHash string) {
+	b.writes = append(b.writes, batchWrite{op: indexTxOp, hash: blockHash, key: txID})
+}
+
+// IndexOutput adds indexing an output paying to address, at outpoint, to the batch.
+func (b *Batch) IndexOutput(address, outpoint string, data []byte) {
+	b.writes = append(b.writes, batchWrite{op: indexOutputOp, address: address, key: outpoint, data: copyBytes(data)})
+}
+
+// IndexSpent adds indexing the tx spending the output at outpoint to the batch.
+func (b *Batch) IndexSpent(outpoint, txID string) {
+	b.writes = append(b.writes, batchWrite{op: indexSpentOp, key: outpoint, data: []byte(txID)})
+}
+
+// DeleteIndexes adds deleting every index entry, also those added earlier in the batch, to the batch.
+func (b *Batch) DeleteIndexes() {
+	b.writes = append(b.writes, batchWrite{op: deleteIndexesOp})
+}
+
+// Replay applies the writes of the batch to w in order.
+func (b *Batch) Replay(w Writer) {
+	for _, write := range b.writes {
+		switch write.op {
+		case saveBlockOp:
+			w.SaveBlock(write.hash, write.data)
+		case saveBlockchainOp:
+			w.SaveBlockchain(write.data)
+		case deleteAllBlocksOp:
+			w.DeleteAllBlocks()
+		case indexTxOp:
+			w.IndexTx(write.key, write.hash)
+		case indexOutputOp:
+			w.IndexOutput(write.address, write.key, write.data)
+		case indexSpentOp:
+			w.IndexSpent(write.key, string(write.data))
+		case deleteIndexesOp:
+			w.DeleteIndexes()
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"bytes"
+
+	"github.com/josh3021/nomadcoin/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore keeps the blocks, the checkpoint and the indexes in the buckets of a bbolt file.
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBolt(path string) (store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(t *bolt.Tx) error {
+		for _, bucket := range append([]string{dataBucket, blocksBucket}, indexBuckets...) {
+			if _, err := t.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+// get returns a copy of the value of key in bucket, as bbolt values are only valid inside their tx.
+func (s *boltStore) get(bucket, key string) []byte {
+	var data []byte
+	s.db.View(func(t *bolt.Tx) error {
+		if value := t.Bucket([]byte(bucket)).Get([]byte(key)); value != nil {
+			data = append([]byte{}, value...)
+		}
+		return nil
+	})
+	return data
+}
+
+// LoadBlockchain returns the checkpoint from database
+func (s *boltStore) LoadBlockchain() []byte {
+	return s.get(dataBucket, checkpoint)
+}
+
+// FindBlock returns the block from database
+func (s *boltStore) FindBlock(hash string) []byte {
+	return s.get(blocksBucket, hash)
+}
+
+// ForEachBlock calls fn with every block in database.
+func (s *boltStore) ForEachBlock(fn func(hash string, data []byte)) {
+	s.db.View(func(t *bolt.Tx) error {
+		return t.Bucket([]byte(blocksBucket)).ForEach(func(key, value []byte) error {
+			fn(string(key), append([]byte{}, value...))
+			return nil
+		})
+	})
+}
+
+// FindTxBlock returns the hash of the block holding the tx from database
+func (s *boltStore) FindTxBlock(txID string) []byte {
+	return s.get(txsBucket, txID)
+}
+
+// ForEachOutput calls fn with every output of address in database, seeking to the prefix of address.
+func (s *boltStore) ForEachOutput(address string, fn func(outpoint string, data []byte)) {
+	prefix := []byte(outputPrefix(address))
+	s.db.View(func(t *bolt.Tx) error {
+		c := t.Bucket([]byte(outputsBucket)).Cursor()
+		for key, value := c.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = c.Next() {
+			fn(string(key[len(prefix):]), append([]byte{}, value...))
+		}
+		return nil
+	})
+}
+
+// FindSpender returns the ID of the tx spending the outpoint from database
+func (s *boltStore) FindSpender(outpoint string) []byte {
+	return s.get(spentBucket, outpoint)
+}
+
+// LoadVersion returns the schema version from database
+func (s *boltStore) LoadVersion() []byte {
+	return s.get(dataBucket, versionKey)
+}
+
+// Write commits batch in one bbolt tx.
+func (s *boltStore) Write(batch *Batch) {
+	err := s.db.Update(func(t *bolt.Tx) error {
+		w := &boltWriter{t: t}
+		batch.Replay(w)
+		if batch.version != 0 {
+			w.put(dataBucket, versionKey, encodeVersion(batch.version))
+		}
+		return w.err
+	})
+	utils.HandleErr(err)
+}
+
+// boltWriter writes to the buckets of a bbolt tx, keeping the first error so that the tx rolls back.
+type boltWriter struct {
+	t   *bolt.Tx
+	err error
+}
+
+func (w *boltWriter) put(bucket, key string, data []byte) {
+	if w.err == nil {
+		w.err = w.t.Bucket([]byte(bucket)).Put([]byte(key), data)
+	}
+}
+
+// SaveBlock saves the block in database.
+func (w *boltWriter) SaveBlock(hash string, data []byte) {
+	w.put(blocksBucket, hash, data)
+}
+
+// SaveBlockchain saves the blockchain in database.
+func (w *boltWriter) SaveBlockchain(data []byte) {
+	w.put(dataBucket, checkpoint, data)
+}
+
+// recreate deletes and recreates bucket, emptying it.
+func (w *boltWriter) recreate(bucket string) {
+	if w.err == nil {
+		w.err = w.t.DeleteBucket([]byte(bucket))
+	}
+	if w.err == nil {
+		_, w.err = w.t.CreateBucket([]byte(bucket))
+	}
+}
+
+// DeleteAllBlocks delete and recreate blocksBucket
+func (w *boltWriter) DeleteAllBlocks() {
+	w.recreate(blocksBucket)
+}
+
+// IndexTx saves the hash of the block holding the tx in database.
+func (w *boltWriter) IndexTx(txID, blockHash string) {
+	w.put(txsBucket, txID, []byte(blockHash))
+}
+
+// IndexOutput saves an output of address in database.
+func (w *boltWriter) IndexOutput(address, outpoint string, data []byte) {
+	w.put(outputsBucket, outputKey(address, outpoint), data)
+}
+
+// IndexSpent saves the ID of the tx spending the outpoint in database.
+func (w *boltWriter) IndexSpent(outpoint, txID string) {
+	w.put(spentBucket, outpoint, []byte(txID))
+}
+
+// DeleteIndexes delete and recreate the index buckets
+func (w *boltWriter) DeleteIndexes() {
+	for _, bucket := range indexBuckets {
+		w.recreate(bucket)
+	}
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
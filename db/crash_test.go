@@ -0,0 +1,103 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	crashBackendEnv = "NOMADCOIN_CRASH_BACKEND"
+	crashPathEnv    = "NOMADCOIN_CRASH_PATH"
+	// crashReplaceEvery makes every few batches delete all blocks first, as Replace does.
+	crashReplaceEvery = 4
+)
+
+func crashBlock(i int, part string) string {
+	return fmt.Sprintf("%d-%s", i, part)
+}
+
+// crashBatch saves two blocks, the index entry of a tx and the checkpoint i,
+// after deleting all blocks and indexes every few batches.
+func crashBatch(i int) *Batch {
+	batch := new(Batch)
+	if i%crashReplaceEvery == 0 {
+		batch.DeleteAllBlocks()
+		batch.DeleteIndexes()
+	}
+	batch.SaveBlock(crashBlock(i, "a"), []byte(crashBlock(i, "a")))
+	batch.SaveBlock(crashBlock(i, "b"), []byte(crashBlock(i, "b")))
+	batch.IndexTx(crashBlock(i, "tx"), crashBlock(i, "a"))
+	batch.SaveBlockchain([]byte(strconv.Itoa(i)))
+	return batch
+}
+
+// TestCrashWriter writes batches until TestCrash kills it.
+func TestCrashWriter(t *testing.T) {
+	backend, path := os.Getenv(crashBackendEnv), os.Getenv(crashPathEnv)
+	if path == "" {
+		t.Skip("only run by TestCrash")
+	}
+	s := openTestStore(t, backend, path)
+	last, _ := strconv.Atoi(string(s.LoadBlockchain()))
+	for i := last + 1; ; i++ {
+		s.Write(crashBatch(i))
+	}
+}
+
+// TestCrash kills a process writing batches midway, and checks that every batch was stored entirely or not at all.
+func TestCrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("kills writer processes")
+	}
+	for _, backend := range Backends() {
+		if !durable(backend) {
+			continue
+		}
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chain.db")
+			previous := 0
+			for round := 0; round < 3; round++ {
+				writer := exec.Command(os.Args[0], "-test.run=^TestCrashWriter$")
+				writer.Env = append(os.Environ(), crashBackendEnv+"="+backend, crashPathEnv+"="+path)
+				if err := writer.Start(); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(time.Duration(200+rand.Intn(300)) * time.Millisecond)
+				writer.Process.Kill()
+				writer.Wait()
+
+				s := openTestStore(t, backend, path)
+				last, _ := strconv.Atoi(string(s.LoadBlockchain()))
+				if last <= previous {
+					t.Fatalf("Expected the checkpoint to move forward from %d, got %d", previous, last)
+				}
+				for _, part := range []string{"a", "b"} {
+					if s.FindBlock(crashBlock(last, part)) == nil {
+						t.Errorf("Expected the blocks of checkpoint %d, %s is missing", last, crashBlock(last, part))
+					}
+					if s.FindBlock(crashBlock(last+1, part)) != nil {
+						t.Errorf("Expected no block after checkpoint %d, found %s", last, crashBlock(last+1, part))
+					}
+					replaced := last%crashReplaceEvery == 0
+					if found := s.FindBlock(crashBlock(last-1, part)) != nil; last > 1 && found == replaced {
+						t.Errorf("Expected %s to be found %v at checkpoint %d", crashBlock(last-1, part), !replaced, last)
+					}
+				}
+				if hash := s.FindTxBlock(crashBlock(last, "tx")); string(hash) != crashBlock(last, "a") {
+					t.Errorf("Expected the tx of checkpoint %d to be indexed, got %q", last, hash)
+				}
+				if hash := s.FindTxBlock(crashBlock(last+1, "tx")); hash != nil {
+					t.Errorf("Expected no tx indexed after checkpoint %d, found it in %s", last, hash)
+				}
+				s.Close()
+				previous = last
+			}
+		})
+	}
+}
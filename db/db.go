@@ -1,60 +1,150 @@
 package db
 
 import (
-	"fmt"
-	"os"
+	"encoding/hex"
+	"errors"
+	"sort"
 
 	"github.com/josh3021/nomadcoin/utils"
-	bolt "go.etcd.io/bbolt"
 )
 
 const (
-	dbName       = "blockchain"
-	dbExtName    = ".db"
 	dataBucket   = "data"
 	blocksBucket = "blocks"
+	// the indexes map tx IDs to the hash of their block, addresses to the outputs paying to them,
+	// and outpoints to the ID of the tx spending them
+	txsBucket     = "txs"
+	outputsBucket = "outputs"
+	spentBucket   = "spent"
 
 	checkpoint = "checkpoint"
+	versionKey = "version"
 )
 
-var db *bolt.DB
+var indexBuckets = []string{txsBucket, outputsBucket, spentBucket}
+
+// store is a backend keeping the blocks and the checkpoint of the chain, and the indexes of its txs.
+// It commits every batch in one transaction. Slices it returns belong to the caller.
+type store interface {
+	FindBlock(hash string) []byte
+	ForEachBlock(fn func(hash string, data []byte))
+	FindTxBlock(txID string) []byte
+	// ForEachOutput calls fn with the outputs indexed for address, in the order of their outpoints.
+	ForEachOutput(address string, fn func(outpoint string, data []byte))
+	FindSpender(outpoint string) []byte
+	LoadBlockchain() []byte
+	LoadVersion() []byte
+	Write(batch *Batch)
+	Close() error
+}
+
+// storeWriter writes to a store one write at a time, each in a batch of its own.
+type storeWriter struct {
+	store
+}
+
+func (w storeWriter) SaveBlock(hash string, data []byte) {
+	batch := new(Batch)
+	batch.SaveBlock(hash, data)
+	w.Write(batch)
+}
+
+func (w storeWriter) SaveBlockchain(data []byte) {
+	batch := new(Batch)
+	batch.SaveBlockchain(data)
+	w.Write(batch)
+}
+
+func (w storeWriter) DeleteAllBlocks() {
+	batch := new(Batch)
+	batch.DeleteAllBlocks()
+	w.Write(batch)
+}
+
+// outputPrefix starts the keys of the outputs of address in the outputs index.
+// Addresses are hex-encoded so that no address is a prefix of the keys of another.
+func outputPrefix(address string) string {
+	return hex.EncodeToString([]byte(address)) + "/"
+}
+
+func outputKey(address, outpoint string) string {
+	return outputPrefix(address) + outpoint
+}
+
+// backends opens a store of every backend at a path.
+var backends = map[string]func(path string) (store, error){
+	"bolt":    openBolt,
+	"leveldb": openLevelDB,
+	"memory":  openMemory,
+}
+
+// ErrUnknownBackend returns ERROR if a storage backend is not known.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+var backend = "bolt"
+var db store
 
 type DB struct{}
 
 func (DB) FindBlock(hash string) []byte {
-	return findBlock(hash)
+	return db.FindBlock(hash)
+}
+func (DB) ForEachBlock(fn func(hash string, data []byte)) {
+	db.ForEachBlock(fn)
+}
+func (DB) FindTxBlock(txID string) []byte {
+	return db.FindTxBlock(txID)
+}
+func (DB) ForEachOutput(address string, fn func(outpoint string, data []byte)) {
+	db.ForEachOutput(address, fn)
+}
+func (DB) FindSpender(outpoint string) []byte {
+	return db.FindSpender(outpoint)
 }
 func (DB) SaveBlock(hash string, data []byte) {
-	saveBlock(hash, data)
+	storeWriter{db}.SaveBlock(hash, data)
 }
 func (DB) SaveBlockchain(data []byte) {
-	saveBlockchain(data)
+	storeWriter{db}.SaveBlockchain(data)
 }
 func (DB) LoadBlockchain() []byte {
-	return loadBlockchain()
+	return db.LoadBlockchain()
 }
 func (DB) DeleteAllBlocks() {
-	deleteAllBlocks()
+	storeWriter{db}.DeleteAllBlocks()
+}
+
+// Write commits the writes of batch at once.
+func (DB) Write(batch *Batch) {
+	db.Write(batch)
 }
 
-func getDBName() string {
-	port := os.Args[2][10:]
-	return fmt.Sprintf("%s_%s%s", dbName, port, dbExtName)
+// Backends returns the names of the storage backends, sorted.
+func Backends() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetBackend sets the storage backend InitDB opens.
+func SetBackend(name string) error {
+	if _, ok := backends[name]; !ok {
+		return ErrUnknownBackend
+	}
+	backend = name
+	return nil
 }
 
-// InitDB initialize database (Initialize database if it does not initialized).
-func InitDB() {
+// InitDB opens the database at path with the storage backend (Initialize database if it does not initialized).
+func InitDB(path string) {
 	if db == nil {
-		dbPointer, err := bolt.Open(getDBName(), 0600, nil)
-		db = dbPointer
-		utils.HandleErr(err)
-		err = db.Update(func(t *bolt.Tx) error {
-			_, err := t.CreateBucketIfNotExists([]byte(dataBucket))
-			utils.HandleErr(err)
-			_, err = t.CreateBucketIfNotExists([]byte(blocksBucket))
-			return err
-		})
+		opened, err := backends[backend](path)
 		utils.HandleErr(err)
+		utils.HandleErr(migrate(opened, path, backends[backend]))
+		db = opened
 	}
 }
 
@@ -62,55 +152,3 @@ func InitDB() {
 func Close() {
 	db.Close()
 }
-
-// SaveBlock saves the block in database.
-func saveBlock(hash string, data []byte) {
-	err := db.Update(func(t *bolt.Tx) error {
-		bucket := t.Bucket([]byte(blocksBucket))
-		err := bucket.Put([]byte(hash), data)
-		return err
-	})
-	utils.HandleErr(err)
-}
-
-// SaveBlockchain saves the blockchain in database.
-func saveBlockchain(data []byte) {
-	err := db.Update(func(t *bolt.Tx) error {
-		bucket := t.Bucket([]byte(dataBucket))
-		err := bucket.Put([]byte(checkpoint), data)
-		return err
-	})
-	utils.HandleErr(err)
-}
-
-// loadBlockchain returns the checkpoint from database
-func loadBlockchain() []byte {
-	var data []byte
-	db.View(func(t *bolt.Tx) error {
-		bucket := t.Bucket([]byte(dataBucket))
-		data = bucket.Get([]byte(checkpoint))
-		return nil
-	})
-	return data
-}
-
-// FindBlock returns the block from database
-func findBlock(hash string) []byte {
-	var data []byte
-	db.View(func(t *bolt.Tx) error {
-		bucket := t.Bucket([]byte(blocksBucket))
-		data = bucket.Get([]byte(hash))
-		return nil
-	})
-	return data
-}
-
-// EmptyBlocks delete and recreate blocksBucket
-func deleteAllBlocks() {
-	db.Update(func(tx *bolt.Tx) error {
-		utils.HandleErr(tx.DeleteBucket([]byte(blocksBucket)))
-		_, err := tx.CreateBucket([]byte(blocksBucket))
-		utils.HandleErr(err)
-		return nil
-	})
-}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// durable reports whether the data of a backend outlives the store.
+func durable(backend string) bool {
+	return backend != "memory"
+}
+
+func openTestStore(t testing.TB, backend, path string) store {
+	s, err := backends[backend](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestConformance runs the same checks against every backend.
+func TestConformance(t *testing.T) {
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chain.db")
+			s := openTestStore(t, backend, path)
+			w := storeWriter{s}
+			t.Run("An empty store should have no blocks and no checkpoint.", func(t *testing.T) {
+				if block := s.FindBlock("x"); block != nil {
+					t.Errorf("Expected nil, got %v", block)
+				}
+				if data := s.LoadBlockchain(); data != nil {
+					t.Errorf("Expected nil, got %v", data)
+				}
+			})
+			t.Run("Saved blocks and checkpoints should be found.", func(t *testing.T) {
+				w.SaveBlock("a", []byte("block a"))
+				w.SaveBlock("b", []byte("block b"))
+				w.SaveBlock("a", []byte("block a2"))
+				w.SaveBlockchain([]byte("checkpoint 1"))
+				w.SaveBlockchain([]byte("checkpoint 2"))
+				tests := []struct {
+					got      []byte
+					expected string
+				}{
+					{s.FindBlock("a"), "block a2"},
+					{s.FindBlock("b"), "block b"},
+					{s.LoadBlockchain(), "checkpoint 2"},
+				}
+				for _, test := range tests {
+					if string(test.got) != test.expected {
+						t.Errorf("Expected %s, got %s", test.expected, test.got)
+					}
+				}
+			})
+			t.Run("Every block should be iterated.", func(t *testing.T) {
+				blocks := make(map[string]string)
+				s.ForEachBlock(func(hash string, data []byte) {
+					blocks[hash] = string(data)
+				})
+				if len(blocks) != 2 || blocks["a"] != "block a2" || blocks["b"] != "block b" {
+					t.Errorf("Expected blocks a and b, got %v", blocks)
+				}
+			})
+			t.Run("Returned slices should belong to the caller.", func(t *testing.T) {
+				data := []byte("block c")
+				w.SaveBlock("c", data)
+				data[0] = 'x'
+				found := s.FindBlock("c")
+				found[1] = 'x'
+				if again := s.FindBlock("c"); !bytes.Equal(again, []byte("block c")) {
+					t.Errorf("Expected block c, got %s", again)
+				}
+			})
+			t.Run("Deleting all blocks should keep the checkpoint.", func(t *testing.T) {
+				w.DeleteAllBlocks()
+				for _, hash := range []string{"a", "b", "c"} {
+					if block := s.FindBlock(hash); block != nil {
+						t.Errorf("Expected %s to be deleted, got %s", hash, block)
+					}
+				}
+				if data := s.LoadBlockchain(); string(data) != "checkpoint 2" {
+					t.Errorf("Expected checkpoint 2, got %s", data)
+				}
+				w.SaveBlock("d", []byte("block d"))
+				if block := s.FindBlock("d"); string(block) != "block d" {
+					t.Errorf("Expected blocks to be saved after deleting, got %s", block)
+				}
+			})
+			t.Run("Batches should apply their writes in order.", func(t *testing.T) {
+				batch := new(Batch)
+				batch.SaveBlock("e", []byte("block e"))
+				batch.DeleteAllBlocks()
+				batch.SaveBlock("f", []byte("block f"))
+				batch.SaveBlockchain([]byte("checkpoint 3"))
+				s.Write(batch)
+				for hash, expected := range map[string][]byte{"d": nil, "e": nil, "f": []byte("block f")} {
+					if block := s.FindBlock(hash); !bytes.Equal(block, expected) {
+						t.Errorf("Expected %q for %s, got %q", expected, hash, block)
+					}
+				}
+				if data := s.LoadBlockchain(); string(data) != "checkpoint 3" {
+					t.Errorf("Expected checkpoint 3, got %s", data)
+				}
+				w.SaveBlock("d", []byte("block d"))
+				w.SaveBlockchain([]byte("checkpoint 2"))
+			})
+			t.Run("Indexed txs, outputs and spenders should be found.", func(t *testing.T) {
+				batch := new(Batch)
+				batch.IndexTx("t1", "d")
+				batch.IndexOutput("addr", "t1:1", []byte("output 1"))
+				batch.IndexOutput("addr", "t1:0", []byte("output 0"))
+				batch.IndexOutput("addr2", "t1:2", []byte("output 2"))
+				batch.IndexSpent("t0:0", "t1")
+				s.Write(batch)
+				if hash := s.FindTxBlock("t1"); string(hash) != "d" {
+					t.Errorf("Expected d, got %s", hash)
+				}
+				if spender := s.FindSpender("t0:0"); string(spender) != "t1" {
+					t.Errorf("Expected t1, got %s", spender)
+				}
+				if missing := s.FindTxBlock("t0"); missing != nil {
+					t.Errorf("Expected nil, got %s", missing)
+				}
+				tests := []struct {
+					address  string
+					expected []string
+				}{
+					{"addr", []string{"t1:0=output 0", "t1:1=output 1"}},
+					{"addr2", []string{"t1:2=output 2"}},
+					{"add", nil},
+				}
+				for _, test := range tests {
+					var outputs []string
+					s.ForEachOutput(test.address, func(outpoint string, data []byte) {
+						outputs = append(outputs, outpoint+"="+string(data))
+					})
+					if fmt.Sprint(outputs) != fmt.Sprint(test.expected) {
+						t.Errorf("Expected %v for %s, got %v", test.expected, test.address, outputs)
+					}
+				}
+			})
+			t.Run("Deleting indexes should keep the blocks.", func(t *testing.T) {
+				batch := new(Batch)
+				batch.IndexTx("t2", "d")
+				batch.DeleteIndexes()
+				batch.IndexTx("t3", "d")
+				s.Write(batch)
+				for txID, expected := range map[string][]byte{"t1": nil, "t2": nil, "t3": []byte("d")} {
+					if hash := s.FindTxBlock(txID); !bytes.Equal(hash, expected) {
+						t.Errorf("Expected %q for %s, got %q", expected, txID, hash)
+					}
+				}
+				s.ForEachOutput("addr", func(outpoint string, data []byte) {
+					t.Errorf("Expected no outputs, got %s", outpoint)
+				})
+				if spender := s.FindSpender("t0:0"); spender != nil {
+					t.Errorf("Expected nil, got %s", spender)
+				}
+				if block := s.FindBlock("d"); string(block) != "block d" {
+					t.Errorf("Expected block d, got %s", block)
+				}
+			})
+			t.Run("Durable stores should keep their data once reopened.", func(t *testing.T) {
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if !durable(backend) {
+					t.Skip("the memory store is not durable")
+				}
+				reopened := openTestStore(t, backend, path)
+				defer reopened.Close()
+				if block := reopened.FindBlock("d"); string(block) != "block d" {
+					t.Errorf("Expected block d, got %s", block)
+				}
+				if data := reopened.LoadBlockchain(); string(data) != "checkpoint 2" {
+					t.Errorf("Expected checkpoint 2, got %s", data)
+				}
+				if hash := reopened.FindTxBlock("t3"); string(hash) != "d" {
+					t.Errorf("Expected d, got %s", hash)
+				}
+			})
+		})
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	defer SetBackend("bolt")
+	if err := SetBackend("pebble"); err != ErrUnknownBackend {
+		t.Errorf("Expected %v, got %v", ErrUnknownBackend, err)
+	}
+	for _, name := range Backends() {
+		if err := SetBackend(name); err != nil || backend != name {
+			t.Errorf("Expected %s to be set, got %v", name, err)
+		}
+	}
+}
+
+// BenchmarkSaveBlock compares the write throughput of the backends when every write is synced,
+// as when a node saves the block and the checkpoint of every block it mines or receives,
+// one write at a time, both in a batch, or in a batch with the index entries of the txs of the block.
+func BenchmarkSaveBlock(b *testing.B) {
+	block := bytes.Repeat([]byte{0xab}, 1024)
+	for _, backend := range Backends() {
+		b.Run(backend+"/separate", func(b *testing.B) {
+			s := openTestStore(b, backend, filepath.Join(b.TempDir(), "chain.db"))
+			defer s.Close()
+			w := storeWriter{s}
+			b.SetBytes(int64(len(block)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.SaveBlock(fmt.Sprintf("%064d", i), block)
+				w.SaveBlockchain(block[:64])
+			}
+		})
+		b.Run(backend+"/batch", func(b *testing.B) {
+			s := openTestStore(b, backend, filepath.Join(b.TempDir(), "chain.db"))
+			defer s.Close()
+			b.SetBytes(int64(len(block)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				batch := new(Batch)
+				batch.SaveBlock(fmt.Sprintf("%064d", i), block)
+				batch.SaveBlockchain(block[:64])
+				s.Write(batch)
+			}
+		})
+		b.Run(backend+"/indexed", func(b *testing.B) {
+			s := openTestStore(b, backend, filepath.Join(b.TempDir(), "chain.db"))
+			defer s.Close()
+			b.SetBytes(int64(len(block)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				hash := fmt.Sprintf("%064d", i)
+				batch := new(Batch)
+				batch.SaveBlock(hash, block)
+				batch.SaveBlockchain(block[:64])
+				// a coinbase and a payment with change
+				for tx := 0; tx < 2; tx++ {
+					txID := fmt.Sprintf("%063d%d", i, tx)
+					batch.IndexTx(txID, hash)
+					for index := 0; index < 2; index++ {
+						batch.IndexOutput(fmt.Sprintf("address%d", index), fmt.Sprintf("%s:%d", txID, index), block[:64])
+					}
+				}
+				batch.IndexSpent(fmt.Sprintf("%063d1:0", i), fmt.Sprintf("%063d1", i))
+				s.Write(batch)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelStore keeps the blocks, the checkpoint and the indexes in a LevelDB directory, under the bucket names as key prefixes.
+// Writes are synced like bbolt commits, so that both survive a crash.
+type levelStore struct {
+	db *leveldb.DB
+}
+
+var syncWrite = &opt.WriteOptions{Sync: true}
+
+func openLevelDB(path string) (store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelStore{db: db}, nil
+}
+
+func levelKey(bucket, key string) []byte {
+	return []byte(bucket + "/" + key)
+}
+
+func (s *levelStore) get(bucket, key string) []byte {
+	data, err := s.db.Get(levelKey(bucket, key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	utils.HandleErr(err)
+	return data
+}
+
+func (s *levelStore) FindBlock(hash string) []byte {
+	return s.get(blocksBucket, hash)
+}
+
+func (s *levelStore) ForEachBlock(fn func(hash string, data []byte)) {
+	prefix := levelKey(blocksBucket, "")
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		fn(string(iter.Key()[len(prefix):]), append([]byte{}, iter.Value()...))
+	}
+	utils.HandleErr(iter.Error())
+}
+
+func (s *levelStore) FindTxBlock(txID string) []byte {
+	return s.get(txsBucket, txID)
+}
+
+// ForEachOutput calls fn with every output of address, iterating over the keys of its prefix in order.
+func (s *levelStore) ForEachOutput(address string, fn func(outpoint string, data []byte)) {
+	prefix := levelKey(outputsBucket, outputPrefix(address))
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		fn(string(iter.Key()[len(prefix):]), append([]byte{}, iter.Value()...))
+	}
+	utils.HandleErr(iter.Error())
+}
+
+func (s *levelStore) FindSpender(outpoint string) []byte {
+	return s.get(spentBucket, outpoint)
+}
+
+func (s *levelStore) LoadBlockchain() []byte {
+	return s.get(dataBucket, checkpoint)
+}
+
+func (s *levelStore) LoadVersion() []byte {
+	return s.get(dataBucket, versionKey)
+}
+
+// Write commits batch as one synced LevelDB batch.
+func (s *levelStore) Write(batch *Batch) {
+	w := &levelWriter{db: s.db, batch: new(leveldb.Batch), saved: make(map[string]bool)}
+	batch.Replay(w)
+	if batch.version != 0 {
+		w.batch.Put(levelKey(dataBucket, versionKey), encodeVersion(batch.version))
+	}
+	utils.HandleErr(w.err)
+	utils.HandleErr(s.db.Write(w.batch, syncWrite))
+}
+
+// levelWriter collects the writes of a batch in a LevelDB batch.
+type levelWriter struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+	// saved holds the keys of the blocks and index entries saved earlier in the batch, which are not in the database yet
+	saved map[string]bool
+	err   error
+}
+
+// put puts data at the key of bucket, remembering the key to delete it with its bucket.
+func (w *levelWriter) put(bucket, key string, data []byte) {
+	k := levelKey(bucket, key)
+	w.batch.Put(k, data)
+	w.saved[string(k)] = true
+}
+
+func (w *levelWriter) SaveBlock(hash string, data []byte) {
+	w.put(blocksBucket, hash, data)
+}
+
+func (w *levelWriter) SaveBlockchain(data []byte) {
+	w.batch.Put(levelKey(dataBucket, checkpoint), data)
+}
+
+// deleteBucket deletes every key of the prefix of bucket, in the database and earlier in the batch.
+func (w *levelWriter) deleteBucket(bucket string) {
+	prefix := levelKey(bucket, "")
+	iter := w.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		w.batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil && w.err == nil {
+		w.err = err
+	}
+	for key := range w.saved {
+		if strings.HasPrefix(key, string(prefix)) {
+			w.batch.Delete([]byte(key))
+			delete(w.saved, key)
+		}
+	}
+}
+
+func (w *levelWriter) DeleteAllBlocks() {
+	w.deleteBucket(blocksBucket)
+}
+
+func (w *levelWriter) IndexTx(txID, blockHash string) {
+	w.put(txsBucket, txID, []byte(blockHash))
+}
+
+func (w *levelWriter) IndexOutput(address, outpoint string, data []byte) {
+	w.put(outputsBucket, outputKey(address, outpoint), data)
+}
+
+func (w *levelWriter) IndexSpent(outpoint, txID string) {
+	w.put(spentBucket, outpoint, []byte(txID))
+}
+
+func (w *levelWriter) DeleteIndexes() {
+	for _, bucket := range indexBuckets {
+		w.deleteBucket(bucket)
+	}
+}
+
+func (s *levelStore) Close() error {
+	return s.db.Close()
+}
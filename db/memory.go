@@ -0,0 +1,192 @@
+package db
+
+import (
+	"sort"
+	"sync"
+)
+
+// memoryStore keeps the blocks, the checkpoint and the indexes in memory, for tests and ephemeral nodes.
+// Everything is gone once the node stops.
+type memoryStore struct {
+	data memoryData
+	m    sync.RWMutex
+}
+
+// memoryData is what a memory store holds, written without locking.
+type memoryData struct {
+	blocks     map[string][]byte
+	checkpoint []byte
+	version    []byte
+	txs        map[string][]byte
+	// outputs holds the outputs of every address by outpoint
+	outputs map[string]map[string][]byte
+	spent   map[string][]byte
+}
+
+// Memory is a database kept in memory, apart from the one InitDB opens, like the stores of tests.
+type Memory struct {
+	s *memoryStore
+}
+
+// NewMemory returns an empty database kept in memory.
+func NewMemory() *Memory {
+	return &Memory{newMemoryStore()}
+}
+
+func (m *Memory) FindBlock(hash string) []byte {
+	return m.s.FindBlock(hash)
+}
+func (m *Memory) ForEachBlock(fn func(hash string, data []byte)) {
+	m.s.ForEachBlock(fn)
+}
+func (m *Memory) FindTxBlock(txID string) []byte {
+	return m.s.FindTxBlock(txID)
+}
+func (m *Memory) ForEachOutput(address string, fn func(outpoint string, data []byte)) {
+	m.s.ForEachOutput(address, fn)
+}
+func (m *Memory) FindSpender(outpoint string) []byte {
+	return m.s.FindSpender(outpoint)
+}
+func (m *Memory) SaveBlock(hash string, data []byte) {
+	storeWriter{m.s}.SaveBlock(hash, data)
+}
+func (m *Memory) SaveBlockchain(data []byte) {
+	storeWriter{m.s}.SaveBlockchain(data)
+}
+func (m *Memory) LoadBlockchain() []byte {
+	return m.s.LoadBlockchain()
+}
+func (m *Memory) DeleteAllBlocks() {
+	storeWriter{m.s}.DeleteAllBlocks()
+}
+
+// Write commits the writes of batch at once.
+func (m *Memory) Write(batch *Batch) {
+	m.s.Write(batch)
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{data: memoryData{blocks: make(map[string][]byte)}}
+	s.data.DeleteIndexes()
+	return s
+}
+
+func openMemory(string) (store, error) {
+	return newMemoryStore(), nil
+}
+
+func (d *memoryData) SaveBlock(hash string, data []byte) {
+	d.blocks[hash] = copyBytes(data)
+}
+
+func (d *memoryData) SaveBlockchain(data []byte) {
+	d.checkpoint = copyBytes(data)
+}
+
+func (d *memoryData) DeleteAllBlocks() {
+	d.blocks = make(map[string][]byte)
+}
+
+func (d *memoryData) IndexTx(txID, blockHash string) {
+	d.txs[txID] = []byte(blockHash)
+}
+
+func (d *memoryData) IndexOutput(address, outpoint string, data []byte) {
+	if d.outputs[address] == nil {
+		d.outputs[address] = make(map[string][]byte)
+	}
+	d.outputs[address][outpoint] = copyBytes(data)
+}
+
+func (d *memoryData) IndexSpent(outpoint, txID string) {
+	d.spent[outpoint] = []byte(txID)
+}
+
+func (d *memoryData) DeleteIndexes() {
+	d.txs = make(map[string][]byte)
+	d.outputs = make(map[string]map[string][]byte)
+	d.spent = make(map[string][]byte)
+}
+
+func (s *memoryStore) FindBlock(hash string) []byte {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return copyBytes(s.data.blocks[hash])
+}
+
+// ForEachBlock calls fn with a copy of every block, outside of the lock so that fn may write.
+func (s *memoryStore) ForEachBlock(fn func(hash string, data []byte)) {
+	s.m.RLock()
+	blocks := make(map[string][]byte, len(s.data.blocks))
+	for hash, data := range s.data.blocks {
+		blocks[hash] = copyBytes(data)
+	}
+	s.m.RUnlock()
+	for hash, data := range blocks {
+		fn(hash, data)
+	}
+}
+
+func (s *memoryStore) FindTxBlock(txID string) []byte {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return copyBytes(s.data.txs[txID])
+}
+
+// ForEachOutput calls fn with a copy of every output of address, sorted by outpoint like on disk,
+// outside of the lock so that fn may write.
+func (s *memoryStore) ForEachOutput(address string, fn func(outpoint string, data []byte)) {
+	s.m.RLock()
+	outputs := make(map[string][]byte, len(s.data.outputs[address]))
+	var outpoints []string
+	for outpoint, data := range s.data.outputs[address] {
+		outputs[outpoint] = copyBytes(data)
+		outpoints = append(outpoints, outpoint)
+	}
+	s.m.RUnlock()
+	sort.Strings(outpoints)
+	for _, outpoint := range outpoints {
+		fn(outpoint, outputs[outpoint])
+	}
+}
+
+func (s *memoryStore) FindSpender(outpoint string) []byte {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return copyBytes(s.data.spent[outpoint])
+}
+
+func (s *memoryStore) LoadBlockchain() []byte {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return copyBytes(s.data.checkpoint)
+}
+
+func (s *memoryStore) LoadVersion() []byte {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return copyBytes(s.data.version)
+}
+
+// Write replays batch while holding the lock, so readers see all of its writes or none.
+func (s *memoryStore) Write(batch *Batch) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	batch.Replay(&s.data)
+	if batch.version != 0 {
+		s.data.version = encodeVersion(batch.version)
+	}
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// copyBytes returns a copy of data, nil if data is nil.
+func copyBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return append([]byte{}, data...)
+}
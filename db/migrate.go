@@ -0,0 +1,112 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNewerSchema returns ERROR if a database was written by a newer node than this one.
+	ErrNewerSchema = errors.New("database schema is newer than this node supports, upgrade the node")
+	// ErrInvalidSchema returns ERROR if the schema version of a database can not be read.
+	ErrInvalidSchema = errors.New("database schema version can not be read")
+)
+
+// migration upgrades a database from the version before to, adding its writes to a batch
+// that commits them together with the new version.
+type migration struct {
+	to          int
+	description string
+	migrate     func(s store, batch *Batch) error
+}
+
+// migrations upgrade databases one version at a time, in order.
+// Add one whenever the buckets or the encoding of Block, Tx or the checkpoint change.
+var migrations = []migration{
+	{
+		to:          1,
+		description: "record the schema version of databases written before versions, whose layout is the same",
+		migrate:     func(store, *Batch) error { return nil },
+	},
+	{
+		to: 2,
+		// the values of blocks are encoded by the blockchain, so the db can not index them
+		description: "add the tx, output and spender indexes, which the node builds from the blocks when it starts",
+		migrate:     func(store, *Batch) error { return nil },
+	},
+}
+
+// schemaVersion is the version of the layout and of the encoding of the values this node writes,
+// the one of its last migration.
+var schemaVersion = migrations[len(migrations)-1].to
+
+func encodeVersion(version int) []byte {
+	return []byte(strconv.Itoa(version))
+}
+
+// storedVersion returns the schema version of s. Databases written before versions are version 0,
+// and empty ones are new.
+func storedVersion(s store) (version int, empty bool, err error) {
+	if data := s.LoadVersion(); data != nil {
+		version, err := strconv.Atoi(string(data))
+		if err != nil || version < 1 {
+			return 0, false, ErrInvalidSchema
+		}
+		return version, false, nil
+	}
+	empty = s.LoadBlockchain() == nil
+	s.ForEachBlock(func(string, []byte) {
+		empty = false
+	})
+	return 0, empty, nil
+}
+
+// backup copies the blocks, the checkpoint and the version of s to a new database next to path.
+func backup(s store, path string, version int, open func(path string) (store, error)) (string, error) {
+	backupPath := fmt.Sprintf("%s.v%d-%d.bak", path, version, time.Now().Unix())
+	copied, err := open(backupPath)
+	if err != nil {
+		return "", err
+	}
+	defer copied.Close()
+	batch := &Batch{version: version}
+	s.ForEachBlock(batch.SaveBlock)
+	if data := s.LoadBlockchain(); data != nil {
+		batch.SaveBlockchain(data)
+	}
+	copied.Write(batch)
+	return backupPath, nil
+}
+
+// migrate upgrades the database of s at path to schemaVersion in place, after backing it up with open.
+// New databases are stamped with schemaVersion, and databases of newer nodes are refused.
+func migrate(s store, path string, open func(path string) (store, error)) error {
+	version, empty, err := storedVersion(s)
+	switch {
+	case err != nil:
+		return err
+	case empty:
+		s.Write(&Batch{version: schemaVersion})
+		return nil
+	case version > schemaVersion:
+		return ErrNewerSchema
+	case version == schemaVersion:
+		return nil
+	}
+	backupPath, err := backup(s, path, version, open)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up the database of schema version %d to %s\n", version, backupPath)
+	for _, m := range migrations[version:] {
+		batch := &Batch{version: m.to}
+		if err := m.migrate(s, batch); err != nil {
+			return fmt.Errorf("migrating the database to schema version %d: %w", m.to, err)
+		}
+		s.Write(batch)
+		fmt.Printf("Migrated the database to schema version %d: %s\n", m.to, m.description)
+	}
+	return nil
+}
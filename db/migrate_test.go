@@ -0,0 +1,158 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// durableBackends are the backends whose databases can be written by an older node.
+func durableBackends() []string {
+	var names []string
+	for _, backend := range Backends() {
+		if durable(backend) {
+			names = append(names, backend)
+		}
+	}
+	return names
+}
+
+// openOldStore returns a store at path holding a block and a checkpoint, with the version stored if it is not 0.
+func openOldStore(t *testing.T, backend, path string, version int) store {
+	s := openTestStore(t, backend, path)
+	batch := &Batch{version: version}
+	batch.SaveBlock("a", []byte("block a"))
+	batch.SaveBlockchain([]byte("checkpoint"))
+	s.Write(batch)
+	return s
+}
+
+func TestMigrate(t *testing.T) {
+	for _, backend := range durableBackends() {
+		t.Run(backend, func(t *testing.T) {
+			t.Run("New databases should get the current version.", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openTestStore(t, backend, path)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); err != nil {
+					t.Fatal(err)
+				}
+				if version := string(s.LoadVersion()); version != string(encodeVersion(schemaVersion)) {
+					t.Errorf("Expected %d, got %s", schemaVersion, version)
+				}
+			})
+			t.Run("Unversioned databases should be backed up and migrated.", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, 0)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); err != nil {
+					t.Fatal(err)
+				}
+				if version := string(s.LoadVersion()); version != string(encodeVersion(schemaVersion)) {
+					t.Errorf("Expected %d, got %s", schemaVersion, version)
+				}
+				if block := s.FindBlock("a"); string(block) != "block a" {
+					t.Errorf("Expected block a, got %s", block)
+				}
+				backups, _ := filepath.Glob(path + ".v0-*.bak")
+				if len(backups) != 1 {
+					t.Fatalf("Expected 1 backup, got %v", backups)
+				}
+				copied := openTestStore(t, backend, backups[0])
+				defer copied.Close()
+				if copied.LoadVersion() != nil || string(copied.FindBlock("a")) != "block a" || string(copied.LoadBlockchain()) != "checkpoint" {
+					t.Errorf("Expected the backup to hold the unversioned database")
+				}
+			})
+			t.Run("Pending migrations should apply in order with their writes.", func(t *testing.T) {
+				savedMigrations, savedVersion := migrations, schemaVersion
+				defer func() { migrations, schemaVersion = savedMigrations, savedVersion }()
+				step := func(to int) migration {
+					return migration{to: to, description: "test", migrate: func(s store, batch *Batch) error {
+						batch.SaveBlock("a", append(s.FindBlock("a"), byte('0'+to)))
+						return nil
+					}}
+				}
+				migrations, schemaVersion = []migration{step(1), step(2), step(3)}, 3
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, 1)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); err != nil {
+					t.Fatal(err)
+				}
+				if block := s.FindBlock("a"); string(block) != "block a23" {
+					t.Errorf("Expected block a23, got %s", block)
+				}
+				if version := string(s.LoadVersion()); version != "3" {
+					t.Errorf("Expected 3, got %s", version)
+				}
+				if backups, _ := filepath.Glob(path + ".v1-*.bak"); len(backups) != 1 {
+					t.Errorf("Expected 1 backup, got %v", backups)
+				}
+			})
+			t.Run("Failed migrations should leave the database at its version.", func(t *testing.T) {
+				savedMigrations, savedVersion := migrations, schemaVersion
+				defer func() { migrations, schemaVersion = savedMigrations, savedVersion }()
+				failed := errors.New("failed")
+				migrations = []migration{savedMigrations[0], {to: 2, migrate: func(s store, batch *Batch) error {
+					batch.DeleteAllBlocks()
+					return failed
+				}}}
+				schemaVersion = 2
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, 1)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); !errors.Is(err, failed) {
+					t.Errorf("Expected %v, got %v", failed, err)
+				}
+				if version := string(s.LoadVersion()); version != "1" || s.FindBlock("a") == nil {
+					t.Errorf("Expected version 1 with block a, got %s", version)
+				}
+			})
+			t.Run("Current databases should be left alone.", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, schemaVersion)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); err != nil {
+					t.Fatal(err)
+				}
+				if backups, _ := filepath.Glob(path + ".*.bak"); len(backups) != 0 {
+					t.Errorf("Expected no backup, got %v", backups)
+				}
+			})
+			t.Run("Newer databases should be refused.", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, schemaVersion+1)
+				defer s.Close()
+				if err := migrate(s, path, backends[backend]); err != ErrNewerSchema {
+					t.Errorf("Expected %v, got %v", ErrNewerSchema, err)
+				}
+				if version := string(s.LoadVersion()); version != string(encodeVersion(schemaVersion+1)) {
+					t.Errorf("Expected %d, got %s", schemaVersion+1, version)
+				}
+			})
+			t.Run("Unreadable versions should be refused.", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "chain.db")
+				s := openOldStore(t, backend, path, 0)
+				defer s.Close()
+				var err error
+				switch s := s.(type) {
+				case *boltStore:
+					err = s.db.Update(func(t *bolt.Tx) error {
+						return t.Bucket([]byte(dataBucket)).Put([]byte(versionKey), []byte("winter"))
+					})
+				case *levelStore:
+					err = s.db.Put(levelKey(dataBucket, versionKey), []byte("winter"), nil)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := migrate(s, path, backends[backend]); err != ErrInvalidSchema {
+					t.Errorf("Expected %v, got %v", ErrInvalidSchema, err)
+				}
+			})
+		})
+	}
+}
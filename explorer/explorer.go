@@ -20,8 +20,8 @@ type homeData struct {
 }
 
 func home(w http.ResponseWriter, r *http.Request) {
-	// data := homeData{"Home", blockchain.BlockChain().GetAllBlocks()}
-	// templates.ExecuteTemplate(w, "home", data)
+	data := homeData{"Home", blockchain.Blocks(blockchain.Blockchain())}
+	templates.ExecuteTemplate(w, "home", data)
 }
 
 func add(w http.ResponseWriter, r *http.Request) {
@@ -30,9 +30,13 @@ func add(w http.ResponseWriter, r *http.Request) {
 		templates.ExecuteTemplate(w, "add", nil)
 	case http.MethodPost:
 		r.ParseForm()
-		// data := r.Form.Get("data")
+		data := r.Form.Get("data")
+		if _, err := blockchain.Mempool().AddDataTx("", 0, data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		blockchain.Blockchain().AddBlock()
-		http.Redirect(w, r, "/", http.StatusPermanentRedirect)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 	}
 }
 
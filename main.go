@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/josh3021/nomadcoin/cli"
-	"github.com/josh3021/nomadcoin/db"
 )
 
 func sendOnly(c chan<- int) {
@@ -30,8 +29,6 @@ func receiveOnly(c <-chan int) {
 }
 
 func main() {
-	defer db.Close()
-	db.InitDB()
 	cli.Start()
 	// wallet.Wallet()
 	// c := make(chan int, 10)
@@ -86,11 +86,15 @@ func handleMessage(m *Message, p *peer) {
 	case MessageAllBlocksResponse:
 		var payload []*blockchain.Block
 		utils.HandleErr(json.Unmarshal(m.Payload, &payload))
-		blockchain.Blockchain().Replace(payload)
+		if err := blockchain.Blockchain().Replace(payload); err != nil {
+			fmt.Printf("Rejected the blocks of %s: %s\n", p.key, err)
+		}
 	case MessageNewBlockNotify:
 		var payload *blockchain.Block
 		utils.HandleErr(json.Unmarshal(m.Payload, &payload))
-		blockchain.Blockchain().AddPeerBlock(payload)
+		if err := blockchain.Blockchain().AddPeerBlock(payload); err != nil {
+			fmt.Printf("Rejected the block of %s: %s\n", p.key, err)
+		}
 	case MessageNewTxNotify:
 		var payload *blockchain.Tx
 		utils.HandleErr(json.Unmarshal(m.Payload, &payload))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/josh3021/nomadcoin/blockchain"
@@ -86,10 +87,249 @@ func documentation(rw http.ResponseWriter, r *http.Request) {
 			Method:      http.MethodGet,
 			Description: "Show my wallet",
 		},
+		{
+			URL:         url("/wallets"),
+			Method:      http.MethodGet,
+			Description: "See the named wallets",
+		},
+		{
+			URL:         url("/wallets"),
+			Method:      http.MethodPost,
+			Description: "Create and load a named wallet of a key type (p256, secp256k1, ed25519 or schnorr), an empty watch-only wallet, or a wallet of a private key in PEM or WIF",
+			Payload:     "name:string, passphrase?:string, watchOnly?:bool, keyType?:string, privateKey?:string",
+		},
+		{
+			URL:         url("/wallets/{name}/import"),
+			Method:      http.MethodPost,
+			Description: "Watch an address or the addresses of an extended public key with a watch-only wallet",
+			Payload:     "address?:string, xpub?:string",
+		},
+		{
+			URL:         url("/wallets/{name}/load"),
+			Method:      http.MethodPost,
+			Description: "Load a named wallet",
+		},
+		{
+			URL:         url("/wallets/{name}/unload"),
+			Method:      http.MethodPost,
+			Description: "Lock and unload a named wallet",
+		},
+		{
+			URL:         url("/wallets/{name}"),
+			Method:      http.MethodGet,
+			Description: "Show a named wallet; /balance, /transactions, /transactions/batch, /addresses, /labels, /history, /xpub, /sign-message, /public-key, /export-key, /mnemonic, /scan, /encrypt, /unlock and /lock work like under /wallet",
+		},
+		{
+			URL:         url("/wallet/addresses"),
+			Method:      http.MethodGet,
+			Description: "See the addresses of my wallet with their labels and balances",
+		},
+		{
+			URL:         url("/wallet/addresses"),
+			Method:      http.MethodPost,
+			Description: "Create a new receiving address",
+		},
+		{
+			URL:         url("/wallet/labels"),
+			Method:      http.MethodGet,
+			Description: "See the labels of my wallet",
+		},
+		{
+			URL:         url("/wallet/labels"),
+			Method:      http.MethodPost,
+			Description: "Label an address and note something about it, or forget both if empty",
+			Payload:     "address:string, label:string, note?:string",
+		},
+		{
+			URL:         url("/wallet/history"),
+			Method:      http.MethodGet,
+			Description: "See the transactions on chain that paid to or spent from my wallet",
+		},
+		{
+			URL:         url("/wallet/transactions"),
+			Method:      http.MethodGet,
+			Description: "See the pending, confirmed, conflicted and abandoned transactions of my wallet with their confirmations",
+		},
+		{
+			URL:         url("/wallet/sign-message"),
+			Method:      http.MethodPost,
+			Description: "Sign a message with the key of an address of my wallet, to prove I own it",
+			Payload:     "address?:string, message:string",
+		},
+		{
+			URL:         url("/wallet/public-key"),
+			Method:      http.MethodGet,
+			Description: "See the public key of an address of my wallet (?address=, the wallet address by default)",
+		},
+		{
+			URL:         url("/wallet/export-key"),
+			Method:      http.MethodPost,
+			Description: "Export the private key of an address of my encrypted wallet in PEM or WIF, with its passphrase; whoever holds it can spend its coins",
+			Payload:     "address?:string, format:string (pem or wif), passphrase:string",
+		},
+		{
+			URL:         url("/verify-message"),
+			Method:      http.MethodPost,
+			Description: "Verify a signed message",
+			Payload:     "address:string, message:string, publicKey?:string, signature:string",
+		},
+		{
+			URL:         url("/wallet/xpub"),
+			Method:      http.MethodGet,
+			Description: "Show the extended public key of my wallet, to watch it from elsewhere",
+		},
+		{
+			URL:         url("/wallet/mnemonic"),
+			Method:      http.MethodPost,
+			Description: "Show the backup phrase of my encrypted wallet, with its passphrase",
+			Payload:     "passphrase:string",
+		},
+		{
+			URL:         url("/wallet/recover"),
+			Method:      http.MethodPost,
+			Description: "Recover my wallet from a backup phrase",
+			Payload:     "mnemonic:string, passphrase?:string",
+		},
+		{
+			URL:         url("/wallet/scan"),
+			Method:      http.MethodPost,
+			Description: "Find the used addresses of my wallet on chain",
+		},
+		{
+			URL:         url("/wallet/encrypt"),
+			Method:      http.MethodPost,
+			Description: "Encrypt my wallet file with a passphrase",
+			Payload:     "passphrase:string",
+		},
+		{
+			URL:         url("/wallet/unlock"),
+			Method:      http.MethodPost,
+			Description: "Unlock my wallet for timeout seconds",
+			Payload:     "passphrase:string, timeout?:int",
+		},
+		{
+			URL:         url("/wallet/lock"),
+			Method:      http.MethodPost,
+			Description: "Lock my wallet",
+		},
 		{
 			URL:         url("/transactions"),
 			Method:      http.MethodPost,
-			Description: "Create Transaction",
+			Description: "Create Transaction; selection is branch-and-bound, largest-first, smallest-first or random",
+			Payload:     "to:string, amount:int, data?:string, selection?:string, include?:[]{txId,index}, exclude?:[]{txId,index}",
+		},
+		{
+			URL:         url("/transactions/batch"),
+			Method:      http.MethodPost,
+			Description: "Create one Transaction paying every recipient; coin control works like for /transactions",
+			Payload:     "recipients:[]{to:string, amount:int}, selection?:string, include?:[]{txId,index}, exclude?:[]{txId,index}",
+		},
+		{
+			URL:         url("/transactions/build"),
+			Method:      http.MethodPost,
+			Description: "Build an Unsigned Transaction with the outputs it spends",
+			Payload:     "from?:string, to:string, amount:int, data?:string, selection?:string, include?:[]{txId,index}, exclude?:[]{txId,index}",
+		},
+		{
+			URL:         url("/transactions/sign"),
+			Method:      http.MethodPost,
+			Description: "Sign a Transaction with my wallet",
+			Payload:     "tx",
+		},
+		{
+			URL:         url("/transactions/submit"),
+			Method:      http.MethodPost,
+			Description: "Validate and relay a fully signed Transaction",
+			Payload:     "tx",
+		},
+		{
+			URL:         url("/multisig"),
+			Method:      http.MethodPost,
+			Description: "Create a Multisig Address",
+			Payload:     "required:int, addresses:[]string (public keys)",
+		},
+		{
+			URL:         url("/multisig/transactions"),
+			Method:      http.MethodPost,
+			Description: "Create an Unsigned Multisig Transaction",
+			Payload:     "from:string, to:string, amount:int",
+		},
+		{
+			URL:         url("/multisig/sign"),
+			Method:      http.MethodPost,
+			Description: "Sign a Multisig Transaction with my wallet",
+			Payload:     "tx",
+		},
+		{
+			URL:         url("/multisig/combine"),
+			Method:      http.MethodPost,
+			Description: "Combine partially signed Multisig Transactions",
+			Payload:     "txs:[]tx",
+		},
+		{
+			URL:         url("/multisig/submit"),
+			Method:      http.MethodPost,
+			Description: "Submit a fully signed Multisig Transaction",
+			Payload:     "tx",
+		},
+		{
+			URL:         url("/musig"),
+			Method:      http.MethodPost,
+			Description: "Create a MuSig Address aggregating Schnorr keys",
+			Payload:     "addresses:[]string (schnorr addresses)",
+		},
+		{
+			URL:         url("/musig/transactions"),
+			Method:      http.MethodPost,
+			Description: "Create an Unsigned MuSig Transaction",
+			Payload:     "addresses:[]string, to:string, amount:int",
+		},
+		{
+			URL:         url("/musig/nonces"),
+			Method:      http.MethodPost,
+			Description: "Add the nonces of my wallet to a MuSig Transaction",
+			Payload:     "musigTx",
+		},
+		{
+			URL:         url("/musig/sign"),
+			Method:      http.MethodPost,
+			Description: "Add the partial signatures of my wallet to a MuSig Transaction holding every nonce",
+			Payload:     "musigTx",
+		},
+		{
+			URL:         url("/musig/combine"),
+			Method:      http.MethodPost,
+			Description: "Combine the nonces and partial signatures of MuSig Transactions",
+			Payload:     "txs:[]musigTx",
+		},
+		{
+			URL:         url("/musig/submit"),
+			Method:      http.MethodPost,
+			Description: "Aggregate the partial signatures of a MuSig Transaction and submit it",
+			Payload:     "musigTx",
+		},
+		{
+			URL:         url("/htlcs"),
+			Method:      http.MethodGet,
+			Description: "See unspent Hash Time-Locked Contracts",
+		},
+		{
+			URL:         url("/htlcs"),
+			Method:      http.MethodPost,
+			Description: "Lock coins of my wallet in a Hash Time-Locked Contract",
+			Payload:     "recipient:string (public key), hash:string, lockTime:int, amount:int",
+		},
+		{
+			URL:         url("/htlcs/claim"),
+			Method:      http.MethodPost,
+			Description: "Claim a Hash Time-Locked Contract with its preimage",
+			Payload:     "txId:string, index:int, preimage:string",
+		},
+		{
+			URL:         url("/htlcs/refund"),
+			Method:      http.MethodPost,
+			Description: "Refund an expired Hash Time-Locked Contract",
+			Payload:     "txId:string, index:int",
 		},
 		{
 			URL:         url("ws"),
@@ -140,15 +380,26 @@ type balanceResponse struct {
 }
 
 func myBalance(rw http.ResponseWriter, r *http.Request) {
-	address := wallet.Wallet().Address
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
 	total := r.URL.Query().Get("total")
 	bc := blockchain.Blockchain()
 	switch total {
 	case "true":
-		balance := blockchain.GetBalanceByAddress(bc, address)
-		utils.HandleErr(json.NewEncoder(rw).Encode(balanceResponse{address, balance}))
+		balance := 0
+		for _, address := range w.Addresses() {
+			balance += blockchain.GetBalanceByAddress(bc, address)
+		}
+		utils.HandleErr(json.NewEncoder(rw).Encode(balanceResponse{w.Address, balance}))
 	default:
-		utils.HandleErr(json.NewEncoder(rw).Encode(blockchain.UTxOutsByAddress(bc, address)))
+		uTxOuts := []*blockchain.UTxOut{}
+		for _, address := range w.Addresses() {
+			uTxOuts = append(uTxOuts, blockchain.UTxOutsByAddress(bc, address)...)
+		}
+		utils.HandleErr(json.NewEncoder(rw).Encode(uTxOuts))
 	}
 }
 
@@ -171,30 +422,768 @@ func mempool(rw http.ResponseWriter, r *http.Request) {
 }
 
 type myWalletResponse struct {
-	Address string `json:"address"`
+	Address   string   `json:"address"`
+	PublicKey string   `json:"publicKey,omitempty"`
+	Addresses []string `json:"addresses"`
+	Encrypted bool     `json:"encrypted"`
+	Locked    bool     `json:"locked"`
+	WatchOnly bool     `json:"watchOnly"`
+	KeyType   string   `json:"keyType"`
 }
 
 func myWallet(rw http.ResponseWriter, r *http.Request) {
-	address := wallet.Wallet().Address
-	json.NewEncoder(rw).Encode(myWalletResponse{Address: address})
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	// the public key is unknown while an encrypted wallet is locked
+	publicKey, _ := w.PublicKey(w.Address)
+	json.NewEncoder(rw).Encode(myWalletResponse{
+		Address:   w.Address,
+		PublicKey: publicKey,
+		Addresses: w.Addresses(),
+		Encrypted: w.IsEncrypted(),
+		Locked:    w.IsLocked(),
+		WatchOnly: w.IsWatchOnly(),
+		KeyType:   string(w.KeyType()),
+	})
+}
+
+type walletAddressResponse struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+	Note    string `json:"note,omitempty"`
+	Balance int    `json:"balance"`
+}
+
+func walletAddresses(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	labels, err := w.Labels()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	labelOf := make(map[string]*wallet.Label)
+	for _, label := range labels {
+		labelOf[label.Address] = label
+	}
+	bc := blockchain.Blockchain()
+	addresses := []*walletAddressResponse{}
+	for _, address := range w.Addresses() {
+		response := &walletAddressResponse{Address: address, Balance: blockchain.GetBalanceByAddress(bc, address)}
+		if label, ok := labelOf[address]; ok {
+			response.Label, response.Note = label.Label, label.Note
+		}
+		addresses = append(addresses, response)
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(addresses))
+}
+
+func labels(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if r.Method == http.MethodPost {
+		var payload wallet.Label
+		utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+		if err := w.SetLabel(payload.Address, payload.Label, payload.Note); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	labels, err := w.Labels()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(labels))
+}
+
+func history(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(blockchain.HistoryByAddresses(blockchain.Blockchain(), w.Addresses())))
+}
+
+func walletTransactions(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(blockchain.WalletTxs(blockchain.Blockchain(), w.Addresses())))
+}
+
+type signMessagePayload struct {
+	Address string `json:"address,omitempty"`
+	Message string `json:"message"`
+}
+
+func signMessage(rw http.ResponseWriter, r *http.Request) {
+	var payload signMessagePayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	signed, err := w.SignMessage(payload.Address, payload.Message)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(signed))
+}
+
+type publicKeyResponse struct {
+	Address   string `json:"address"`
+	KeyType   string `json:"keyType"`
+	PublicKey string `json:"publicKey"`
+}
+
+func publicKey(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		address = w.Address
+	}
+	key, err := w.PublicKey(address)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	keyType, err := wallet.PublicKeyType(key)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(publicKeyResponse{address, string(keyType), key}))
+}
+
+type exportKeyPayload struct {
+	Address    string `json:"address,omitempty"`
+	Format     string `json:"format"`
+	Passphrase string `json:"passphrase"`
+}
+
+type exportKeyResponse struct {
+	Address    string `json:"address"`
+	Format     string `json:"format"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// exportKey hands a private key only to whoever knows the passphrase of the wallet, like mnemonic,
+// so the keys of unencrypted wallets can not be exported over the api.
+func exportKey(rw http.ResponseWriter, r *http.Request) {
+	var payload exportKeyPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	decrypted, err := w.Decrypt(payload.Passphrase)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	format, err := wallet.ParseKeyFormat(payload.Format)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if payload.Address == "" {
+		payload.Address = w.Address
+	}
+	privateKey, err := decrypted.ExportPrivateKey(payload.Address, format)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(exportKeyResponse{payload.Address, string(format), privateKey}))
+}
+
+type verifyMessageResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func verifyMessage(rw http.ResponseWriter, r *http.Request) {
+	var signed wallet.SignedMessage
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&signed))
+	if err := wallet.VerifyMessage(&signed); err != nil {
+		utils.HandleErr(json.NewEncoder(rw).Encode(verifyMessageResponse{Valid: false, Reason: err.Error()}))
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(verifyMessageResponse{Valid: true}))
+}
+
+type xpubResponse struct {
+	XPub string `json:"xpub"`
+}
+
+func xpub(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	key, err := w.ExtendedPublicKey()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(xpubResponse{key}))
+}
+
+type importPayload struct {
+	Address string `json:"address,omitempty"`
+	XPub    string `json:"xpub,omitempty"`
+}
+
+func importWatched(rw http.ResponseWriter, r *http.Request) {
+	var payload importPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if payload.XPub != "" {
+		err = w.ImportXPub(payload.XPub)
+	} else {
+		err = w.ImportAddress(payload.Address)
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scanWallet(rw, r)
+}
+
+type addressResponse struct {
+	Address string `json:"address"`
+}
+
+func newAddress(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	address, err := w.NewAddress()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(addressResponse{address}))
+}
+
+type mnemonicPayload struct {
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// mnemonic shows the backup phrase only to whoever knows the passphrase of the wallet,
+// as any local process or web page can call the api.
+func mnemonic(rw http.ResponseWriter, r *http.Request) {
+	var payload mnemonicPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	decrypted, err := w.Decrypt(payload.Passphrase)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	phrase, err := decrypted.Mnemonic()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(mnemonicPayload{Mnemonic: phrase}))
+}
+
+func recoverWallet(rw http.ResponseWriter, r *http.Request) {
+	var payload mnemonicPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	if _, err := wallet.Recover(payload.Mnemonic, payload.Passphrase); err != nil {
+		writeError(rw, err)
+		return
+	}
+	scanWallet(rw, r)
+}
+
+func scanWallet(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := w.Scan(blockchain.UsedAddresses(blockchain.Blockchain())); err != nil {
+		writeError(rw, err)
+		return
+	}
+	myWallet(rw, r)
+}
+
+type passphrasePayload struct {
+	Passphrase string `json:"passphrase"`
+	// Timeout is the number of seconds the wallet stays unlocked
+	Timeout int `json:"timeout,omitempty"`
+}
+
+func encryptWallet(rw http.ResponseWriter, r *http.Request) {
+	var payload passphrasePayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := w.Encrypt(payload.Passphrase); err != nil {
+		writeError(rw, err)
+		return
+	}
+	myWallet(rw, r)
+}
+
+func unlockWallet(rw http.ResponseWriter, r *http.Request) {
+	var payload passphrasePayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := w.Unlock(payload.Passphrase, time.Duration(payload.Timeout)*time.Second); err != nil {
+		writeError(rw, err)
+		return
+	}
+	myWallet(rw, r)
+}
+
+func lockWallet(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := w.Lock(); err != nil {
+		writeError(rw, err)
+		return
+	}
+	myWallet(rw, r)
 }
 
 type addTxPayload struct {
 	To     string `json:"to"`
 	Amount int    `json:"amount"`
+	Data   string `json:"data,omitempty"`
+	blockchain.TxOptions
 }
 
 func transactions(rw http.ResponseWriter, r *http.Request) {
 	var payload addTxPayload
 	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
-	tx, err := blockchain.Mempool().AddTx(payload.To, payload.Amount)
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if payload.To != "" {
+		if err := wallet.ValidateAddress(payload.To); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	var tx *blockchain.Tx
+	if payload.Data != "" {
+		tx, err = blockchain.Mempool().AddDataTxFrom(w, payload.To, payload.Amount, payload.Data, &payload.TxOptions)
+	} else {
+		tx, err = blockchain.Mempool().AddTxFrom(w, payload.To, payload.Amount, &payload.TxOptions)
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	p2p.BroadcastNewTx(tx)
+	rw.WriteHeader(http.StatusCreated)
+}
+
+type batchTxPayload struct {
+	Recipients []*blockchain.Recipient `json:"recipients"`
+	blockchain.TxOptions
+}
+
+type txResponse struct {
+	ID string `json:"id"`
+}
+
+func batchTransactions(rw http.ResponseWriter, r *http.Request) {
+	var payload batchTxPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	tx, err := blockchain.Mempool().AddBatchTxFrom(w, payload.Recipients, &payload.TxOptions)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	p2p.BroadcastNewTx(tx)
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(txResponse{tx.ID}))
+}
+
+type createWalletPayload struct {
+	Name       string `json:"name"`
+	Passphrase string `json:"passphrase,omitempty"`
+	WatchOnly  bool   `json:"watchOnly,omitempty"`
+	KeyType    string `json:"keyType,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+}
+
+func wallets(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		infos, err := wallet.Manager().List()
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		utils.HandleErr(json.NewEncoder(rw).Encode(infos))
+	case http.MethodPost:
+		var payload createWalletPayload
+		utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+		var err error
+		switch {
+		case payload.WatchOnly:
+			_, err = wallet.Manager().CreateWatchOnly(payload.Name)
+		case payload.PrivateKey != "":
+			_, err = wallet.Manager().ImportKey(payload.Name, payload.PrivateKey, payload.Passphrase)
+		case payload.KeyType != "":
+			var keyType wallet.KeyType
+			if keyType, err = wallet.ParseKeyType(payload.KeyType); err == nil {
+				_, err = wallet.Manager().CreateWithKeyType(payload.Name, payload.Passphrase, keyType)
+			}
+		default:
+			_, err = wallet.Manager().Create(payload.Name, payload.Passphrase)
+		}
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusCreated)
+		myWallet(rw, mux.SetURLVars(r, map[string]string{"name": payload.Name}))
+	}
+}
+
+func loadWallet(rw http.ResponseWriter, r *http.Request) {
+	if _, err := wallet.Manager().Load(mux.Vars(r)["name"]); err != nil {
+		writeError(rw, err)
+		return
+	}
+	myWallet(rw, r)
+}
+
+func unloadWallet(rw http.ResponseWriter, r *http.Request) {
+	if err := wallet.Manager().Unload(mux.Vars(r)["name"]); err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+type buildTxPayload struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+	Data   string `json:"data,omitempty"`
+	blockchain.TxOptions
+}
+
+type buildTxResponse struct {
+	Tx    *blockchain.Tx           `json:"tx"`
+	Spent []*blockchain.SpentTxOut `json:"spent"`
+}
+
+func buildTransaction(rw http.ResponseWriter, r *http.Request) {
+	var payload buildTxPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	if payload.From == "" {
+		payload.From = wallet.Wallet().Address
+	}
+	tx, spent, err := blockchain.BuildTx(payload.From, payload.To, payload.Amount, payload.Data, &payload.TxOptions)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(buildTxResponse{tx, spent}))
+}
+
+func signTransaction(rw http.ResponseWriter, r *http.Request) {
+	var tx blockchain.Tx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&tx))
+	if err := blockchain.SignTx(&tx); err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+func submitTransaction(rw http.ResponseWriter, r *http.Request) {
+	var tx blockchain.Tx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&tx))
+	if err := blockchain.Mempool().AddSignedTx(&tx); err != nil {
+		writeError(rw, err)
+		return
+	}
+	p2p.BroadcastNewTx(&tx)
+	rw.WriteHeader(http.StatusCreated)
+}
+
+type multisigAddressPayload struct {
+	Required  int      `json:"required"`
+	Addresses []string `json:"addresses"`
+}
+
+type multisigAddressResponse struct {
+	Address string `json:"address"`
+}
+
+type multisigTxPayload struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+type combineTxsPayload struct {
+	Txs []*blockchain.Tx `json:"txs"`
+}
+
+func writeError(rw http.ResponseWriter, err error) {
+	rw.WriteHeader(http.StatusBadRequest)
+	utils.HandleErr(json.NewEncoder(rw).Encode(errorResponse{err.Error()}))
+}
+
+func multisigAddress(rw http.ResponseWriter, r *http.Request) {
+	var payload multisigAddressPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	address, err := wallet.MultisigAddress(payload.Required, payload.Addresses)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(multisigAddressResponse{address}))
+}
+
+func multisigTransactions(rw http.ResponseWriter, r *http.Request) {
+	var payload multisigTxPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	tx, err := blockchain.MakeMultisigTx(payload.From, payload.To, payload.Amount)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+func multisigSign(rw http.ResponseWriter, r *http.Request) {
+	var tx blockchain.Tx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&tx))
+	if err := blockchain.SignMultisigTx(&tx); err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+func multisigCombine(rw http.ResponseWriter, r *http.Request) {
+	var payload combineTxsPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	tx, err := blockchain.CombineMultisigTxs(payload.Txs)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+type muSigAddressPayload struct {
+	Addresses []string `json:"addresses"`
+}
+
+type muSigTxPayload struct {
+	Addresses []string `json:"addresses"`
+	To        string   `json:"to"`
+	Amount    int      `json:"amount"`
+}
+
+type combineMuSigTxsPayload struct {
+	Txs []*blockchain.MuSigTx `json:"txs"`
+}
+
+func muSigAddress(rw http.ResponseWriter, r *http.Request) {
+	var payload muSigAddressPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	address, err := wallet.MuSigAddress(payload.Addresses)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(multisigAddressResponse{address}))
+}
+
+func muSigTransactions(rw http.ResponseWriter, r *http.Request) {
+	var payload muSigTxPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	m, err := blockchain.MakeMuSigTx(payload.Addresses, payload.To, payload.Amount)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(m))
+}
+
+func muSigNonces(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	var m blockchain.MuSigTx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&m))
+	if err := m.AddNonces(w); err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(m))
+}
+
+func muSigSign(rw http.ResponseWriter, r *http.Request) {
+	w, err := wallet.Manager().Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	var m blockchain.MuSigTx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&m))
+	if err := m.Sign(w); err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(m))
+}
+
+func muSigCombine(rw http.ResponseWriter, r *http.Request) {
+	var payload combineMuSigTxsPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	m, err := blockchain.CombineMuSigTxs(payload.Txs)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	utils.HandleErr(json.NewEncoder(rw).Encode(m))
+}
+
+func muSigSubmit(rw http.ResponseWriter, r *http.Request) {
+	var m blockchain.MuSigTx
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&m))
+	tx, err := m.SignedTx()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := blockchain.Mempool().AddSignedTx(tx); err != nil {
+		writeError(rw, err)
+		return
+	}
+	p2p.BroadcastNewTx(tx)
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+type addHTLCPayload struct {
+	Recipient string `json:"recipient"`
+	Hash      string `json:"hash"`
+	LockTime  int    `json:"lockTime"`
+	Amount    int    `json:"amount"`
+}
+
+type spendHTLCPayload struct {
+	TxID     string `json:"txId"`
+	Index    int    `json:"index"`
+	Preimage string `json:"preimage"`
+}
+
+func htlcs(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		utils.HandleErr(json.NewEncoder(rw).Encode(blockchain.HTLCs(blockchain.Blockchain())))
+	case http.MethodPost:
+		var payload addHTLCPayload
+		utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+		tx, err := blockchain.Mempool().AddHTLC(payload.Recipient, payload.Hash, payload.LockTime, payload.Amount)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		p2p.BroadcastNewTx(tx)
+		rw.WriteHeader(http.StatusCreated)
+		utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+	}
+}
+
+func claimHTLC(rw http.ResponseWriter, r *http.Request) {
+	var payload spendHTLCPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	tx, err := blockchain.Mempool().ClaimHTLC(payload.TxID, payload.Index, payload.Preimage)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	p2p.BroadcastNewTx(tx)
+	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
+}
+
+func refundHTLC(rw http.ResponseWriter, r *http.Request) {
+	var payload spendHTLCPayload
+	utils.HandleErr(json.NewDecoder(r.Body).Decode(&payload))
+	tx, err := blockchain.Mempool().RefundHTLC(payload.TxID, payload.Index)
 	if err != nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		utils.HandleErr(json.NewEncoder(rw).Encode(errorResponse{err.Error()}))
+		writeError(rw, err)
 		return
 	}
 	p2p.BroadcastNewTx(tx)
 	rw.WriteHeader(http.StatusCreated)
+	utils.HandleErr(json.NewEncoder(rw).Encode(tx))
 }
 
 type addPeerPayload struct {
@@ -227,7 +1216,65 @@ func Start(inputPort int) {
 	router.HandleFunc("/balance/{address}", balance).Methods(http.MethodGet)
 	router.HandleFunc("/mempool", mempool).Methods(http.MethodGet)
 	router.HandleFunc("/wallet", myWallet).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/addresses", walletAddresses).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/addresses", newAddress).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/labels", labels).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/wallet/history", history).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/transactions", walletTransactions).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/xpub", xpub).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/sign-message", signMessage).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/public-key", publicKey).Methods(http.MethodGet)
+	router.HandleFunc("/wallet/export-key", exportKey).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/mnemonic", mnemonic).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/recover", recoverWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/scan", scanWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/encrypt", encryptWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/unlock", unlockWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallet/lock", lockWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets", wallets).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/wallets/{name}", myWallet).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/load", loadWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/unload", unloadWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/balance", myBalance).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/transactions", walletTransactions).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/transactions", transactions).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/transactions/batch", batchTransactions).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/import", importWatched).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/addresses", walletAddresses).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/addresses", newAddress).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/labels", labels).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/wallets/{name}/history", history).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/xpub", xpub).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/sign-message", signMessage).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/public-key", publicKey).Methods(http.MethodGet)
+	router.HandleFunc("/wallets/{name}/export-key", exportKey).Methods(http.MethodPost)
+	router.HandleFunc("/verify-message", verifyMessage).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/mnemonic", mnemonic).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/scan", scanWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/encrypt", encryptWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/unlock", unlockWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/lock", lockWallet).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/musig/nonces", muSigNonces).Methods(http.MethodPost)
+	router.HandleFunc("/wallets/{name}/musig/sign", muSigSign).Methods(http.MethodPost)
 	router.HandleFunc("/transactions", transactions).Methods(http.MethodPost)
+	router.HandleFunc("/transactions/batch", batchTransactions).Methods(http.MethodPost)
+	router.HandleFunc("/transactions/build", buildTransaction).Methods(http.MethodPost)
+	router.HandleFunc("/transactions/sign", signTransaction).Methods(http.MethodPost)
+	router.HandleFunc("/transactions/submit", submitTransaction).Methods(http.MethodPost)
+	router.HandleFunc("/multisig", multisigAddress).Methods(http.MethodPost)
+	router.HandleFunc("/multisig/transactions", multisigTransactions).Methods(http.MethodPost)
+	router.HandleFunc("/multisig/sign", multisigSign).Methods(http.MethodPost)
+	router.HandleFunc("/multisig/combine", multisigCombine).Methods(http.MethodPost)
+	router.HandleFunc("/multisig/submit", submitTransaction).Methods(http.MethodPost)
+	router.HandleFunc("/musig", muSigAddress).Methods(http.MethodPost)
+	router.HandleFunc("/musig/transactions", muSigTransactions).Methods(http.MethodPost)
+	router.HandleFunc("/musig/nonces", muSigNonces).Methods(http.MethodPost)
+	router.HandleFunc("/musig/sign", muSigSign).Methods(http.MethodPost)
+	router.HandleFunc("/musig/combine", muSigCombine).Methods(http.MethodPost)
+	router.HandleFunc("/musig/submit", muSigSubmit).Methods(http.MethodPost)
+	router.HandleFunc("/htlcs", htlcs).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/htlcs/claim", claimHTLC).Methods(http.MethodPost)
+	router.HandleFunc("/htlcs/refund", refundHTLC).Methods(http.MethodPost)
 	router.HandleFunc("/ws", p2p.Upgrade).Methods(http.MethodGet)
 	router.HandleFunc("/peers", peers).Methods(http.MethodGet, http.MethodPost)
 	fmt.Printf("📃 REST is Listening on http://localhost:%s\n", port)
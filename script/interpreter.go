@@ -0,0 +1,386 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+const (
+	// MaxScriptSize is the largest script, in bytes, the interpreter runs.
+	MaxScriptSize int = 1000
+	// MaxElementSize is the largest element, in bytes, a script can push.
+	MaxElementSize int = 520
+	// MaxOps is the largest number of non-push opcodes a script pair can execute.
+	MaxOps int = 201
+	// MaxStackSize is the largest number of elements the stack can hold.
+	MaxStackSize int = 1000
+	// MaxMultisigKeys is the largest number of keys OP_CHECKMULTISIG accepts.
+	MaxMultisigKeys int = 15
+
+	maxNumSize int = 5
+)
+
+var (
+	ErrScriptSize       = errors.New("script is too big")
+	ErrElementSize      = errors.New("pushed element is too big")
+	ErrOpCount          = errors.New("too many opcodes")
+	ErrStackSize        = errors.New("stack is too big")
+	ErrStackUnderflow   = errors.New("not enough elements on the stack")
+	ErrNotPushOnly      = errors.New("unlocking script must only push data")
+	ErrUnbalancedIf     = errors.New("unbalanced conditional")
+	ErrVerify           = errors.New("verify failed")
+	ErrEarlyReturn      = errors.New("OP_RETURN executed")
+	ErrInvalidNumber    = errors.New("invalid number")
+	ErrMultisigKeys     = errors.New("invalid number of multisig keys")
+	ErrLockTime         = errors.New("lock time not reached")
+	ErrNegativeLockTime = errors.New("negative lock time")
+	ErrEvalFalse        = errors.New("script evaluated to false")
+	ErrDataSize         = errors.New("data is too big")
+)
+
+// Checker checks the parts of a script that depend on the spending tx and the chain.
+type Checker interface {
+	// CheckSig reports whether signature is a valid signature of the spending tx by pubKey.
+	CheckSig(signature, pubKey []byte) bool
+	// CheckLockTime reports whether the chain has reached lockTime.
+	CheckLockTime(lockTime int64) bool
+}
+
+type engine struct {
+	stack   [][]byte
+	ops     int
+	checker Checker
+}
+
+// Execute runs unlocking followed by locking and returns nil if the output is unlocked.
+func Execute(unlocking, locking Script, checker Checker) error {
+	if !unlocking.IsPushOnly() {
+		return ErrNotPushOnly
+	}
+	e := &engine{checker: checker}
+	if err := e.run(unlocking); err != nil {
+		return err
+	}
+	if err := e.run(locking); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+func (e *engine) run(s Script) error {
+	if len(s) > MaxScriptSize {
+		return ErrScriptSize
+	}
+	instructions, err := parse(s)
+	if err != nil {
+		return err
+	}
+	var conditions []bool
+	for _, ins := range instructions {
+		if len(ins.data) > MaxElementSize {
+			return ErrElementSize
+		}
+		if !ins.op.isPush() {
+			e.ops++
+			if e.ops > MaxOps {
+				return ErrOpCount
+			}
+		}
+		executing := true
+		for _, condition := range conditions {
+			executing = executing && condition
+		}
+		switch ins.op {
+		case OpIf, OpNotIf:
+			condition := false
+			if executing {
+				top, err := e.pop()
+				if err != nil {
+					return err
+				}
+				condition = asBool(top) == (ins.op == OpIf)
+			}
+			conditions = append(conditions, condition)
+			continue
+		case OpElse:
+			if len(conditions) == 0 {
+				return ErrUnbalancedIf
+			}
+			conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+			continue
+		case OpEndIf:
+			if len(conditions) == 0 {
+				return ErrUnbalancedIf
+			}
+			conditions = conditions[:len(conditions)-1]
+			continue
+		}
+		if !executing {
+			continue
+		}
+		if err := e.step(ins); err != nil {
+			return err
+		}
+		if len(e.stack) > MaxStackSize {
+			return ErrStackSize
+		}
+	}
+	if len(conditions) != 0 {
+		return ErrUnbalancedIf
+	}
+	return nil
+}
+
+func (e *engine) step(ins instruction) error {
+	switch op := ins.op; {
+	case op.isSmallInt():
+		e.push(encodeNum(int64(op - Op1 + 1)))
+	case op.isPush():
+		e.push(ins.data)
+	case op == OpVerify:
+		return e.verify()
+	case op == OpReturn:
+		return ErrEarlyReturn
+	case op == OpDrop:
+		_, err := e.pop()
+		return err
+	case op == OpDup:
+		top, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(top)
+	case op == OpSwap:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(a)
+		e.push(b)
+	case op == OpSize:
+		top, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(encodeNum(int64(len(top))))
+	case op == OpEqual, op == OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(fromBool(bytes.Equal(a, b)))
+		if op == OpEqualVerify {
+			return e.verify()
+		}
+	case op == OpSHA256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		e.push(hash[:])
+	case op == OpCheckSig, op == OpCheckSigVerify:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(fromBool(e.checker.CheckSig(signature, pubKey)))
+		if op == OpCheckSigVerify {
+			return e.verify()
+		}
+	case op == OpCheckMultisig, op == OpCheckMultisigVerify:
+		if err := e.checkMultisig(); err != nil {
+			return err
+		}
+		if op == OpCheckMultisigVerify {
+			return e.verify()
+		}
+	case op == OpCheckLockTimeVerify:
+		top, err := e.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeNum(top)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return ErrNegativeLockTime
+		}
+		if !e.checker.CheckLockTime(lockTime) {
+			return ErrLockTime
+		}
+	default:
+		return ErrUnknownOpcode
+	}
+	return nil
+}
+
+// checkMultisig pops <sig...> <m> <pubKey...> <n> and pushes whether the signatures match the keys in order.
+func (e *engine) checkMultisig() error {
+	n, err := e.popInt(0, int64(MaxMultisigKeys))
+	if err != nil {
+		return err
+	}
+	e.ops += int(n)
+	if e.ops > MaxOps {
+		return ErrOpCount
+	}
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+	m, err := e.popInt(0, n)
+	if err != nil {
+		return err
+	}
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+	key := 0
+	for _, signature := range signatures {
+		for key < len(pubKeys) && !e.checker.CheckSig(signature, pubKeys[key]) {
+			key++
+		}
+		if key == len(pubKeys) {
+			e.push(fromBool(false))
+			return nil
+		}
+		key++
+	}
+	e.push(fromBool(true))
+	return nil
+}
+
+func (e *engine) push(data []byte) {
+	e.stack = append(e.stack, data)
+}
+
+func (e *engine) pop() ([]byte, error) {
+	top, err := e.peek()
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return top, nil
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *engine) popInt(min, max int64) (int64, error) {
+	top, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	n, err := decodeNum(top)
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, ErrMultisigKeys
+	}
+	return n, nil
+}
+
+func (e *engine) verify() error {
+	top, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(top) {
+		return ErrVerify
+	}
+	return nil
+}
+
+func asBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// negative zero is false
+			return !(i == len(data)-1 && b == 0x80)
+		}
+	}
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
+
+// encodeNum returns the minimal little-endian sign-magnitude encoding of n.
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var result []byte
+	for n > 0 {
+		result = append(result, byte(n&0xff))
+		n >>= 8
+	}
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// decodeNum returns the number of a minimally encoded element of at most maxNumSize bytes.
+func decodeNum(data []byte) (int64, error) {
+	if len(data) > maxNumSize {
+		return 0, ErrInvalidNumber
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	last := data[len(data)-1]
+	if last&0x7f == 0 && (len(data) == 1 || data[len(data)-2]&0x80 == 0) {
+		return 0, ErrInvalidNumber
+	}
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * i)
+	}
+	if last&0x80 != 0 {
+		n &= ^(int64(0x80) << (8 * (len(data) - 1)))
+		return -n, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,230 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeChecker struct {
+	height int64
+}
+
+func (fakeChecker) CheckSig(signature, pubKey []byte) bool {
+	return bytes.Equal(signature, fakeSign(pubKey))
+}
+
+func (f fakeChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= f.height
+}
+
+func fakeSign(pubKey []byte) []byte {
+	return append([]byte("sig-"), pubKey...)
+}
+
+func mustScript(s Script, err error) Script {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestExecuteTemplates(t *testing.T) {
+	alice, bob, carol := []byte("alice"), []byte("bob"), []byte("carol")
+	secret := []byte("secret")
+	type test struct {
+		name      string
+		unlocking Script
+		locking   Script
+		height    int64
+		want      error
+	}
+	p2pk := mustScript(PayToPubKey(alice))
+	p2pkh := mustScript(PayToPubKeyHash(Hash(alice)))
+	multisig := mustScript(Multisig(2, [][]byte{alice, bob, carol}))
+	hashLock := mustScript(HashLock(Hash(secret)))
+	timeLock := mustScript(TimeLock(100, alice))
+	tests := []test{
+		{"p2pk valid", mustScript(PushOnly(fakeSign(alice))), p2pk, 0, nil},
+		{"p2pk wrong key", mustScript(PushOnly(fakeSign(bob))), p2pk, 0, ErrEvalFalse},
+		{"p2pk empty unlocking", nil, p2pk, 0, ErrStackUnderflow},
+		{"p2pkh valid", mustScript(PushOnly(fakeSign(alice), alice)), p2pkh, 0, nil},
+		{"p2pkh wrong key", mustScript(PushOnly(fakeSign(bob), bob)), p2pkh, 0, ErrVerify},
+		{"p2pkh wrong signature", mustScript(PushOnly(fakeSign(bob), alice)), p2pkh, 0, ErrEvalFalse},
+		{"multisig first and second", mustScript(PushOnly(fakeSign(alice), fakeSign(bob))), multisig, 0, nil},
+		{"multisig first and third", mustScript(PushOnly(fakeSign(alice), fakeSign(carol))), multisig, 0, nil},
+		{"multisig out of order", mustScript(PushOnly(fakeSign(carol), fakeSign(alice))), multisig, 0, ErrEvalFalse},
+		{"multisig same key twice", mustScript(PushOnly(fakeSign(bob), fakeSign(bob))), multisig, 0, ErrEvalFalse},
+		{"multisig one signature", mustScript(PushOnly(fakeSign(bob))), multisig, 0, ErrStackUnderflow},
+		{"hash lock valid", mustScript(PushOnly(secret)), hashLock, 0, nil},
+		{"hash lock wrong preimage", mustScript(PushOnly([]byte("guess"))), hashLock, 0, ErrEvalFalse},
+		{"time lock reached", mustScript(PushOnly(fakeSign(alice))), timeLock, 100, nil},
+		{"time lock not reached", mustScript(PushOnly(fakeSign(alice))), timeLock, 99, ErrLockTime},
+		{"time lock wrong key", mustScript(PushOnly(fakeSign(bob))), timeLock, 100, ErrEvalFalse},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Execute(tc.unlocking, tc.locking, fakeChecker{height: tc.height})
+			if got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteOpcodes(t *testing.T) {
+	type test struct {
+		name    string
+		locking Script
+		want    error
+	}
+	tests := []test{
+		{"OP_1", NewBuilder().AddOp(Op1).script, nil},
+		{"OP_0", NewBuilder().AddOp(Op0).script, ErrEvalFalse},
+		{"empty", nil, ErrEvalFalse},
+		{"OP_VERIFY true", NewBuilder().AddInt(2).AddOp(OpVerify).AddInt(1).script, nil},
+		{"OP_VERIFY false", NewBuilder().AddInt(0).AddOp(OpVerify).AddInt(1).script, ErrVerify},
+		{"OP_RETURN", NewBuilder().AddOp(OpReturn).AddData([]byte("data")).script, ErrEarlyReturn},
+		{"OP_DROP", NewBuilder().AddInt(1).AddInt(0).AddOp(OpDrop).script, nil},
+		{"OP_DROP underflow", NewBuilder().AddOp(OpDrop).script, ErrStackUnderflow},
+		{"OP_DUP", NewBuilder().AddInt(3).AddOp(OpDup).AddOp(OpEqual).script, nil},
+		{"OP_DUP underflow", NewBuilder().AddOp(OpDup).script, ErrStackUnderflow},
+		{"OP_SWAP", NewBuilder().AddInt(0).AddInt(1).AddOp(OpSwap).AddOp(OpDrop).script, nil},
+		{"OP_SWAP underflow", NewBuilder().AddInt(1).AddOp(OpSwap).script, ErrStackUnderflow},
+		{"OP_SIZE", NewBuilder().AddData([]byte("abc")).AddOp(OpSize).AddInt(3).AddOp(OpEqualVerify).script, nil},
+		{"OP_EQUAL false", NewBuilder().AddInt(1).AddInt(2).AddOp(OpEqual).script, ErrEvalFalse},
+		{"OP_EQUALVERIFY false", NewBuilder().AddInt(1).AddInt(2).AddOp(OpEqualVerify).script, ErrVerify},
+		{"OP_SHA256", NewBuilder().AddData([]byte("x")).AddOp(OpSHA256).AddData(Hash([]byte("x"))).AddOp(OpEqual).script, nil},
+		{"OP_IF taken", NewBuilder().AddInt(1).AddOp(OpIf).AddInt(1).AddOp(OpElse).AddInt(0).AddOp(OpEndIf).script, nil},
+		{"OP_IF else", NewBuilder().AddInt(0).AddOp(OpIf).AddInt(0).AddOp(OpElse).AddInt(1).AddOp(OpEndIf).script, nil},
+		{"OP_NOTIF", NewBuilder().AddInt(0).AddOp(OpNotIf).AddInt(1).AddOp(OpEndIf).script, nil},
+		{"OP_IF skips OP_RETURN", NewBuilder().AddInt(0).AddOp(OpIf).AddOp(OpReturn).AddOp(OpEndIf).AddInt(1).script, nil},
+		{"nested OP_IF", NewBuilder().AddInt(1).AddInt(0).AddOp(OpSwap).AddOp(OpIf).AddOp(OpIf).AddInt(0).AddOp(OpElse).AddInt(1).AddOp(OpEndIf).AddOp(OpEndIf).script, nil},
+		{"OP_IF without OP_ENDIF", NewBuilder().AddInt(1).AddOp(OpIf).AddInt(1).script, ErrUnbalancedIf},
+		{"OP_ELSE without OP_IF", NewBuilder().AddOp(OpElse).script, ErrUnbalancedIf},
+		{"OP_ENDIF without OP_IF", NewBuilder().AddOp(OpEndIf).script, ErrUnbalancedIf},
+		{"OP_IF underflow", NewBuilder().AddOp(OpIf).AddOp(OpEndIf).script, ErrStackUnderflow},
+		{"OP_CHECKLOCKTIMEVERIFY negative", NewBuilder().AddInt(-1).AddOp(OpCheckLockTimeVerify).script, ErrNegativeLockTime},
+		{"OP_CHECKLOCKTIMEVERIFY oversized", NewBuilder().AddData([]byte{1, 2, 3, 4, 5, 6}).AddOp(OpCheckLockTimeVerify).script, ErrInvalidNumber},
+		{"OP_CHECKLOCKTIMEVERIFY non-minimal", NewBuilder().AddData([]byte{1, 0}).AddOp(OpCheckLockTimeVerify).script, ErrInvalidNumber},
+		{"OP_CHECKMULTISIG too many keys", NewBuilder().AddInt(1).AddInt(16).AddOp(OpCheckMultisig).script, ErrMultisigKeys},
+		{"OP_CHECKMULTISIG more signatures than keys", NewBuilder().AddInt(2).AddData([]byte("a")).AddInt(1).AddOp(OpCheckMultisig).script, ErrMultisigKeys},
+		{"OP_CHECKMULTISIG zero of zero", NewBuilder().AddInt(0).AddInt(0).AddOp(OpCheckMultisig).script, nil},
+		{"OP_CHECKSIGVERIFY fails", NewBuilder().AddData([]byte("bad")).AddData([]byte("key")).AddOp(OpCheckSigVerify).script, ErrVerify},
+		{"OP_CHECKMULTISIGVERIFY passes", NewBuilder().AddData(fakeSign([]byte("a"))).AddInt(1).AddData([]byte("a")).AddInt(1).AddOp(OpCheckMultisigVerify).AddInt(1).script, nil},
+		{"unknown opcode", Script{0xff}, ErrUnknownOpcode},
+		{"truncated push", Script{0x05, 0x01}, ErrMalformedScript},
+		{"truncated OP_PUSHDATA1", Script{byte(OpPushData1)}, ErrMalformedScript},
+		{"truncated OP_PUSHDATA2", Script{byte(OpPushData2), 0x01}, ErrMalformedScript},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Execute(nil, tc.locking, fakeChecker{height: 10})
+			if got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	t.Run("Unlocking script should be push only.", func(t *testing.T) {
+		unlocking := NewBuilder().AddInt(1).AddOp(OpDup).script
+		if err := Execute(unlocking, Script{byte(Op1)}, fakeChecker{}); err != ErrNotPushOnly {
+			t.Errorf("Expected %v, got %v", ErrNotPushOnly, err)
+		}
+	})
+	t.Run("Script should not exceed MaxScriptSize.", func(t *testing.T) {
+		locking := bytes.Repeat([]byte{byte(Op1)}, MaxScriptSize+1)
+		if err := Execute(nil, locking, fakeChecker{}); err != ErrScriptSize {
+			t.Errorf("Expected %v, got %v", ErrScriptSize, err)
+		}
+	})
+	t.Run("Script should not exceed MaxOps.", func(t *testing.T) {
+		b := NewBuilder().AddInt(1)
+		for i := 0; i <= MaxOps; i++ {
+			b.AddOp(OpDup).AddOp(OpDrop)
+		}
+		if err := Execute(nil, b.script, fakeChecker{}); err != ErrOpCount {
+			t.Errorf("Expected %v, got %v", ErrOpCount, err)
+		}
+	})
+	t.Run("Stack should not exceed MaxStackSize.", func(t *testing.T) {
+		unlocking := bytes.Repeat([]byte{byte(Op1)}, MaxStackSize/2)
+		locking := bytes.Repeat([]byte{byte(Op1)}, MaxStackSize/2+1)
+		if err := Execute(unlocking, locking, fakeChecker{}); err != ErrStackSize {
+			t.Errorf("Expected %v, got %v", ErrStackSize, err)
+		}
+	})
+	t.Run("Pushes should not exceed MaxElementSize.", func(t *testing.T) {
+		if _, err := NewBuilder().AddData(make([]byte, MaxElementSize+1)).Script(); err != ErrElementSize {
+			t.Errorf("Expected %v, got %v", ErrElementSize, err)
+		}
+		locking := append(Script{byte(OpPushData2), 0x09, 0x02}, make([]byte, MaxElementSize+1)...)
+		if err := Execute(nil, locking, fakeChecker{}); err != ErrElementSize {
+			t.Errorf("Expected %v, got %v", ErrElementSize, err)
+		}
+	})
+}
+
+func TestNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 16, 127, 128, -128, 255, 256, -32768, 1 << 31, -(1 << 31)} {
+		got, err := decodeNum(encodeNum(n))
+		if err != nil || got != n {
+			t.Errorf("Expected %d, got %d (%v)", n, got, err)
+		}
+	}
+	for _, b := range [][]byte{{0x00}, {0x80}, {0x01, 0x00}, {0x7f, 0x80}} {
+		if _, err := decodeNum(b); err != ErrInvalidNumber {
+			t.Errorf("Expected %x to be non-minimal", b)
+		}
+	}
+}
+
+func TestAsBool(t *testing.T) {
+	type test struct {
+		input []byte
+		want  bool
+	}
+	tests := []test{
+		{nil, false},
+		{[]byte{0}, false},
+		{[]byte{0, 0x80}, false},
+		{[]byte{0x80}, false},
+		{[]byte{1}, true},
+		{[]byte{0x80, 0}, true},
+	}
+	for _, tc := range tests {
+		if got := asBool(tc.input); got != tc.want {
+			t.Errorf("asBool(%x): Expected %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+func TestExecuteHTLC(t *testing.T) {
+	alice, bob := []byte("alice"), []byte("bob")
+	preimage := []byte("preimage")
+	htlc := mustScript(HTLC(Hash(preimage), alice, 50, bob))
+	type test struct {
+		name      string
+		unlocking Script
+		height    int64
+		want      error
+	}
+	tests := []test{
+		{"claim with preimage", mustScript(HTLCClaim(fakeSign(alice), preimage)), 0, nil},
+		{"claim after timeout", mustScript(HTLCClaim(fakeSign(alice), preimage)), 60, nil},
+		{"claim with wrong preimage", mustScript(HTLCClaim(fakeSign(alice), []byte("guess"))), 0, ErrVerify},
+		{"claim by refund key", mustScript(HTLCClaim(fakeSign(bob), preimage)), 0, ErrEvalFalse},
+		{"refund after timeout", mustScript(HTLCRefund(fakeSign(bob))), 50, nil},
+		{"refund before timeout", mustScript(HTLCRefund(fakeSign(bob))), 49, ErrLockTime},
+		{"refund by recipient key", mustScript(HTLCRefund(fakeSign(alice))), 50, ErrEvalFalse},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Execute(tc.unlocking, htlc, fakeChecker{height: tc.height})
+			if got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package script implements the small stack-based language used to lock and unlock transaction outputs
+package script
+
+// Opcode is a single instruction of a script.
+type Opcode byte
+
+const (
+	Op0         Opcode = 0x00
+	OpPushData1 Opcode = 0x4c
+	OpPushData2 Opcode = 0x4d
+	Op1         Opcode = 0x51
+	Op16        Opcode = 0x60
+
+	OpIf     Opcode = 0x63
+	OpNotIf  Opcode = 0x64
+	OpElse   Opcode = 0x67
+	OpEndIf  Opcode = 0x68
+	OpVerify Opcode = 0x69
+	OpReturn Opcode = 0x6a
+
+	OpDrop Opcode = 0x75
+	OpDup  Opcode = 0x76
+	OpSwap Opcode = 0x7c
+	OpSize Opcode = 0x82
+
+	OpEqual       Opcode = 0x87
+	OpEqualVerify Opcode = 0x88
+
+	OpSHA256              Opcode = 0xa8
+	OpCheckSig            Opcode = 0xac
+	OpCheckSigVerify      Opcode = 0xad
+	OpCheckMultisig       Opcode = 0xae
+	OpCheckMultisigVerify Opcode = 0xaf
+	OpCheckLockTimeVerify Opcode = 0xb1
+)
+
+var opcodeNames = map[Opcode]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSHA256:              "OP_SHA256",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultisig:       "OP_CHECKMULTISIG",
+	OpCheckMultisigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+// isPush reports whether op only pushes data onto the stack.
+func (op Opcode) isPush() bool {
+	return op <= OpPushData2 || op.isSmallInt()
+}
+
+// isSmallInt reports whether op is one of OP_1 ... OP_16.
+func (op Opcode) isSmallInt() bool {
+	return op >= Op1 && op <= Op16
+}
@@ -0,0 +1,186 @@
+package script
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Script is a serialized list of opcodes and data pushes.
+type Script []byte
+
+type instruction struct {
+	op   Opcode
+	data []byte
+}
+
+// ErrMalformedScript returns ERROR if a script can not be parsed into instructions.
+var ErrMalformedScript = errors.New("malformed script")
+
+// ErrUnknownOpcode returns ERROR if a script contains an opcode the interpreter does not know.
+var ErrUnknownOpcode = errors.New("unknown opcode")
+
+// FromHex returns the script of a hex-encoded string.
+func FromHex(s string) (Script, error) {
+	return hex.DecodeString(s)
+}
+
+// String returns the hex-encoded script.
+func (s Script) String() string {
+	return hex.EncodeToString(s)
+}
+
+// IsPushOnly reports whether the script only pushes data.
+func (s Script) IsPushOnly() bool {
+	instructions, err := parse(s)
+	if err != nil {
+		return false
+	}
+	for _, ins := range instructions {
+		if !ins.op.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUnspendable reports whether the script can never be satisfied.
+func (s Script) IsUnspendable() bool {
+	return len(s) > 0 && Opcode(s[0]) == OpReturn
+}
+
+// PushedData returns the data pushed by a script.
+func (s Script) PushedData() ([][]byte, error) {
+	instructions, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	var pushes [][]byte
+	for _, ins := range instructions {
+		switch {
+		case ins.op.isSmallInt():
+			pushes = append(pushes, encodeNum(int64(ins.op-Op1+1)))
+		case ins.op.isPush():
+			pushes = append(pushes, ins.data)
+		}
+	}
+	return pushes, nil
+}
+
+// Disassemble returns the human readable form of the script.
+func (s Script) Disassemble() string {
+	instructions, err := parse(s)
+	if err != nil {
+		return fmt.Sprintf("[%s]", err)
+	}
+	var words []string
+	for _, ins := range instructions {
+		switch {
+		case ins.op.isSmallInt():
+			words = append(words, fmt.Sprintf("OP_%d", ins.op-Op1+1))
+		case ins.op == Op0:
+			words = append(words, opcodeNames[Op0])
+		case ins.op.isPush():
+			words = append(words, hex.EncodeToString(ins.data))
+		default:
+			words = append(words, opcodeNames[ins.op])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func parse(s Script) ([]instruction, error) {
+	var instructions []instruction
+	for i := 0; i < len(s); {
+		op := Opcode(s[i])
+		i++
+		var size int
+		switch {
+		case op > Op0 && op < OpPushData1:
+			size = int(op)
+		case op == OpPushData1:
+			if i+1 > len(s) {
+				return nil, ErrMalformedScript
+			}
+			size = int(s[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(s) {
+				return nil, ErrMalformedScript
+			}
+			size = int(s[i]) | int(s[i+1])<<8
+			i += 2
+		default:
+			if _, ok := opcodeNames[op]; !ok && !op.isSmallInt() {
+				return nil, ErrUnknownOpcode
+			}
+		}
+		if i+size > len(s) {
+			return nil, ErrMalformedScript
+		}
+		instructions = append(instructions, instruction{op: op, data: s[i : i+size]})
+		i += size
+	}
+	return instructions, nil
+}
+
+// Builder builds scripts with minimal data pushes.
+type Builder struct {
+	script Script
+	err    error
+}
+
+// NewBuilder returns an empty script builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// AddOp appends an opcode.
+func (b *Builder) AddOp(op Opcode) *Builder {
+	b.script = append(b.script, byte(op))
+	return b
+}
+
+// AddData appends a push of data using the smallest push opcode.
+func (b *Builder) AddData(data []byte) *Builder {
+	switch size := len(data); {
+	case size > MaxElementSize:
+		b.err = ErrElementSize
+	case size == 0:
+		b.script = append(b.script, byte(Op0))
+	case size < int(OpPushData1):
+		b.script = append(b.script, byte(size))
+		b.script = append(b.script, data...)
+	case size <= 0xff:
+		b.script = append(b.script, byte(OpPushData1), byte(size))
+		b.script = append(b.script, data...)
+	default:
+		b.script = append(b.script, byte(OpPushData2), byte(size), byte(size>>8))
+		b.script = append(b.script, data...)
+	}
+	return b
+}
+
+// AddInt appends a push of n, using OP_0 ... OP_16 when possible.
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + Opcode(n-1))
+	default:
+		return b.AddData(encodeNum(n))
+	}
+}
+
+// Script returns the built script.
+func (b *Builder) Script() (Script, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MaxScriptSize {
+		return nil, ErrScriptSize
+	}
+	return b.script, nil
+}
@@ -0,0 +1,126 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBuilderAddData(t *testing.T) {
+	type test struct {
+		size   int
+		prefix []byte
+	}
+	tests := []test{
+		{0, []byte{byte(Op0)}},
+		{1, []byte{0x01}},
+		{75, []byte{75}},
+		{76, []byte{byte(OpPushData1), 76}},
+		{255, []byte{byte(OpPushData1), 0xff}},
+		{256, []byte{byte(OpPushData2), 0x00, 0x01}},
+	}
+	for _, tc := range tests {
+		data := bytes.Repeat([]byte{0xaa}, tc.size)
+		s, err := NewBuilder().AddData(data).Script()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(s, tc.prefix) || len(s) != len(tc.prefix)+tc.size {
+			t.Errorf("AddData(%d bytes): got %x", tc.size, s[:len(tc.prefix)])
+		}
+		pushes, err := s.PushedData()
+		if err != nil || len(pushes) != 1 || !bytes.Equal(pushes[0], data) {
+			t.Errorf("PushedData() should return the %d pushed bytes", tc.size)
+		}
+	}
+}
+
+func TestBuilderAddInt(t *testing.T) {
+	s, _ := NewBuilder().AddInt(0).AddInt(1).AddInt(16).AddInt(17).AddInt(-1).Script()
+	expected := Script{byte(Op0), byte(Op1), byte(Op16), 0x01, 17, 0x01, 0x81}
+	if !bytes.Equal(s, expected) {
+		t.Errorf("Expected %x, got %x", expected, s)
+	}
+}
+
+func TestFromHex(t *testing.T) {
+	s, _ := PayToPubKey([]byte{0xab})
+	restored, err := FromHex(s.String())
+	if err != nil || !bytes.Equal(s, restored) {
+		t.Errorf("FromHex() should restore %s", s)
+	}
+	if _, err := FromHex("xyz"); err == nil {
+		t.Error("Expected ERROR, got nil")
+	}
+}
+
+func TestIsPushOnly(t *testing.T) {
+	pushOnly, _ := PushOnly([]byte("a"), nil)
+	if !pushOnly.IsPushOnly() {
+		t.Error("PushOnly() should be push only")
+	}
+	locking, _ := PayToPubKey([]byte("a"))
+	if locking.IsPushOnly() {
+		t.Error("PayToPubKey() should not be push only")
+	}
+}
+
+func TestIsUnspendable(t *testing.T) {
+	s, _ := NewBuilder().AddOp(OpReturn).AddData([]byte("hash")).Script()
+	if !s.IsUnspendable() {
+		t.Error("OP_RETURN scripts should be unspendable")
+	}
+	if (Script{byte(Op1)}).IsUnspendable() {
+		t.Error("OP_1 should be spendable")
+	}
+}
+
+func ExampleScript_Disassemble() {
+	s, _ := Multisig(2, [][]byte{{0xaa}, {0xbb}, {0xcc}})
+	fmt.Println(s.Disassemble())
+	// Output: OP_2 aa bb cc OP_3 OP_CHECKMULTISIG
+}
+
+func ExamplePayToPubKeyHash() {
+	s, _ := PayToPubKeyHash([]byte{0x01, 0x02})
+	fmt.Println(s.Disassemble())
+	// Output: OP_DUP OP_SHA256 0102 OP_EQUALVERIFY OP_CHECKSIG
+}
+
+func TestParseHTLC(t *testing.T) {
+	for _, lockTime := range []int64{7, 1000} {
+		s, _ := HTLC([]byte{0x01}, []byte{0x02}, lockTime, []byte{0x03})
+		terms, ok := ParseHTLC(s)
+		if !ok {
+			t.Fatalf("ParseHTLC() should parse %s", s.Disassemble())
+		}
+		if !bytes.Equal(terms.Hash, []byte{0x01}) || !bytes.Equal(terms.Recipient, []byte{0x02}) || terms.LockTime != lockTime || !bytes.Equal(terms.Refund, []byte{0x03}) {
+			t.Errorf("ParseHTLC() returned wrong terms %+v", terms)
+		}
+	}
+	s, _ := PayToPubKey([]byte{0x01})
+	if _, ok := ParseHTLC(s); ok {
+		t.Error("ParseHTLC() should not parse pay-to-pubkey")
+	}
+}
+
+func TestNullData(t *testing.T) {
+	data := []byte("document hash")
+	s, err := NullData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsUnspendable() {
+		t.Error("NullData() should be unspendable")
+	}
+	parsed, ok := ParseNullData(s)
+	if !ok || !bytes.Equal(parsed, data) {
+		t.Errorf("ParseNullData() should return %s, got %s", data, parsed)
+	}
+	if _, err := NullData(make([]byte, MaxDataSize+1)); err != ErrDataSize {
+		t.Errorf("Expected %v, got %v", ErrDataSize, err)
+	}
+	if err := Execute(nil, s, fakeChecker{}); err != ErrEarlyReturn {
+		t.Errorf("Expected %v, got %v", ErrEarlyReturn, err)
+	}
+}
@@ -0,0 +1,150 @@
+package script
+
+import "crypto/sha256"
+
+// PayToPubKey returns a script spendable by a signature of pubKey.
+func PayToPubKey(pubKey []byte) (Script, error) {
+	return NewBuilder().AddData(pubKey).AddOp(OpCheckSig).Script()
+}
+
+// PayToPubKeyHash returns a script spendable by a public key hashing to pubKeyHash and its signature.
+func PayToPubKeyHash(pubKeyHash []byte) (Script, error) {
+	return NewBuilder().
+		AddOp(OpDup).
+		AddOp(OpSHA256).
+		AddData(pubKeyHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+// Multisig returns a script spendable by signatures of "required" of pubKeys, given in the order of pubKeys.
+func Multisig(required int, pubKeys [][]byte) (Script, error) {
+	if required < 1 || required > len(pubKeys) || len(pubKeys) > MaxMultisigKeys {
+		return nil, ErrMultisigKeys
+	}
+	b := NewBuilder().AddInt(int64(required))
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+	return b.AddInt(int64(len(pubKeys))).AddOp(OpCheckMultisig).Script()
+}
+
+// HashLock returns a script spendable by anyone revealing the SHA-256 preimage of hash.
+func HashLock(hash []byte) (Script, error) {
+	return NewBuilder().AddOp(OpSHA256).AddData(hash).AddOp(OpEqual).Script()
+}
+
+// TimeLock returns a script spendable by a signature of pubKey once the chain reaches lockTime.
+func TimeLock(lockTime int64, pubKey []byte) (Script, error) {
+	return NewBuilder().
+		AddInt(lockTime).
+		AddOp(OpCheckLockTimeVerify).
+		AddOp(OpDrop).
+		AddData(pubKey).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+// PushOnly returns an unlocking script pushing every element of data in order.
+func PushOnly(data ...[]byte) (Script, error) {
+	b := NewBuilder()
+	for _, d := range data {
+		b.AddData(d)
+	}
+	return b.Script()
+}
+
+// Hash returns the SHA-256 hash used by OP_SHA256.
+func Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// HTLC returns a script spendable by recipient revealing the SHA-256 preimage of hash, or by refund once the chain reaches lockTime.
+func HTLC(hash, recipient []byte, lockTime int64, refund []byte) (Script, error) {
+	return NewBuilder().
+		AddOp(OpIf).
+		AddOp(OpSHA256).
+		AddData(hash).
+		AddOp(OpEqualVerify).
+		AddData(recipient).
+		AddOp(OpCheckSig).
+		AddOp(OpElse).
+		AddInt(lockTime).
+		AddOp(OpCheckLockTimeVerify).
+		AddOp(OpDrop).
+		AddData(refund).
+		AddOp(OpCheckSig).
+		AddOp(OpEndIf).
+		Script()
+}
+
+// HTLCClaim returns the unlocking script of the preimage path of an HTLC.
+func HTLCClaim(signature, preimage []byte) (Script, error) {
+	return NewBuilder().AddData(signature).AddData(preimage).AddInt(1).Script()
+}
+
+// HTLCRefund returns the unlocking script of the timeout path of an HTLC.
+func HTLCRefund(signature []byte) (Script, error) {
+	return NewBuilder().AddData(signature).AddInt(0).Script()
+}
+
+// HTLCTerms are the terms of an HTLC script.
+type HTLCTerms struct {
+	Hash      []byte
+	Recipient []byte
+	LockTime  int64
+	Refund    []byte
+}
+
+// ParseHTLC returns the terms of s if it is an HTLC script.
+func ParseHTLC(s Script) (*HTLCTerms, bool) {
+	instructions, err := parse(s)
+	if err != nil || len(instructions) != 13 {
+		return nil, false
+	}
+	// pushes are matched by the data positions below
+	expected := []Opcode{OpIf, OpSHA256, Op0, OpEqualVerify, Op0, OpCheckSig, OpElse, Op0, OpCheckLockTimeVerify, OpDrop, Op0, OpCheckSig, OpEndIf}
+	for index, op := range expected {
+		ins := instructions[index]
+		if (op == Op0 && !ins.op.isPush()) || (op != Op0 && ins.op != op) {
+			return nil, false
+		}
+	}
+	lockTime := instructions[7]
+	lockTimeBytes := lockTime.data
+	if lockTime.op.isSmallInt() {
+		lockTimeBytes = encodeNum(int64(lockTime.op - Op1 + 1))
+	}
+	n, err := decodeNum(lockTimeBytes)
+	if err != nil {
+		return nil, false
+	}
+	return &HTLCTerms{
+		Hash:      instructions[2].data,
+		Recipient: instructions[4].data,
+		LockTime:  n,
+		Refund:    instructions[10].data,
+	}, true
+}
+
+// MaxDataSize is the largest payload, in bytes, a data-carrying output can hold.
+const MaxDataSize int = 80
+
+// NullData returns a provably unspendable script carrying data.
+func NullData(data []byte) (Script, error) {
+	if len(data) > MaxDataSize {
+		return nil, ErrDataSize
+	}
+	return NewBuilder().AddOp(OpReturn).AddData(data).Script()
+}
+
+// ParseNullData returns the data of s if it is a data-carrying script.
+func ParseNullData(s Script) ([]byte, bool) {
+	instructions, err := parse(s)
+	if err != nil || len(instructions) != 2 || instructions[0].op != OpReturn || !instructions[1].op.isPush() {
+		return nil, false
+	}
+	return instructions[1].data, true
+}
@@ -5,11 +5,9 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 )
 
@@ -45,24 +43,8 @@ func Hash(i interface{}) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-func EncodeBigInts(bigA, bigB *big.Int) string {
-	bytes := append(bigA.Bytes(), bigB.Bytes()...)
-	return fmt.Sprintf("%x", bytes)
-}
-
-func RestoreBigInts(payload string) (*big.Int, *big.Int, error) {
-	payloadBytes, err := hex.DecodeString(payload)
-	if err != nil {
-		return nil, nil, err
-	}
-	bigA, bigB := big.Int{}, big.Int{}
-	halfLength := len(payloadBytes) / 2
-	firstHalfBytes := payloadBytes[:halfLength]
-	secondHalfBytes := payloadBytes[halfLength:]
-	bigA.SetBytes(firstHalfBytes)
-	bigB.SetBytes(secondHalfBytes)
-	return &bigA, &bigB, nil
-}
+// BigIntSize is the width, in bytes, of the coordinates, scalars and signature halves of 256-bit keys.
+const BigIntSize int = 32
 
 func Splitter(s, sep string, index int) string {
 	r := strings.Split(s, sep)
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
 	"reflect"
 	"testing"
 )
@@ -135,50 +134,3 @@ func ExampleFromBytes() {
 	fmt.Println(restored)
 	// Output: {test}
 }
-
-func TestEncodeBigInts(t *testing.T) {
-	bigA := big.NewInt(123123)
-	bigB := big.NewInt(456456)
-	expected := "01e0f306f708"
-	s := EncodeBigInts(bigA, bigB)
-	if s != expected {
-		t.Errorf("Expected: %s, got: %s", expected, s)
-	}
-}
-
-func ExampleEncodeBigInts() {
-	bigA := big.NewInt(123123)
-	bigB := big.NewInt(456456)
-	s := EncodeBigInts(bigA, bigB)
-	fmt.Println(s)
-	// Output: 01e0f306f708
-}
-
-func TestRestoreBigInts(t *testing.T) {
-	t.Run("RestoreBigInts should return two-seperated bigInts", func(t *testing.T) {
-		i := "01e0f306f708"
-		var expectedBigA int64 = 123123
-		var expectedBigB int64 = 456456
-		bigA, bigB, _ := RestoreBigInts(i)
-		if !reflect.DeepEqual(bigA, big.NewInt(expectedBigA)) {
-			t.Errorf("Expected: %v, got: %v", expectedBigA, bigA)
-		}
-		if !reflect.DeepEqual(bigB, big.NewInt(expectedBigB)) {
-			t.Errorf("Expected: %v, got: %v", expectedBigB, bigB)
-		}
-	})
-	t.Run("RestoreBigInts should return ERROR when Input is invalid", func(t *testing.T) {
-		i := "0245l2"
-		_, _, err := RestoreBigInts(i)
-		if err == nil {
-			t.Error("Expected ERROR, got nil")
-		}
-	})
-}
-
-func ExampleRestoreBigInts() {
-	i := "01e0f306f708"
-	bigA, bigB, _ := RestoreBigInts(i)
-	fmt.Println(bigA, bigB)
-	// Output: 123123 456456
-}
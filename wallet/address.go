@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// Network tells apart addresses, extended public keys and WIF private keys of different chains by their version.
+type Network struct {
+	Name        string
+	Version     byte
+	XPubVersion uint32
+	WIFVersion  byte
+}
+
+var (
+	// MainNet is the network of the main chain.
+	MainNet = Network{Name: "mainnet", Version: 0x35, XPubVersion: 0x0488b21e, WIFVersion: 0xb5}
+	// TestNet is the network of test chains.
+	TestNet = Network{Name: "testnet", Version: 0x6f, XPubVersion: 0x043587cf, WIFVersion: 0xef}
+)
+
+var network = MainNet
+
+const (
+	base58Alphabet string = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	checksumLength int    = 4
+	pubKeyHashSize int    = sha256.Size
+)
+
+var (
+	// ErrInvalidAddress returns ERROR if an address is neither a checksummed address nor a legacy hex address.
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrChecksum returns ERROR if the checksum of an address does not match, usually because of a typo.
+	ErrChecksum = errors.New("address checksum mismatch")
+	// ErrWrongNetwork returns ERROR if an address belongs to another network.
+	ErrWrongNetwork = errors.New("address of another network")
+	// ErrUnknownNetwork returns ERROR if a network name is not known.
+	ErrUnknownNetwork = errors.New("unknown network")
+)
+
+// SetNetwork selects the network whose addresses the node creates and accepts.
+func SetNetwork(name string) error {
+	for _, known := range []Network{MainNet, TestNet} {
+		if known.Name == name {
+			network = known
+			return nil
+		}
+	}
+	return ErrUnknownNetwork
+}
+
+// AddressFromPublicKey returns the address of a hex-encoded public key.
+// Addresses of keys other than P256 carry the tag of their key type after the network version.
+// Schnorr addresses hold the x-only public key instead of its hash.
+func AddressFromPublicKey(publicKey string) (string, error) {
+	publicKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return "", err
+	}
+	keyType := taggedKeyType(publicKeyBytes, publicKeyLengths)
+	if keyType == Schnorr {
+		return encodeAddress(network.Version, Schnorr, publicKeyBytes[1:]), nil
+	}
+	hash := sha256.Sum256(publicKeyBytes)
+	return encodeAddress(network.Version, keyType, hash[:]), nil
+}
+
+func encodeAddress(version byte, keyType KeyType, pubKeyHash []byte) string {
+	payload := []byte{version}
+	if tag, ok := keyTypeTags[keyType]; ok {
+		payload = append(payload, tag)
+	}
+	payload = append(payload, pubKeyHash...)
+	return base58Encode(append(payload, checksum(payload)...))
+}
+
+// DecodeAddress returns the public key hash of a checksummed address of the current network.
+func DecodeAddress(address string) ([]byte, error) {
+	_, pubKeyHash, err := decodeAddress(address)
+	return pubKeyHash, err
+}
+
+// decodeAddress returns the key type and the public key hash of a checksummed address of the current network,
+// or the x-only public key of a Schnorr address.
+func decodeAddress(address string) (KeyType, []byte, error) {
+	decoded, err := base58Decode(address)
+	if err != nil || len(decoded) < 1+pubKeyHashSize+checksumLength || len(decoded) > 2+pubKeyHashSize+checksumLength {
+		return "", nil, ErrInvalidAddress
+	}
+	payload, sum := decoded[:len(decoded)-checksumLength], decoded[len(decoded)-checksumLength:]
+	if !bytes.Equal(checksum(payload), sum) {
+		return "", nil, ErrChecksum
+	}
+	if payload[0] != network.Version {
+		return "", nil, ErrWrongNetwork
+	}
+	if len(payload) == 1+pubKeyHashSize {
+		return P256, payload[1:], nil
+	}
+	for keyType, tag := range keyTypeTags {
+		if payload[1] != tag {
+			continue
+		}
+		if keyType == Schnorr {
+			if _, err := liftX(payload[2:]); err != nil {
+				return "", nil, ErrInvalidAddress
+			}
+		}
+		return keyType, payload[2:], nil
+	}
+	return "", nil, ErrUnknownKeyType
+}
+
+// IsLegacyAddress reports whether address is a hex-encoded public key, the address format before checksums.
+func IsLegacyAddress(address string) bool {
+	_, err := legacyPublicKey(address)
+	return err == nil
+}
+
+// publicKeyAddresses returns the address of a public key and, for P256 keys, its legacy address and,
+// if its coordinates have leading zero bytes, the addresses it had before big ints had a fixed width.
+func publicKeyAddresses(publicKey crypto.PublicKey) []string {
+	encoded := encodePublicKey(publicKey)
+	if publicKeyType(publicKey) != P256 {
+		return []string{mustAddress(encoded)}
+	}
+	legacy := encoded
+	addresses := []string{mustAddress(legacy), legacy}
+	key := publicKey.(*ecdsa.PublicKey)
+	if unpadded := fmt.Sprintf("%x", append(key.X.Bytes(), key.Y.Bytes()...)); unpadded != legacy {
+		addresses = append(addresses, mustAddress(unpadded), unpadded)
+	}
+	return addresses
+}
+
+// legacyPublicKey returns the public key of a hex-encoded X||Y.
+// Keys used to be encoded without padding, so shorter encodings are split wherever both halves form a point on the curve.
+func legacyPublicKey(address string) (*ecdsa.PublicKey, error) {
+	publicKeyBytes, err := hex.DecodeString(address)
+	if err != nil || len(publicKeyBytes) == 0 || len(publicKeyBytes) > 2*utils.BigIntSize {
+		return nil, ErrInvalidAddress
+	}
+	var publicKey *ecdsa.PublicKey
+	splitBigInts(publicKeyBytes, func(x, y *big.Int) bool {
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return false
+		}
+		publicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		return true
+	})
+	if publicKey == nil {
+		return nil, ErrInvalidAddress
+	}
+	return publicKey, nil
+}
+
+// splitBigInts calls try with every split of b into two big ints of at most BigIntSize bytes, until try returns true.
+func splitBigInts(b []byte, try func(a, b *big.Int) bool) bool {
+	aLength, maxALength := len(b)-utils.BigIntSize, utils.BigIntSize
+	if aLength < 0 {
+		aLength = 0
+	}
+	if maxALength > len(b) {
+		maxALength = len(b)
+	}
+	for ; aLength <= maxALength; aLength++ {
+		if try(new(big.Int).SetBytes(b[:aLength]), new(big.Int).SetBytes(b[aLength:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAddress returns nil if address is a checksummed address of the current network, a legacy hex address or a multisig address.
+func ValidateAddress(address string) error {
+	if IsMultisigAddress(address) {
+		_, _, err := ParseMultisigAddress(address)
+		return err
+	}
+	if IsLegacyAddress(address) {
+		return nil
+	}
+	_, err := DecodeAddress(address)
+	return err
+}
+
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:checksumLength]
+}
+
+func base58Encode(input []byte) string {
+	n := new(big.Int).SetBytes(input)
+	base := big.NewInt(int64(len(base58Alphabet)))
+	mod := new(big.Int)
+	var result []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result)
+}
+
+func base58Decode(input string) ([]byte, error) {
+	n := new(big.Int)
+	base := big.NewInt(int64(len(base58Alphabet)))
+	zeros := 0
+	for zeros < len(input) && input[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	for _, c := range []byte(input) {
+		digit := bytes.IndexByte([]byte(base58Alphabet), c)
+		if digit < 0 {
+			return nil, ErrInvalidAddress
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
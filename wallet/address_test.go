@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+func TestAddress(t *testing.T) {
+	w := makeTestWallet()
+	legacy := legacyAddress(w.privateKey)
+	t.Run("Address should decode to the hash of its public key.", func(t *testing.T) {
+		pubKeyHash, err := DecodeAddress(w.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pubKeyHash) != pubKeyHashSize {
+			t.Errorf("Expected %d bytes, got %d", pubKeyHashSize, len(pubKeyHash))
+		}
+		if address, _ := AddressFromPublicKey(legacy); address != w.Address {
+			t.Errorf("Expected %s, got %s", w.Address, address)
+		}
+	})
+	t.Run("Addresses should be validated.", func(t *testing.T) {
+		multisig, _ := MultisigAddress(1, []string{legacy})
+		typo := strings.Replace(w.Address, w.Address[10:11], "z", 1)
+		if typo == w.Address {
+			typo = strings.Replace(w.Address, w.Address[10:11], "y", 1)
+		}
+		tests := []struct {
+			address string
+			err     error
+		}{
+			{w.Address, nil},
+			{legacy, nil},
+			{multisig, nil},
+			{typo, ErrChecksum},
+			{"winter", ErrInvalidAddress},
+			{"0OIl", ErrInvalidAddress},
+			{"", ErrInvalidAddress},
+			{legacy[:len(legacy)-2] + "00", ErrInvalidAddress},
+		}
+		for _, test := range tests {
+			if err := ValidateAddress(test.address); err != test.err {
+				t.Errorf("Expected %v for %q, got %v", test.err, test.address, err)
+			}
+		}
+	})
+	t.Run("Addresses of another network should be rejected.", func(t *testing.T) {
+		if err := SetNetwork(TestNet.Name); err != nil {
+			t.Fatal(err)
+		}
+		defer SetNetwork(MainNet.Name)
+		if err := ValidateAddress(w.Address); err != ErrWrongNetwork {
+			t.Errorf("Expected %v, got %v", ErrWrongNetwork, err)
+		}
+		if address := parseAddress(w.privateKey); address == w.Address {
+			t.Error("Expected a different address on another network")
+		}
+	})
+	if err := SetNetwork("moon"); err != ErrUnknownNetwork {
+		t.Errorf("Expected %v, got %v", ErrUnknownNetwork, err)
+	}
+}
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		input, encoded string
+	}{
+		{"", ""},
+		{"\x00\x00hello", "11Cn8eVZg"},
+		{"hello world", "StV1DL6CwTryKyV"},
+	}
+	for _, test := range tests {
+		if encoded := base58Encode([]byte(test.input)); encoded != test.encoded {
+			t.Errorf("Expected %s, got %s", test.encoded, encoded)
+		}
+		if decoded, err := base58Decode(test.encoded); err != nil || string(decoded) != test.input {
+			t.Errorf("Expected %q, got %q (%v)", test.input, decoded, err)
+		}
+	}
+}
+
+func TestFixedWidthKeys(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	random := rand.New(rand.NewSource(1))
+	payload, _ := hex.DecodeString(testPayload)
+	for i := 0; i < 2000; i++ {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), random)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := newKeyWallet(privateKey)
+		legacy, unpadded := legacyAddress(privateKey), unpaddedPublicKey(privateKey)
+		if len(legacy) != 4*utils.BigIntSize {
+			t.Fatalf("Expected %d hex digits, got %s", 4*utils.BigIntSize, legacy)
+		}
+		signature := Sign(testPayload, w)
+		for _, publicKey := range []string{legacy, unpadded} {
+			if !Verify(signature, testPayload, publicKey) {
+				t.Fatalf("Expected signature to verify with %s", publicKey)
+			}
+		}
+		r, sigS, err := ecdsa.Sign(random, privateKey, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unpaddedSignature := fmt.Sprintf("%x", append(r.Bytes(), sigS.Bytes()...))
+		if !VerifyLegacy(unpaddedSignature, testPayload, unpadded) {
+			t.Fatalf("Expected unpadded signature %s to verify", unpaddedSignature)
+		}
+		if len(unpaddedSignature) < 4*utils.BigIntSize && Verify(unpaddedSignature, testPayload, unpadded) {
+			t.Fatalf("Expected unpadded signature %s to verify only as legacy", unpaddedSignature)
+		}
+		for _, address := range keyAddresses(privateKey) {
+			if !w.HasAddress(address) {
+				t.Fatalf("Expected wallet to have %s", address)
+			}
+			publicKey, err := w.PublicKey(address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if address != publicKey && mustAddress(publicKey) != address {
+				t.Fatalf("Expected public key of %s, got %s", address, publicKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/josh3021/nomadcoin/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// DefaultUnlockTimeout is how long an unlocked wallet stays unlocked when no timeout is given.
+	DefaultUnlockTimeout time.Duration = 5 * time.Minute
+
+	kdfScrypt  string = "scrypt"
+	scryptN    int    = 1 << 15
+	scryptR    int    = 8
+	scryptP    int    = 1
+	keyLength  int    = 32
+	saltLength int    = 16
+)
+
+var (
+	// ErrWalletLocked returns ERROR if the wallet has to be unlocked first.
+	ErrWalletLocked = errors.New("wallet is locked")
+	// ErrNotEncrypted returns ERROR if the wallet has no passphrase.
+	ErrNotEncrypted = errors.New("wallet is not encrypted")
+	// ErrAlreadyEncrypted returns ERROR if the wallet already has a passphrase.
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+	// ErrWrongPassphrase returns ERROR if the passphrase does not decrypt the wallet.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrEmptyPassphrase returns ERROR if the passphrase is empty.
+	ErrEmptyPassphrase = errors.New("passphrase is empty")
+	// ErrAddressMismatch returns ERROR if the decrypted keys are not the ones of the address of the wallet file.
+	ErrAddressMismatch = errors.New("decrypted wallet does not match the address of the wallet file")
+)
+
+// encryptedWallet is the file of an encrypted wallet.
+// The addresses stay readable so a locked wallet can still show its balance and receive coinbase rewards.
+type encryptedWallet struct {
+	Address    string   `json:"address"`
+	Addresses  []string `json:"addresses"`
+	KDF        string   `json:"kdf"`
+	N          int      `json:"n"`
+	R          int      `json:"r"`
+	P          int      `json:"p"`
+	Salt       []byte   `json:"salt"`
+	Nonce      []byte   `json:"nonce"`
+	Ciphertext []byte   `json:"ciphertext"`
+}
+
+// encryption holds the encrypted file of a wallet and, while unlocked, the key that decrypts it.
+type encryption struct {
+	file  *encryptedWallet
+	key   []byte
+	timer *time.Timer
+}
+
+func isEncryptedWallet(walletBytes []byte) bool {
+	var file encryptedWallet
+	return len(walletBytes) > 0 && walletBytes[0] == '{' &&
+		json.Unmarshal(walletBytes, &file) == nil && len(file.Ciphertext) > 0
+}
+
+func newEncryption(passphrase string) (*encryption, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	file := &encryptedWallet{KDF: kdfScrypt, N: scryptN, R: scryptR, P: scryptP, Salt: salt}
+	key, err := file.key(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &encryption{file: file, key: key}, nil
+}
+
+func (file *encryptedWallet) key(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, keyLength)
+}
+
+// additionalData binds the readable addresses to the ciphertext.
+func (file *encryptedWallet) additionalData() []byte {
+	return []byte(file.Address + ":" + strings.Join(file.Addresses, ","))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext into the wallet file and returns the file bytes.
+func (e *encryption) seal(address string, addresses []string, plaintext []byte) []byte {
+	aead, err := newAEAD(e.key)
+	utils.HandleErr(err)
+	e.file.Address = address
+	e.file.Addresses = addresses
+	e.file.Nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(e.file.Nonce)
+	utils.HandleErr(err)
+	e.file.Ciphertext = aead.Seal(nil, e.file.Nonce, plaintext, e.file.additionalData())
+	return utils.ToJSON(e.file)
+}
+
+// open decrypts the wallet file with key.
+func (e *encryption) open(key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, e.file.Nonce, e.file.Ciphertext, e.file.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func (e *encryption) locked() bool {
+	return e.key == nil
+}
+
+// newLockedWallet returns the locked wallet of an encrypted wallet file.
+func newLockedWallet(walletBytes []byte) (*wallet, error) {
+	var file encryptedWallet
+	if err := json.Unmarshal(walletBytes, &file); err != nil {
+		return nil, err
+	}
+	return &wallet{
+		Address:    file.Address,
+		keys:       make(map[string]crypto.Signer),
+		encryption: &encryption{file: &file},
+	}, nil
+}
+
+// IsEncrypted reports whether the wallet file is encrypted with a passphrase.
+func (w *wallet) IsEncrypted() bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.encryption != nil
+}
+
+// IsLocked reports whether the wallet has to be unlocked before signing.
+func (w *wallet) IsLocked() bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.isLocked()
+}
+
+func (w *wallet) isLocked() bool {
+	return w.encryption != nil && w.encryption.locked()
+}
+
+// Encrypt encrypts the wallet file in place with passphrase and locks the wallet.
+func (w *wallet) Encrypt(passphrase string) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.encryption != nil {
+		return ErrAlreadyEncrypted
+	}
+	encryption, err := newEncryption(passphrase)
+	if err != nil {
+		return err
+	}
+	w.encryption = encryption
+	persistWallet(w)
+	w.lock()
+	return nil
+}
+
+// Unlock decrypts the keys of the wallet and locks it again after timeout, or DefaultUnlockTimeout if timeout is 0.
+func (w *wallet) Unlock(passphrase string, timeout time.Duration) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.encryption == nil {
+		return ErrNotEncrypted
+	}
+	unlocked, key, err := w.encryption.decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	w.privateKey, w.hd, w.watch, w.keys = unlocked.privateKey, unlocked.hd, unlocked.watch, unlocked.keys
+	w.encryption.key = key
+	w.lockAfter(timeout)
+	return nil
+}
+
+// Decrypt returns the wallet in the encrypted file, decrypted with passphrase, and leaves the wallet as it is.
+// Secrets like the mnemonic are read from it, so that only the holder of the passphrase can read them.
+func (w *wallet) Decrypt(passphrase string) (*wallet, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.encryption == nil {
+		return nil, ErrNotEncrypted
+	}
+	decrypted, _, err := w.encryption.decrypt(passphrase)
+	return decrypted, err
+}
+
+// decrypt returns the wallet in the encrypted file and the key that decrypts it,
+// once the decrypted keys are checked against the readable address of the file.
+func (e *encryption) decrypt(passphrase string) (*wallet, []byte, error) {
+	key, err := e.file.key(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := e.open(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	decrypted, err := decodeWallet(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decrypted.Address != e.file.Address {
+		return nil, nil, ErrAddressMismatch
+	}
+	return decrypted, key, nil
+}
+
+func (w *wallet) lockAfter(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultUnlockTimeout
+	}
+	if w.encryption.timer != nil {
+		w.encryption.timer.Stop()
+	}
+	w.encryption.timer = time.AfterFunc(timeout, func() {
+		w.Lock()
+	})
+}
+
+// Lock forgets the keys of an encrypted wallet.
+func (w *wallet) Lock() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.encryption == nil {
+		return ErrNotEncrypted
+	}
+	w.lock()
+	return nil
+}
+
+func (w *wallet) lock() {
+	if w.encryption.timer != nil {
+		w.encryption.timer.Stop()
+		w.encryption.timer = nil
+	}
+	w.privateKey = nil
+	w.hd = nil
+	w.watch = nil
+	w.keys = make(map[string]crypto.Signer)
+	w.encryption.key = nil
+}
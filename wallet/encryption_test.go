@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type memoryLayer map[string][]byte
+
+func (m memoryLayer) hasWalletFile(name string) bool {
+	_, ok := m[name]
+	return ok
+}
+
+func (m memoryLayer) writeFile(name string, data []byte, perm fs.FileMode) error {
+	m[name] = data
+	return nil
+}
+
+func (m memoryLayer) readFile(name string) ([]byte, error) {
+	return m[name], nil
+}
+
+func (m memoryLayer) readDir(name string) ([]string, error) {
+	var names []string
+	for filename := range m {
+		if filepath.Dir(filename) == name {
+			names = append(names, filepath.Base(filename))
+		}
+	}
+	return names, nil
+}
+
+const testPassphrase string = "correct horse battery staple"
+
+func TestEncrypt(t *testing.T) {
+	layer := memoryLayer{}
+	files = layer
+	w := makeTestWallet()
+	persistWallet(w)
+	plaintext := layer[walletFilename]
+	if err := w.Encrypt(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Wallet file should be encrypted in place.", func(t *testing.T) {
+		if !isEncryptedWallet(layer[walletFilename]) {
+			t.Error("Expected an encrypted wallet file")
+		}
+		if bytes.Contains(layer[walletFilename], plaintext) {
+			t.Error("Expected the private key not to be stored in plaintext")
+		}
+		if err := w.Encrypt(testPassphrase); err != ErrAlreadyEncrypted {
+			t.Errorf("Expected %v, got %v", ErrAlreadyEncrypted, err)
+		}
+	})
+	t.Run("Encrypted wallet should be locked.", func(t *testing.T) {
+		restored := restoreWallet(layer[walletFilename])
+		if restored.Address != w.Address || !restored.IsLocked() {
+			t.Errorf("Expected locked wallet of %s", w.Address)
+		}
+		if !restored.HasAddress(w.Address) {
+			t.Error("Expected locked wallet to know its addresses")
+		}
+		if _, err := SignFor(testPayload, w.Address, restored); err != ErrWalletLocked {
+			t.Errorf("Expected %v, got %v", ErrWalletLocked, err)
+		}
+	})
+	t.Run("Unlock should check the passphrase.", func(t *testing.T) {
+		if err := w.Unlock("wrong", 0); err != ErrWrongPassphrase {
+			t.Errorf("Expected %v, got %v", ErrWrongPassphrase, err)
+		}
+		if err := w.Unlock(testPassphrase, 0); err != nil {
+			t.Fatal(err)
+		}
+		signature, err := SignFor(testPayload, w.Address, w)
+		if err != nil || !Verify(signature, testPayload, legacyAddress(w.privateKey)) {
+			t.Errorf("Expected a valid signature, got %v", err)
+		}
+		w.Lock()
+		if _, err := SignFor(testPayload, w.Address, w); err != ErrWalletLocked {
+			t.Errorf("Expected %v, got %v", ErrWalletLocked, err)
+		}
+	})
+	t.Run("Keys of another address should not unlock.", func(t *testing.T) {
+		encryption, err := newEncryption(testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed := encryption.seal("other", []string{"other"}, encodeWallet(makeTestWallet()))
+		other, err := newLockedWallet(sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Unlock(testPassphrase, 0); err != ErrAddressMismatch {
+			t.Errorf("Expected %v, got %v", ErrAddressMismatch, err)
+		}
+		if _, err := other.Decrypt(testPassphrase); err != ErrAddressMismatch {
+			t.Errorf("Expected %v, got %v", ErrAddressMismatch, err)
+		}
+	})
+	t.Run("Wallet should lock after the timeout.", func(t *testing.T) {
+		if err := w.Unlock(testPassphrase, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if !w.IsLocked() {
+			t.Error("Expected wallet to be locked")
+		}
+	})
+	t.Run("Plaintext wallet can not be locked.", func(t *testing.T) {
+		if err := makeTestWallet().Lock(); err != ErrNotEncrypted {
+			t.Errorf("Expected %v, got %v", ErrNotEncrypted, err)
+		}
+		if err := makeTestWallet().Encrypt(""); err != ErrEmptyPassphrase {
+			t.Errorf("Expected %v, got %v", ErrEmptyPassphrase, err)
+		}
+	})
+}
+
+func TestEncryptHDWallet(t *testing.T) {
+	layer := memoryLayer{}
+	files = layer
+	w, err := Recover(testMnemonic, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := newAddress(t, w)
+	w.Lock()
+	if _, err := w.NewAddress(); err != ErrWalletLocked {
+		t.Errorf("Expected %v, got %v", ErrWalletLocked, err)
+	}
+	if _, err := w.Mnemonic(); err != ErrWalletLocked {
+		t.Errorf("Expected %v, got %v", ErrWalletLocked, err)
+	}
+	restored := restoreWallet(layer[walletFilename])
+	if addresses := restored.Addresses(); len(addresses) != 4 || addresses[1] != address {
+		t.Errorf("Expected locked wallet to show %s, got %v", address, addresses)
+	}
+	t.Run("Tampered addresses should not unlock.", func(t *testing.T) {
+		tampered := restoreWallet(layer[walletFilename])
+		tampered.encryption.file.Addresses = []string{"tampered"}
+		if err := tampered.Unlock(testPassphrase, 0); err != ErrWrongPassphrase {
+			t.Errorf("Expected %v, got %v", ErrWrongPassphrase, err)
+		}
+	})
+	if err := restored.Unlock(testPassphrase, 0); err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic, _ := restored.Mnemonic(); mnemonic != testMnemonic {
+		t.Errorf("Expected %s, got %s", testMnemonic, mnemonic)
+	}
+	restored.Lock()
+	t.Run("Decrypt should need the passphrase and leave the wallet locked.", func(t *testing.T) {
+		if _, err := restored.Decrypt("wrong"); err != ErrWrongPassphrase {
+			t.Errorf("Expected %v, got %v", ErrWrongPassphrase, err)
+		}
+		decrypted, err := restored.Decrypt(testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic, _ := decrypted.Mnemonic(); mnemonic != testMnemonic || !restored.IsLocked() {
+			t.Errorf("Expected %s from a locked wallet, got %s", testMnemonic, mnemonic)
+		}
+		if _, err := makeTestWallet().Decrypt(testPassphrase); err != ErrNotEncrypted {
+			t.Errorf("Expected %v, got %v", ErrNotEncrypted, err)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// KeyFormat is an encoding of private keys, to move them between wallets.
+type KeyFormat string
+
+const (
+	// PEM keys are SEC 1 "EC PRIVATE KEY" blocks for ECDSA and Schnorr keys, and PKCS #8 "PRIVATE KEY" blocks
+	// for Ed25519 keys, as OpenSSL writes them. Schnorr keys are secp256k1 keys with a Key-Type header.
+	PEM KeyFormat = "pem"
+	// WIF keys are checksummed base58 strings of the network, the tag of the key type and the key, like addresses.
+	WIF KeyFormat = "wif"
+)
+
+const (
+	ecPrivateKeyBlock string = "EC PRIVATE KEY"
+	pkcs8Block        string = "PRIVATE KEY"
+	keyTypeHeader     string = "Key-Type"
+)
+
+// ErrUnknownKeyFormat returns ERROR if a key format is not known.
+var ErrUnknownKeyFormat = errors.New("unknown key format")
+
+var (
+	oidP256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// ecPrivateKey is the SEC 1 structure of an elliptic curve private key.
+// crypto/x509 only knows the NIST curves, so secp256k1 keys are marshaled by hand.
+type ecPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// ParseKeyFormat returns the key format of name.
+func ParseKeyFormat(name string) (KeyFormat, error) {
+	format := KeyFormat(name)
+	if format != PEM && format != WIF {
+		return "", ErrUnknownKeyFormat
+	}
+	return format, nil
+}
+
+// ExportPrivateKey returns the private key of address in format.
+func (w *wallet) ExportPrivateKey(address string, format KeyFormat) (string, error) {
+	w.m.Lock()
+	locked := w.isLocked()
+	privateKey, ok := w.keys[address]
+	w.m.Unlock()
+	if locked {
+		return "", ErrWalletLocked
+	}
+	if w.watch != nil {
+		return "", ErrWatchOnly
+	}
+	if !ok {
+		return "", ErrUnknownAddress
+	}
+	switch format {
+	case PEM:
+		return encodePEM(privateKey)
+	case WIF:
+		return encodeWIF(privateKey), nil
+	}
+	return "", ErrUnknownKeyFormat
+}
+
+// privateKeyBytes returns the 32-byte scalar of privateKey, or the seed of an Ed25519 key.
+func privateKeyBytes(privateKey crypto.Signer) []byte {
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return key.D.FillBytes(make([]byte, utils.BigIntSize))
+	case ed25519.PrivateKey:
+		return key.Seed()
+	case *secp256k1Key:
+		return key.Serialize()
+	case *schnorrKey:
+		return key.Serialize()
+	}
+	return nil
+}
+
+func encodePEM(privateKey crypto.Signer) (string, error) {
+	keyType := privateKeyType(privateKey)
+	if keyType == Ed25519 {
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: pkcs8Block, Bytes: der})), nil
+	}
+	oid := oidSecp256k1
+	var point []byte
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		oid = oidP256
+		point = append([]byte{0x04}, key.X.FillBytes(make([]byte, utils.BigIntSize))...)
+		point = append(point, key.Y.FillBytes(make([]byte, utils.BigIntSize))...)
+	case *secp256k1Key:
+		point = key.PubKey().SerializeUncompressed()
+	case *schnorrKey:
+		point = key.PubKey().SerializeUncompressed()
+	}
+	keyBytes := privateKeyBytes(privateKey)
+	der, err := asn1.Marshal(ecPrivateKey{
+		Version:       1,
+		PrivateKey:    keyBytes,
+		NamedCurveOID: oid,
+		PublicKey:     asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+	if err != nil {
+		return "", err
+	}
+	block := &pem.Block{Type: ecPrivateKeyBlock, Bytes: der}
+	if keyType == Schnorr {
+		block.Headers = map[string]string{keyTypeHeader: string(Schnorr)}
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+func decodePEM(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	switch block.Type {
+	case pkcs8Block:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		switch key := key.(type) {
+		case ed25519.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			if key.Curve == elliptic.P256() {
+				return key, nil
+			}
+		}
+		return nil, ErrUnknownKeyType
+	case ecPrivateKeyBlock:
+		var key ecPrivateKey
+		if rest, err := asn1.Unmarshal(block.Bytes, &key); err != nil || len(rest) != 0 || len(key.PrivateKey) > utils.BigIntSize {
+			return nil, ErrInvalidKey
+		}
+		var keyType KeyType
+		switch {
+		case key.NamedCurveOID.Equal(oidP256):
+			keyType = P256
+		case key.NamedCurveOID.Equal(oidSecp256k1):
+			keyType = Secp256k1
+			if block.Headers[keyTypeHeader] == string(Schnorr) {
+				keyType = Schnorr
+			}
+		default:
+			return nil, ErrUnknownKeyType
+		}
+		return privateKeyFromBytes(keyType, new(big.Int).SetBytes(key.PrivateKey).FillBytes(make([]byte, utils.BigIntSize)))
+	}
+	return nil, ErrInvalidKey
+}
+
+func encodeWIF(privateKey crypto.Signer) string {
+	payload := []byte{network.WIFVersion}
+	if tag, ok := keyTypeTags[privateKeyType(privateKey)]; ok {
+		payload = append(payload, tag)
+	}
+	payload = append(payload, privateKeyBytes(privateKey)...)
+	return base58Encode(append(payload, checksum(payload)...))
+}
+
+func decodeWIF(encoded string) (crypto.Signer, error) {
+	decoded, err := base58Decode(encoded)
+	if err != nil || len(decoded) < 1+utils.BigIntSize+checksumLength || len(decoded) > 2+utils.BigIntSize+checksumLength {
+		return nil, ErrInvalidKey
+	}
+	payload, sum := decoded[:len(decoded)-checksumLength], decoded[len(decoded)-checksumLength:]
+	if !bytes.Equal(checksum(payload), sum) {
+		return nil, ErrChecksum
+	}
+	if payload[0] != network.WIFVersion {
+		return nil, ErrWrongNetwork
+	}
+	if len(payload) == 1+utils.BigIntSize {
+		return privateKeyFromBytes(P256, payload[1:])
+	}
+	for keyType, tag := range keyTypeTags {
+		if payload[1] == tag {
+			return privateKeyFromBytes(keyType, payload[2:])
+		}
+	}
+	return nil, ErrUnknownKeyType
+}
+
+// parsePrivateKey returns the private key of a PEM block or a WIF string.
+func parsePrivateKey(encoded string) (crypto.Signer, error) {
+	encoded = strings.TrimSpace(encoded)
+	if strings.HasPrefix(encoded, "-----BEGIN") {
+		return decodePEM(encoded)
+	}
+	return decodeWIF(encoded)
+}
+
+// ImportKey creates and loads a single-key wallet of name holding a private key in PEM or WIF,
+// encrypted if passphrase is not empty.
+func (m *manager) ImportKey(name, privateKey, passphrase string) (*wallet, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return m.create(name, passphrase, func() (*wallet, error) {
+		return newKeyWallet(key), nil
+	})
+}
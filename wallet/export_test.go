@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestExportPrivateKey(t *testing.T) {
+	files = memoryLayer{}
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		for _, format := range []KeyFormat{PEM, WIF} {
+			t.Run(string(keyType)+" "+string(format), func(t *testing.T) {
+				privateKey, err := newPrivateKey(keyType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				w := newKeyWallet(privateKey)
+				exported, err := w.ExportPrivateKey(w.Address, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imported, err := parsePrivateKey(exported)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if address := parseAddress(imported); address != w.Address {
+					t.Errorf("Expected %s, got %s", w.Address, address)
+				}
+			})
+		}
+	}
+	t.Run("P256 keys should be exported as OpenSSL does.", func(t *testing.T) {
+		w := makeTestWallet()
+		exported, _ := w.ExportPrivateKey(w.Address, PEM)
+		block, _ := pem.Decode([]byte(exported))
+		expected, _ := x509.MarshalECPrivateKey(createPrivateKey())
+		if _, err := x509.ParseECPrivateKey(block.Bytes); err != nil || len(block.Bytes) != len(expected) {
+			t.Errorf("Expected a SEC 1 key, got %v", err)
+		}
+	})
+	t.Run("WIF keys should be checked.", func(t *testing.T) {
+		w := makeTestWallet()
+		exported, _ := w.ExportPrivateKey(w.Address, WIF)
+		typo := exported[:20] + "z" + exported[21:]
+		if typo == exported {
+			typo = exported[:20] + "y" + exported[21:]
+		}
+		SetNetwork(TestNet.Name)
+		testnet, _ := w.ExportPrivateKey(w.Address, WIF)
+		SetNetwork(MainNet.Name)
+		tests := []struct {
+			key string
+			err error
+		}{
+			{typo, ErrChecksum},
+			{testnet, ErrWrongNetwork},
+			{"winter", ErrInvalidKey},
+			{"-----BEGIN PUBLIC KEY-----\n-----END PUBLIC KEY-----", ErrInvalidKey},
+		}
+		for _, test := range tests {
+			if _, err := parsePrivateKey(test.key); err != test.err {
+				t.Errorf("Expected %v for %s, got %v", test.err, test.key, err)
+			}
+		}
+	})
+	t.Run("Keys should not be exported from locked or watch-only wallets.", func(t *testing.T) {
+		w := makeTestWallet()
+		w.filename = "locked.wallet"
+		w.Encrypt(testPassphrase)
+		w.Lock()
+		if _, err := w.ExportPrivateKey(w.Address, WIF); err != ErrWalletLocked {
+			t.Errorf("Expected %v, got %v", ErrWalletLocked, err)
+		}
+		watch, _ := newWatchWallet(&watchState{})
+		if _, err := watch.ExportPrivateKey(w.Address, WIF); err != ErrWatchOnly {
+			t.Errorf("Expected %v, got %v", ErrWatchOnly, err)
+		}
+		if _, err := makeTestWallet().ExportPrivateKey(w.Address, "der"); err != ErrUnknownKeyFormat {
+			t.Errorf("Expected %v, got %v", ErrUnknownKeyFormat, err)
+		}
+	})
+}
+
+func TestImportKey(t *testing.T) {
+	files = memoryLayer{}
+	m := &manager{wallets: make(map[string]*wallet)}
+	privateKey, _ := newPrivateKey(Schnorr)
+	original := newKeyWallet(privateKey)
+	exported, _ := original.ExportPrivateKey(original.Address, PEM)
+	imported, err := m.ImportKey("imported", exported, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Address != original.Address || imported.KeyType() != Schnorr {
+		t.Errorf("Expected the schnorr address %s, got %s", original.Address, imported.Address)
+	}
+	m.Unload("imported")
+	loaded, err := m.Load("imported")
+	if err != nil || loaded.Address != original.Address {
+		t.Errorf("Expected the imported wallet to be saved, got %v", err)
+	}
+	if _, err := m.ImportKey("imported", exported, ""); err != ErrWalletExists {
+		t.Errorf("Expected %v, got %v", ErrWalletExists, err)
+	}
+}
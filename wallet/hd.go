@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/josh3021/nomadcoin/utils"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// HardenedOffset is the first index of hardened child keys.
+	HardenedOffset uint32 = 0x80000000
+	// GapLimit is the number of consecutive unused addresses after which a scan stops.
+	GapLimit int = 20
+
+	hdPurpose  uint32 = 44
+	hdCoinType uint32 = 1
+	hdAccount  uint32 = 0
+
+	receiveChain uint32 = 0
+	changeChain  uint32 = 1
+)
+
+var (
+	// ErrInvalidMnemonic returns ERROR if a mnemonic is not a valid BIP39 phrase.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	// ErrInvalidXPub returns ERROR if an extended public key can not be decoded.
+	ErrInvalidXPub = errors.New("invalid extended public key")
+	// ErrHardenedDerivation returns ERROR if a hardened child is derived from a public key.
+	ErrHardenedDerivation = errors.New("hardened child of a public key")
+)
+
+// masterKeySeeds key the HMAC of the master key of each key type, as SLIP-10 defines them.
+var masterKeySeeds = map[KeyType]string{
+	P256:      "Nist256p1 seed",
+	Secp256k1: "Bitcoin seed",
+	Ed25519:   "ed25519 seed",
+	Schnorr:   "Bitcoin seed",
+}
+
+// xpubLength is the length of a serialized extended public key, without its checksum:
+// version, depth, parent fingerprint, child number, chain code and compressed key.
+const xpubLength int = 4 + 1 + 4 + 4 + 32 + 33
+
+// extendedKey is a private key with the chain code used to derive its children (SLIP-10).
+// Ed25519 keys only have hardened children.
+type extendedKey struct {
+	keyType   KeyType
+	key       *big.Int
+	chainCode []byte
+}
+
+// extendedPublicKey is a public key with the chain code used to derive its non-hardened children.
+type extendedPublicKey struct {
+	x, y        *big.Int
+	chainCode   []byte
+	depth       byte
+	childNumber uint32
+}
+
+// NewMnemonic returns a new mnemonic phrase of 12 or 24 words.
+func NewMnemonic(words int) (string, error) {
+	bitSize := 128
+	if words == 24 {
+		bitSize = 256
+	} else if words != 12 {
+		return "", ErrInvalidMnemonic
+	}
+	entropy, err := bip39.NewEntropy(bitSize)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+func seedFromMnemonic(mnemonic string) ([]byte, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	return seed, nil
+}
+
+func newMasterKey(seed []byte, keyType KeyType) *extendedKey {
+	mac := hmac.New(sha512.New, []byte(masterKeySeeds[keyType]))
+	mac.Write(seed)
+	i := mac.Sum(nil)
+	for {
+		key := new(big.Int).SetBytes(i[:32])
+		if keyType == Ed25519 || key.Sign() != 0 && key.Cmp(keyType.order()) < 0 {
+			return &extendedKey{keyType: keyType, key: key, chainCode: i[32:]}
+		}
+		mac = hmac.New(sha512.New, []byte(masterKeySeeds[keyType]))
+		mac.Write(i)
+		i = mac.Sum(nil)
+	}
+}
+
+// child derives the child key at index, hardened if index >= HardenedOffset or the key is an Ed25519 key.
+func (k *extendedKey) child(index uint32) *extendedKey {
+	if k.keyType == Ed25519 {
+		index |= HardenedOffset
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(appendIndex(append([]byte{0x00}, k.key.FillBytes(make([]byte, 32))...), index))
+		i := mac.Sum(nil)
+		return &extendedKey{keyType: Ed25519, key: new(big.Int).SetBytes(i[:32]), chainCode: i[32:]}
+	}
+	n := k.keyType.order()
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.key.FillBytes(make([]byte, 32))...)
+	} else {
+		data = k.publicKeyBytes()
+	}
+	data = appendIndex(data, index)
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		i := mac.Sum(nil)
+		il := new(big.Int).SetBytes(i[:32])
+		if il.Cmp(n) < 0 {
+			childKey := new(big.Int).Add(il, k.key)
+			childKey.Mod(childKey, n)
+			if childKey.Sign() != 0 {
+				return &extendedKey{keyType: k.keyType, key: childKey, chainCode: i[32:]}
+			}
+		}
+		data = appendIndex(append([]byte{0x01}, i[32:]...), index)
+	}
+}
+
+func appendIndex(data []byte, index uint32) []byte {
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	return append(data, indexBytes...)
+}
+
+// derive derives the key at path from k.
+func (k *extendedKey) derive(path ...uint32) *extendedKey {
+	key := k
+	for _, index := range path {
+		key = key.child(index)
+	}
+	return key
+}
+
+func (k *extendedKey) publicKeyBytes() []byte {
+	if k.keyType != P256 {
+		_, publicKey := btcec.PrivKeyFromBytes(k.key.FillBytes(make([]byte, 32)))
+		return publicKey.SerializeCompressed()
+	}
+	x, y := elliptic.P256().ScalarBaseMult(k.key.FillBytes(make([]byte, 32)))
+	return elliptic.MarshalCompressed(elliptic.P256(), x, y)
+}
+
+func (k *extendedKey) privateKey() crypto.Signer {
+	privateKey, err := privateKeyFromBytes(k.keyType, k.key.FillBytes(make([]byte, 32)))
+	utils.HandleErr(err)
+	return privateKey
+}
+
+// accountKey returns the key of type keyType of the wallet account, m/44'/1'/0'.
+func accountKey(seed []byte, keyType KeyType) *extendedKey {
+	return newMasterKey(seed, keyType).derive(
+		hdPurpose+HardenedOffset,
+		hdCoinType+HardenedOffset,
+		hdAccount+HardenedOffset,
+	)
+}
+
+// public returns the extended public key of k, at depth and childNumber of its path.
+func (k *extendedKey) public(depth byte, childNumber uint32) *extendedPublicKey {
+	x, y := elliptic.P256().ScalarBaseMult(k.key.FillBytes(make([]byte, 32)))
+	return &extendedPublicKey{x: x, y: y, chainCode: k.chainCode, depth: depth, childNumber: childNumber}
+}
+
+// child derives the public key of the non-hardened child at index, matching the public key of extendedKey.child.
+func (k *extendedPublicKey) child(index uint32) (*extendedPublicKey, error) {
+	if index >= HardenedOffset {
+		return nil, ErrHardenedDerivation
+	}
+	curve := elliptic.P256()
+	data := appendIndex(elliptic.MarshalCompressed(curve, k.x, k.y), index)
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		i := mac.Sum(nil)
+		if new(big.Int).SetBytes(i[:32]).Cmp(curve.Params().N) < 0 {
+			x, y := curve.ScalarBaseMult(i[:32])
+			x, y = curve.Add(x, y, k.x, k.y)
+			if x.Sign() != 0 || y.Sign() != 0 {
+				return &extendedPublicKey{x: x, y: y, chainCode: i[32:], depth: k.depth + 1, childNumber: index}, nil
+			}
+		}
+		data = appendIndex(append([]byte{0x01}, i[32:]...), index)
+	}
+}
+
+func (k *extendedPublicKey) publicKey() *ecdsa.PublicKey {
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: k.x, Y: k.y}
+}
+
+// String returns k serialized like a BIP32 extended public key of the current network.
+// Keys are exported without the parent fingerprint, which derivation does not need.
+func (k *extendedPublicKey) String() string {
+	payload := appendIndex(nil, network.XPubVersion)
+	payload = append(payload, k.depth, 0, 0, 0, 0)
+	payload = appendIndex(payload, k.childNumber)
+	payload = append(payload, k.chainCode...)
+	payload = append(payload, elliptic.MarshalCompressed(elliptic.P256(), k.x, k.y)...)
+	return base58Encode(append(payload, checksum(payload)...))
+}
+
+// parseXPub returns the extended public key of xpub, which has to belong to the current network.
+func parseXPub(xpub string) (*extendedPublicKey, error) {
+	decoded, err := base58Decode(xpub)
+	if err != nil || len(decoded) != xpubLength+checksumLength {
+		return nil, ErrInvalidXPub
+	}
+	payload, sum := decoded[:xpubLength], decoded[xpubLength:]
+	if !bytes.Equal(checksum(payload), sum) {
+		return nil, ErrChecksum
+	}
+	if binary.BigEndian.Uint32(payload) != network.XPubVersion {
+		return nil, ErrWrongNetwork
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), payload[45:])
+	if x == nil {
+		return nil, ErrInvalidXPub
+	}
+	return &extendedPublicKey{
+		x:           x,
+		y:           y,
+		chainCode:   payload[13:45],
+		depth:       payload[4],
+		childNumber: binary.BigEndian.Uint32(payload[9:13]),
+	}, nil
+}
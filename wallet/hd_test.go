@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testMnemonic string = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func newAddress(t *testing.T, w *wallet) string {
+	address, err := w.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+func TestMasterKey(t *testing.T) {
+	// SLIP-10 test vector 1 for nist256p1, secp256k1 and ed25519
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		keyType   KeyType
+		name      string
+		path      []uint32
+		chainCode string
+		key       string
+	}{
+		{P256, "m", nil, "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{P256, "m/0H", []uint32{HardenedOffset}, "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{P256, "m/0H/1", []uint32{HardenedOffset, 1}, "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{Secp256k1, "m", nil, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{Secp256k1, "m/0H", []uint32{HardenedOffset}, "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{Secp256k1, "m/0H/1", []uint32{HardenedOffset, 1}, "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{Ed25519, "m", nil, "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{Ed25519, "m/0H", []uint32{HardenedOffset}, "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{Ed25519, "m/0H/1H", []uint32{HardenedOffset, 1}, "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.keyType)+" "+test.name, func(t *testing.T) {
+			key := newMasterKey(seed, test.keyType).derive(test.path...)
+			if chainCode := hex.EncodeToString(key.chainCode); chainCode != test.chainCode {
+				t.Errorf("Expected chain code %s, got %s", test.chainCode, chainCode)
+			}
+			if privateKey := hex.EncodeToString(key.key.FillBytes(make([]byte, 32))); privateKey != test.key {
+				t.Errorf("Expected key %s, got %s", test.key, privateKey)
+			}
+		})
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, words := range []int{12, 24} {
+		mnemonic, err := NewMnemonic(words)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(strings.Fields(mnemonic)); n != words {
+			t.Errorf("Expected %d words, got %d", words, n)
+		}
+		if _, err := seedFromMnemonic(mnemonic); err != nil {
+			t.Errorf("Expected a valid mnemonic, got %v", err)
+		}
+	}
+	if _, err := NewMnemonic(13); err != ErrInvalidMnemonic {
+		t.Errorf("Expected %v, got %v", ErrInvalidMnemonic, err)
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	t.Run("Same mnemonic should derive the same addresses.", func(t *testing.T) {
+		a, err := newHDWallet(&hdState{Mnemonic: testMnemonic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+		if a.Address != b.Address || newAddress(t, a) != newAddress(t, b) || a.ChangeAddress() != b.ChangeAddress() {
+			t.Error("Expected both wallets to derive the same addresses")
+		}
+	})
+	t.Run("Invalid mnemonic should be rejected.", func(t *testing.T) {
+		_, err := newHDWallet(&hdState{Mnemonic: "abandon abandon"})
+		if err != ErrInvalidMnemonic {
+			t.Errorf("Expected %v, got %v", ErrInvalidMnemonic, err)
+		}
+	})
+	t.Run("Change address should be fresh once used.", func(t *testing.T) {
+		w, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+		change := w.ChangeAddress()
+		if w.ChangeAddress() != change {
+			t.Error("Expected ChangeAddress not to hand out the address")
+		}
+		w.UseChangeAddress()
+		if w.ChangeAddress() == change {
+			t.Error("Expected a new change address")
+		}
+		if !w.HasAddress(change) || len(w.Addresses()) != 4 {
+			t.Errorf("Expected used change address in %v", w.Addresses())
+		}
+	})
+	t.Run("SignFor should sign with the key of the address.", func(t *testing.T) {
+		w, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+		address := newAddress(t, w)
+		signature, err := SignFor(testPayload, address, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, _ := w.PublicKey(address)
+		if !Verify(signature, testPayload, publicKey) {
+			t.Error("Expected signature to verify")
+		}
+		if _, err := SignFor(testPayload, "unknown", w); err != ErrUnknownAddress {
+			t.Errorf("Expected %v, got %v", ErrUnknownAddress, err)
+		}
+	})
+}
+
+func TestScan(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	funded, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+	var receive []string
+	for i := 0; i < 30; i++ {
+		receive = append(receive, newAddress(t, funded))
+	}
+	used := map[string]bool{
+		receive[4]:             true,
+		receive[4+GapLimit]:    true,
+		funded.ChangeAddress(): true,
+	}
+	w, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+	w.Scan(func(address string) bool { return used[address] })
+	// receive[i] is the receiving address at index i+1
+	if w.hd.NextReceive != 6+GapLimit {
+		t.Errorf("Expected %d receiving addresses, got %d", 6+GapLimit, w.hd.NextReceive)
+	}
+	if w.hd.NextChange != 1 {
+		t.Errorf("Expected 1 change address, got %d", w.hd.NextChange)
+	}
+	for address := range used {
+		if !w.HasAddress(address) {
+			t.Errorf("Expected %s to be found", address)
+		}
+	}
+}
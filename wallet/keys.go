@@ -0,0 +1,333 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// KeyType names the signature scheme of a key.
+type KeyType string
+
+const (
+	// P256 keys sign with ECDSA over NIST P-256. Every wallet used them before key types.
+	P256 KeyType = "p256"
+	// Secp256k1 keys sign with ECDSA over secp256k1, the curve of most hardware signers.
+	Secp256k1 KeyType = "secp256k1"
+	// Ed25519 keys sign with Ed25519.
+	Ed25519 KeyType = "ed25519"
+	// Schnorr keys sign BIP340 Schnorr signatures over secp256k1, and can be aggregated with MuSig.
+	// Their addresses hold the x-only public key itself, so spending them takes only a signature.
+	Schnorr KeyType = "schnorr"
+)
+
+// keyTypeTags prefix the public keys, signatures and addresses of every key type but P256,
+// whose encodings are older than key types.
+var keyTypeTags = map[KeyType]byte{
+	Secp256k1: 0x01,
+	Ed25519:   0x02,
+	Schnorr:   0x03,
+}
+
+var (
+	// ErrUnknownKeyType returns ERROR if a key type is not known.
+	ErrUnknownKeyType = errors.New("unknown key type")
+	// ErrInvalidKey returns ERROR if a private key can not be decoded.
+	ErrInvalidKey = errors.New("invalid private key")
+)
+
+var defaultKeyType = P256
+
+// keyState is what a single-key wallet file of a key type other than P256 holds.
+type keyState struct {
+	KeyType    KeyType `json:"keyType"`
+	PrivateKey string  `json:"privateKey"`
+}
+
+// ParseKeyType returns the key type of name, P256 if name is empty.
+func ParseKeyType(name string) (KeyType, error) {
+	keyType := KeyType(name)
+	if keyType == "" || keyType == P256 {
+		return P256, nil
+	}
+	if _, ok := keyTypeTags[keyType]; !ok {
+		return "", ErrUnknownKeyType
+	}
+	return keyType, nil
+}
+
+// SetKeyType selects the key type of wallets created from now on.
+func SetKeyType(name string) error {
+	keyType, err := ParseKeyType(name)
+	if err != nil {
+		return err
+	}
+	defaultKeyType = keyType
+	return nil
+}
+
+// order returns the order of the curve of keys of t, which private keys have to be below.
+func (t KeyType) order() *big.Int {
+	if t == Secp256k1 || t == Schnorr {
+		return btcec.S256().Params().N
+	}
+	return elliptic.P256().Params().N
+}
+
+// newPrivateKey returns a new private key of keyType.
+func newPrivateKey(keyType KeyType) (crypto.Signer, error) {
+	if keyType == Ed25519 {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+	if keyType == P256 {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	if keyType == Schnorr {
+		return &schnorrKey{privateKey}, nil
+	}
+	return &secp256k1Key{privateKey}, nil
+}
+
+// privateKeyType returns the key type of privateKey.
+func privateKeyType(privateKey crypto.Signer) KeyType {
+	return publicKeyType(privateKey.Public())
+}
+
+func publicKeyType(publicKey crypto.PublicKey) KeyType {
+	if _, ok := publicKey.(*btcec.PublicKey); ok {
+		return Secp256k1
+	}
+	if _, ok := publicKey.(ed25519.PublicKey); ok {
+		return Ed25519
+	}
+	if _, ok := publicKey.(schnorrPublicKey); ok {
+		return Schnorr
+	}
+	return P256
+}
+
+// encodePublicKey returns the hex-encoded public key, X||Y for ECDSA keys and X for Schnorr keys, after the tag of its key type.
+func encodePublicKey(publicKey crypto.PublicKey) string {
+	var encoded []byte
+	if tag, ok := keyTypeTags[publicKeyType(publicKey)]; ok {
+		encoded = append(encoded, tag)
+	}
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		encoded = append(encoded, key.X.FillBytes(make([]byte, utils.BigIntSize))...)
+		encoded = append(encoded, key.Y.FillBytes(make([]byte, utils.BigIntSize))...)
+	case *btcec.PublicKey:
+		// uncompressed keys are 0x04||X||Y
+		encoded = append(encoded, key.SerializeUncompressed()[1:]...)
+	case ed25519.PublicKey:
+		encoded = append(encoded, key...)
+	case schnorrPublicKey:
+		encoded = append(encoded, key...)
+	}
+	return hex.EncodeToString(encoded)
+}
+
+// taggedKeyType returns the key type whose tag starts encoded, if encoded has the length of that key type.
+// Untagged encodings are P256 keys.
+func taggedKeyType(encoded []byte, lengths map[KeyType]int) KeyType {
+	for keyType, tag := range keyTypeTags {
+		if len(encoded) == lengths[keyType] && encoded[0] == tag {
+			return keyType
+		}
+	}
+	return P256
+}
+
+var publicKeyLengths = map[KeyType]int{
+	Secp256k1: 1 + 2*utils.BigIntSize,
+	Ed25519:   1 + ed25519.PublicKeySize,
+	Schnorr:   1 + schnorrKeySize,
+}
+
+var signatureLengths = map[KeyType]int{
+	Secp256k1: 1 + 2*utils.BigIntSize,
+	Ed25519:   1 + ed25519.SignatureSize,
+	Schnorr:   1 + 2*schnorrKeySize,
+}
+
+// parsePublicKey returns the public key of a hex-encoded public key of any key type.
+func parsePublicKey(publicKey string) (crypto.PublicKey, error) {
+	publicKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil || len(publicKeyBytes) == 0 {
+		return nil, ErrInvalidAddress
+	}
+	switch taggedKeyType(publicKeyBytes, publicKeyLengths) {
+	case Secp256k1:
+		key, err := btcec.ParsePubKey(append([]byte{0x04}, publicKeyBytes[1:]...))
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+		return key, nil
+	case Ed25519:
+		return ed25519.PublicKey(publicKeyBytes[1:]), nil
+	case Schnorr:
+		if _, err := liftX(publicKeyBytes[1:]); err != nil {
+			return nil, ErrInvalidAddress
+		}
+		return schnorrPublicKey(publicKeyBytes[1:]), nil
+	}
+	return legacyPublicKey(publicKey)
+}
+
+// PublicKeyType returns the key type of a hex-encoded public key.
+func PublicKeyType(publicKey string) (KeyType, error) {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return publicKeyType(key), nil
+}
+
+// signPayload signs payload with privateKey. ECDSA signatures are r||s, and all but P256 ones start with the tag of their key type.
+func signPayload(privateKey crypto.Signer, payload []byte) ([]byte, error) {
+	var signature []byte
+	if tag, ok := keyTypeTags[privateKeyType(privateKey)]; ok {
+		signature = append(signature, tag)
+	}
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, payload)
+		if err != nil {
+			return nil, err
+		}
+		signature = append(signature, r.FillBytes(make([]byte, utils.BigIntSize))...)
+		signature = append(signature, s.FillBytes(make([]byte, utils.BigIntSize))...)
+	case ed25519.PrivateKey:
+		signature = append(signature, ed25519.Sign(key, payload)...)
+	case *secp256k1Key, *schnorrKey:
+		keySignature, err := key.Sign(rand.Reader, payload, crypto.Hash(0))
+		if err != nil {
+			return nil, err
+		}
+		signature = append(signature, keySignature...)
+	default:
+		return nil, ErrInvalidKey
+	}
+	return signature, nil
+}
+
+// verifyTagged verifies a signature by a public key of a key type other than P256.
+// The signature has to be tagged with the key type of the public key.
+func verifyTagged(publicKey crypto.PublicKey, payload, signature []byte) bool {
+	keyType := publicKeyType(publicKey)
+	if taggedKeyType(signature, signatureLengths) != keyType {
+		return false
+	}
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		r := new(big.Int).SetBytes(signature[1 : 1+utils.BigIntSize])
+		s := new(big.Int).SetBytes(signature[1+utils.BigIntSize:])
+		return ecdsa.Verify(key, payload, r, s)
+	case *btcec.PublicKey:
+		return secp256k1Verify(key, payload, signature[1:])
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature[1:])
+	case schnorrPublicKey:
+		return schnorrVerify(key, payload, signature[1:])
+	}
+	return false
+}
+
+// marshalPrivateKey returns the wallet file bytes of a single-key wallet of a key type other than P256.
+func marshalPrivateKey(privateKey crypto.Signer) []byte {
+	state := keyState{KeyType: privateKeyType(privateKey)}
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		state.PrivateKey = hex.EncodeToString(key.D.FillBytes(make([]byte, utils.BigIntSize)))
+	case ed25519.PrivateKey:
+		state.PrivateKey = hex.EncodeToString(key.Seed())
+	case *secp256k1Key:
+		state.PrivateKey = hex.EncodeToString(key.Serialize())
+	case *schnorrKey:
+		state.PrivateKey = hex.EncodeToString(key.Serialize())
+	}
+	return utils.ToJSON(state)
+}
+
+// privateKey returns the private key of state.
+func (state *keyState) privateKey() (crypto.Signer, error) {
+	keyType, err := ParseKeyType(string(state.KeyType))
+	if err != nil {
+		return nil, err
+	}
+	keyBytes, err := hex.DecodeString(state.PrivateKey)
+	if err != nil || len(keyBytes) != utils.BigIntSize {
+		return nil, ErrInvalidKey
+	}
+	return privateKeyFromBytes(keyType, keyBytes)
+}
+
+// privateKeyFromBytes returns the private key of keyType of a 32-byte scalar, or seed for Ed25519.
+func privateKeyFromBytes(keyType KeyType, keyBytes []byte) (crypto.Signer, error) {
+	if keyType == Ed25519 {
+		return ed25519.NewKeyFromSeed(keyBytes), nil
+	}
+	if keyType == Secp256k1 || keyType == Schnorr {
+		privateKey, err := secp256k1FromBytes(keyBytes)
+		if err != nil {
+			return nil, err
+		}
+		if keyType == Schnorr {
+			return &schnorrKey{privateKey}, nil
+		}
+		return &secp256k1Key{privateKey}, nil
+	}
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(keyBytes)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrInvalidKey
+	}
+	privateKey := &ecdsa.PrivateKey{D: d}
+	privateKey.Curve = curve
+	privateKey.X, privateKey.Y = curve.ScalarBaseMult(keyBytes)
+	return privateKey, nil
+}
+
+// AddressPublicKey returns the hex-encoded public key held by a legacy or Schnorr address, which outputs pay to directly.
+// Other addresses hold the hash of their public key, and report false.
+func AddressPublicKey(address string) (string, bool) {
+	if IsLegacyAddress(address) {
+		return address, true
+	}
+	keyType, key, err := decodeAddress(address)
+	if err != nil || keyType != Schnorr {
+		return "", false
+	}
+	return hex.EncodeToString(append([]byte{keyTypeTags[Schnorr]}, key...)), true
+}
+
+// AddressKeyType returns the key type of the key an address pays to, P256 for legacy addresses.
+func AddressKeyType(address string) (KeyType, error) {
+	if IsLegacyAddress(address) {
+		return P256, nil
+	}
+	keyType, _, err := decodeAddress(address)
+	return keyType, err
+}
+
+// KeyType returns the key type of the wallet, read from its address.
+func (w *wallet) KeyType() KeyType {
+	keyType, err := AddressKeyType(w.Address)
+	if err != nil {
+		return P256
+	}
+	return keyType
+}
@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSecp256k1Keys(t *testing.T) {
+	tests := []struct {
+		k    int64
+		x, y string
+	}{
+		{1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"},
+		{2, "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5", "1ae168fea63dc339a3c58419466ceaeef7f632653266d0e1236431a950cfe52a"},
+		{3, "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9", "388f7b0f632de8140fe337e62a37f3566500a99934c2231b6cb9fd7584b8e672"},
+	}
+	for _, test := range tests {
+		privateKey, err := privateKeyFromBytes(Secp256k1, big.NewInt(test.k).FillBytes(make([]byte, 32)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if publicKey := encodePublicKey(privateKey.Public()); publicKey != "01"+test.x+test.y {
+			t.Errorf("Expected 01%s%s for %dG, got %s", test.x, test.y, test.k, publicKey)
+		}
+	}
+	for _, keyType := range []KeyType{Secp256k1, Schnorr} {
+		for _, d := range []*big.Int{new(big.Int), keyType.order()} {
+			if _, err := privateKeyFromBytes(keyType, d.FillBytes(make([]byte, 32))); err != ErrInvalidKey {
+				t.Errorf("Expected %v for %s key %x, got %v", ErrInvalidKey, keyType, d, err)
+			}
+		}
+	}
+}
+
+func TestSchnorr(t *testing.T) {
+	// test vectors of BIP340
+	tests := []struct {
+		secretKey, publicKey, aux, message, signature string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+		},
+		{
+			"b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+			"dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			"6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+		},
+		{
+			"c90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9",
+			"dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+			"c87aa53824b4d7ae2eb035a2b5bbbccc080e76cdc6d1692c4b0b62d798e6d906",
+			"7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+			"5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7",
+		},
+	}
+	for _, test := range tests {
+		secretKey, _ := hex.DecodeString(test.secretKey)
+		aux, _ := hex.DecodeString(test.aux)
+		message, _ := hex.DecodeString(test.message)
+		privateKey, err := privateKeyFromBytes(Schnorr, secretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if publicKey := hex.EncodeToString(privateKey.Public().(schnorrPublicKey)); publicKey != test.publicKey {
+			t.Errorf("Expected %s, got %s", test.publicKey, publicKey)
+		}
+		signature, err := schnorrSign(privateKey.(*schnorrKey).PrivateKey, message, aux)
+		if err != nil || hex.EncodeToString(signature) != test.signature {
+			t.Errorf("Expected %s, got %x (%v)", test.signature, signature, err)
+		}
+		publicKey, _ := hex.DecodeString(test.publicKey)
+		if !schnorrVerify(publicKey, message, signature) {
+			t.Errorf("Expected the signature of %s to verify", test.secretKey)
+		}
+		message[0] ^= 1
+		if schnorrVerify(publicKey, message, signature) {
+			t.Errorf("Expected the signature of %s not to verify another message", test.secretKey)
+		}
+	}
+	// not the x of a point of the curve
+	notOnCurve, _ := hex.DecodeString("eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34")
+	if _, err := liftX(notOnCurve); err != ErrInvalidSchnorrKey {
+		t.Errorf("Expected %v, got %v", ErrInvalidSchnorrKey, err)
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		t.Run(string(keyType), func(t *testing.T) {
+			privateKey, err := newPrivateKey(keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := newKeyWallet(privateKey)
+			if addressKeyType, err := AddressKeyType(w.Address); err != nil || addressKeyType != keyType {
+				t.Errorf("Expected %s, got %s (%v)", keyType, addressKeyType, err)
+			}
+			if err := ValidateAddress(w.Address); err != nil {
+				t.Errorf("Expected a valid address, got %v", err)
+			}
+			publicKey, _ := w.PublicKey(w.Address)
+			if publicKeyType, err := PublicKeyType(publicKey); err != nil || publicKeyType != keyType {
+				t.Errorf("Expected %s, got %s (%v)", keyType, publicKeyType, err)
+			}
+			signature, err := w.SignFor(testPayload, w.Address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !Verify(signature, testPayload, publicKey) {
+				t.Error("Expected the signature to verify")
+			}
+			if Verify(signature, testPayload, legacyAddress(createPrivateKey())) {
+				t.Error("Expected the signature not to verify by another key")
+			}
+			restored, err := decodeWallet(marshalWalletBytes(privateKey))
+			if err != nil || restored.Address != w.Address {
+				t.Errorf("Expected the wallet file to restore %s, got %v", w.Address, err)
+			}
+		})
+	}
+	t.Run("Signatures should be tagged with the key type of the key.", func(t *testing.T) {
+		secp256k1Key, _ := newPrivateKey(Secp256k1)
+		payload, _ := hex.DecodeString(testPayload)
+		signature, _ := signPayload(secp256k1Key, payload)
+		publicKey := legacyAddress(secp256k1Key)
+		if !Verify(hex.EncodeToString(signature), testPayload, publicKey) {
+			t.Fatal("Expected the tagged signature to verify")
+		}
+		if Verify(hex.EncodeToString(signature[1:]), testPayload, publicKey) {
+			t.Error("Expected an untagged signature not to verify")
+		}
+		signature[0] = keyTypeTags[Ed25519]
+		if Verify(hex.EncodeToString(signature), testPayload, publicKey) {
+			t.Error("Expected a signature tagged with another key type not to verify")
+		}
+	})
+	if _, err := ParseKeyType("rsa"); err != ErrUnknownKeyType {
+		t.Errorf("Expected %v, got %v", ErrUnknownKeyType, err)
+	}
+}
+
+func TestHDKeyTypes(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	for _, keyType := range []KeyType{Secp256k1, Ed25519, Schnorr} {
+		t.Run(string(keyType), func(t *testing.T) {
+			w, err := newHDWallet(&hdState{Mnemonic: testMnemonic, KeyType: keyType})
+			if err != nil {
+				t.Fatal(err)
+			}
+			address := newAddress(t, w)
+			if w.KeyType() != keyType {
+				t.Errorf("Expected %s, got %s", keyType, w.KeyType())
+			}
+			restored, err := decodeWallet(encodeWallet(w))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !restored.HasAddress(address) || restored.Address != w.Address {
+				t.Error("Expected the wallet file to keep the key type and addresses")
+			}
+			p256, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+			if p256.Address == w.Address {
+				t.Error("Expected different keys for different key types")
+			}
+			if strings.Contains(string(encodeWallet(p256)), "keyType") {
+				t.Error("Expected P256 wallet files to stay as they were")
+			}
+			if _, err := w.ExtendedPublicKey(); err != ErrNoXPub {
+				t.Errorf("Expected %v, got %v", ErrNoXPub, err)
+			}
+		})
+	}
+}
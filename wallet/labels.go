@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// labelsExtension names the file next to a wallet file that holds its labels.
+// Labels are kept out of the wallet file so they stay readable while an encrypted wallet is locked.
+const labelsExtension string = ".labels"
+
+// Label is what the owner of a wallet notes about an address, one of its own or a counterparty's.
+type Label struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+	Note    string `json:"note,omitempty"`
+}
+
+func (w *wallet) labelsFile() string {
+	return w.file() + labelsExtension
+}
+
+// loadLabels reads the labels file once, w.m has to be held.
+func (w *wallet) loadLabels() (map[string]*Label, error) {
+	if w.labels != nil {
+		return w.labels, nil
+	}
+	labels := make(map[string]*Label)
+	if files.hasWalletFile(w.labelsFile()) {
+		labelsBytes, err := files.readFile(w.labelsFile())
+		if err != nil {
+			return nil, err
+		}
+		var list []*Label
+		if err := json.Unmarshal(labelsBytes, &list); err != nil {
+			return nil, err
+		}
+		for _, label := range list {
+			labels[label.Address] = label
+		}
+	}
+	w.labels = labels
+	return labels, nil
+}
+
+// SetLabel labels address and keeps a note about it, or forgets both if they are empty.
+func (w *wallet) SetLabel(address, label, note string) error {
+	if err := ValidateAddress(address); err != nil {
+		return err
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	labels, err := w.loadLabels()
+	if err != nil {
+		return err
+	}
+	if label == "" && note == "" {
+		delete(labels, address)
+	} else {
+		labels[address] = &Label{Address: address, Label: label, Note: note}
+	}
+	return files.writeFile(w.labelsFile(), utils.ToJSON(sortedLabels(labels)), 0600)
+}
+
+// Labels returns every label of the wallet, sorted by address.
+func (w *wallet) Labels() ([]*Label, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	labels, err := w.loadLabels()
+	if err != nil {
+		return nil, err
+	}
+	return sortedLabels(labels), nil
+}
+
+func sortedLabels(labels map[string]*Label) []*Label {
+	list := []*Label{}
+	for _, label := range labels {
+		list = append(list, label)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+	return list
+}
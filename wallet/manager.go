@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	walletsDir       string = "wallets"
+	walletsExtension string = ".wallet"
+)
+
+var (
+	// ErrInvalidWalletName returns ERROR if a wallet name is not made of letters, digits, "-" and "_".
+	ErrInvalidWalletName = errors.New("invalid wallet name")
+	// ErrWalletExists returns ERROR if a wallet of the same name already exists.
+	ErrWalletExists = errors.New("wallet already exists")
+	// ErrWalletNotFound returns ERROR if there is no wallet file of a name.
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWalletNotLoaded returns ERROR if a wallet has to be loaded first.
+	ErrWalletNotLoaded = errors.New("wallet is not loaded")
+)
+
+var walletNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// WalletInfo describes a named wallet.
+type WalletInfo struct {
+	Name   string `json:"name"`
+	Loaded bool   `json:"loaded"`
+}
+
+type manager struct {
+	wallets map[string]*wallet
+	m       sync.Mutex
+}
+
+var wm *manager
+var managerOnce sync.Once
+
+// Manager returns the manager of the named wallets.
+func Manager() *manager {
+	managerOnce.Do(func() {
+		wm = &manager{wallets: make(map[string]*wallet)}
+	})
+	return wm
+}
+
+func walletPath(name string) (string, error) {
+	if !walletNamePattern.MatchString(name) {
+		return "", ErrInvalidWalletName
+	}
+	return filepath.Join(inDir(walletsDir), name+walletsExtension), nil
+}
+
+// Create creates and loads a new HD wallet of name, encrypted if passphrase is not empty.
+func (m *manager) Create(name, passphrase string) (*wallet, error) {
+	return m.CreateWithKeyType(name, passphrase, defaultKeyType)
+}
+
+// CreateWithKeyType creates and loads a new HD wallet of name whose keys are of keyType.
+func (m *manager) CreateWithKeyType(name, passphrase string, keyType KeyType) (*wallet, error) {
+	return m.create(name, passphrase, func() (*wallet, error) {
+		mnemonic, err := NewMnemonic(mnemonicWords)
+		if err != nil {
+			return nil, err
+		}
+		return newHDWallet(&hdState{Mnemonic: mnemonic, KeyType: keyType})
+	})
+}
+
+// CreateWatchOnly creates and loads an empty watch-only wallet of name, to import addresses and extended public keys into.
+func (m *manager) CreateWatchOnly(name string) (*wallet, error) {
+	return m.create(name, "", func() (*wallet, error) {
+		return newWatchWallet(&watchState{})
+	})
+}
+
+func (m *manager) create(name, passphrase string, newWallet func() (*wallet, error)) (*wallet, error) {
+	filename, err := walletPath(name)
+	if err != nil {
+		return nil, err
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	if _, ok := m.wallets[name]; ok || files.hasWalletFile(filename) {
+		return nil, ErrWalletExists
+	}
+	created, err := newWallet()
+	if err != nil {
+		return nil, err
+	}
+	created.filename = filename
+	if passphrase != "" {
+		if created.encryption, err = newEncryption(passphrase); err != nil {
+			return nil, err
+		}
+		created.lockAfter(0)
+	}
+	persistWallet(created)
+	m.wallets[name] = created
+	return created, nil
+}
+
+// Load loads the wallet file of name.
+func (m *manager) Load(name string) (*wallet, error) {
+	filename, err := walletPath(name)
+	if err != nil {
+		return nil, err
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	if loaded, ok := m.wallets[name]; ok {
+		return loaded, nil
+	}
+	if !files.hasWalletFile(filename) {
+		return nil, ErrWalletNotFound
+	}
+	walletBytes, err := files.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var loaded *wallet
+	if isEncryptedWallet(walletBytes) {
+		loaded, err = newLockedWallet(walletBytes)
+	} else {
+		loaded, err = decodeWallet(walletBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	loaded.filename = filename
+	m.wallets[name] = loaded
+	return loaded, nil
+}
+
+// Unload locks and forgets the wallet of name.
+func (m *manager) Unload(name string) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	unloaded, ok := m.wallets[name]
+	if !ok {
+		return ErrWalletNotLoaded
+	}
+	if unloaded.IsEncrypted() {
+		unloaded.Lock()
+	}
+	delete(m.wallets, name)
+	return nil
+}
+
+// Get returns the loaded wallet of name, or the node wallet if name is empty.
+func (m *manager) Get(name string) (*wallet, error) {
+	if name == "" {
+		return Wallet(), nil
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	loaded, ok := m.wallets[name]
+	if !ok {
+		return nil, ErrWalletNotLoaded
+	}
+	return loaded, nil
+}
+
+// List returns every named wallet on disk or loaded, sorted by name.
+func (m *manager) List() ([]*WalletInfo, error) {
+	filenames, err := files.readDir(inDir(walletsDir))
+	if err != nil {
+		return nil, err
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	names := make(map[string]bool)
+	for name := range m.wallets {
+		names[name] = true
+	}
+	for _, filename := range filenames {
+		name := strings.TrimSuffix(filename, walletsExtension)
+		if name != filename && walletNamePattern.MatchString(name) {
+			names[name] = true
+		}
+	}
+	infos := []*WalletInfo{}
+	for name := range names {
+		_, loaded := m.wallets[name]
+		infos = append(infos, &WalletInfo{Name: name, Loaded: loaded})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	files = memoryLayer{}
+	m := &manager{wallets: make(map[string]*wallet)}
+	alice, err := m.Create("alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create("bob", testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Names should be validated.", func(t *testing.T) {
+		for _, name := range []string{"", "../alice", "a b", "alice.wallet"} {
+			if _, err := m.Create(name, ""); err != ErrInvalidWalletName {
+				t.Errorf("Expected %v for %q, got %v", ErrInvalidWalletName, name, err)
+			}
+		}
+		if _, err := m.Create("alice", ""); err != ErrWalletExists {
+			t.Errorf("Expected %v, got %v", ErrWalletExists, err)
+		}
+	})
+	t.Run("Wallets should have their own keys.", func(t *testing.T) {
+		bob, _ := m.Get("bob")
+		if alice.Address == bob.Address || alice.file() == bob.file() {
+			t.Error("Expected different wallets")
+		}
+	})
+	t.Run("Unloaded wallets should be listed and loadable.", func(t *testing.T) {
+		if err := m.Unload("bob"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Get("bob"); err != ErrWalletNotLoaded {
+			t.Errorf("Expected %v, got %v", ErrWalletNotLoaded, err)
+		}
+		infos, err := m.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 2 || infos[0].Name != "alice" || !infos[0].Loaded || infos[1].Name != "bob" || infos[1].Loaded {
+			t.Errorf("Expected alice loaded and bob unloaded, got %v %v", infos[0], infos[1])
+		}
+		bob, err := m.Load("bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bob.IsLocked() {
+			t.Error("Expected encrypted wallet to load locked")
+		}
+		if _, err := m.Load("carol"); err != ErrWalletNotFound {
+			t.Errorf("Expected %v, got %v", ErrWalletNotFound, err)
+		}
+	})
+	t.Run("Empty name should be the node wallet.", func(t *testing.T) {
+		w = alice
+		defer func() { w = nil }()
+		if got, _ := m.Get(""); got != alice {
+			t.Error("Expected the node wallet")
+		}
+	})
+	t.Run("Wallets should be kept in the wallet directory.", func(t *testing.T) {
+		layer := memoryLayer{}
+		files = layer
+		SetDir(filepath.Join("data", "testnet"))
+		defer SetDir("")
+		defer func() { w = nil }()
+		w = nil
+		m := &manager{wallets: make(map[string]*wallet)}
+		if _, err := m.Create("carol", ""); err != nil {
+			t.Fatal(err)
+		}
+		Wallet()
+		for _, name := range []string{"data/testnet/wallets/carol.wallet", "data/testnet/nomadcoin.wallet"} {
+			if _, ok := layer[filepath.FromSlash(name)]; !ok {
+				t.Errorf("Expected %s to be written", name)
+			}
+		}
+		if infos, _ := m.List(); len(infos) != 1 || infos[0].Name != "carol" {
+			t.Errorf("Expected only carol in the directory, got %v", infos)
+		}
+	})
+}
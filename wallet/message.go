@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// messagePrefix separates the hashes of signed messages from tx IDs, so a signed message can never spend coins.
+const messagePrefix string = "Nomadcoin Signed Message:\n"
+
+var (
+	// ErrPublicKeyMismatch returns ERROR if the public key of a signed message does not belong to its address.
+	ErrPublicKeyMismatch = errors.New("public key does not belong to the address")
+	// ErrInvalidSignature returns ERROR if a signed message was not signed by the key of its address.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// SignedMessage proves that the owner of Address wrote Message.
+type SignedMessage struct {
+	Address   string `json:"address"`
+	Message   string `json:"message"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// messageHash returns the hex-encoded double SHA-256 of the prefix and the length-prefixed message.
+func messageHash(message string) string {
+	data := append([]byte(messagePrefix), make([]byte, binary.MaxVarintLen64)...)
+	n := binary.PutUvarint(data[len(messagePrefix):], uint64(len(message)))
+	data = append(data[:len(messagePrefix)+n], message...)
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return hex.EncodeToString(second[:])
+}
+
+// SignMessage signs message with the key of address, or of the wallet address if address is empty.
+func (w *wallet) SignMessage(address, message string) (*SignedMessage, error) {
+	if address == "" {
+		address = w.Address
+	}
+	signature, err := w.SignFor(messageHash(message), address)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := w.PublicKey(address)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedMessage{Address: address, Message: message, PublicKey: publicKey, Signature: signature}, nil
+}
+
+// VerifyMessage returns nil if signed was signed by the key of its address.
+// The public key may be left out for legacy and Schnorr addresses, which hold their public keys.
+func VerifyMessage(signed *SignedMessage) error {
+	publicKey := signed.PublicKey
+	if addressPublicKey, ok := AddressPublicKey(signed.Address); ok {
+		if publicKey == "" {
+			publicKey = addressPublicKey
+		}
+		if publicKey != addressPublicKey {
+			return ErrPublicKeyMismatch
+		}
+	} else {
+		if _, err := DecodeAddress(signed.Address); err != nil {
+			return err
+		}
+		if address, err := AddressFromPublicKey(publicKey); err != nil || address != signed.Address {
+			return ErrPublicKeyMismatch
+		}
+	}
+	if !Verify(signed.Signature, messageHash(signed.Message), publicKey) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
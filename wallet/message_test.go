@@ -0,0 +1,63 @@
+package wallet
+
+import "testing"
+
+func TestSignMessage(t *testing.T) {
+	w := makeTestWallet()
+	legacy := legacyAddress(w.privateKey)
+	signed, err := w.SignMessage("", "I own this address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Address != w.Address {
+		t.Errorf("Expected %s, got %s", w.Address, signed.Address)
+	}
+	t.Run("Signed messages should verify.", func(t *testing.T) {
+		if err := VerifyMessage(signed); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signedByLegacy, err := w.SignMessage(legacy, "I own this address")
+		if err != nil {
+			t.Fatal(err)
+		}
+		signedByLegacy.PublicKey = ""
+		if err := VerifyMessage(signedByLegacy); err != nil {
+			t.Errorf("Expected nil without a public key for a legacy address, got %v", err)
+		}
+	})
+	t.Run("Tampered messages should not verify.", func(t *testing.T) {
+		otherKey := legacyAddress(createPrivateKey())
+		tests := []struct {
+			name   string
+			tamper func(m *SignedMessage)
+			err    error
+		}{
+			{"message", func(m *SignedMessage) { m.Message += "!" }, ErrInvalidSignature},
+			{"public key", func(m *SignedMessage) { m.PublicKey = otherKey }, ErrPublicKeyMismatch},
+			{"legacy public key", func(m *SignedMessage) { m.Address, m.PublicKey = legacy, otherKey }, ErrPublicKeyMismatch},
+			{"address", func(m *SignedMessage) { m.Address = "winter" }, ErrInvalidAddress},
+		}
+		for _, test := range tests {
+			tampered := *signed
+			test.tamper(&tampered)
+			if err := VerifyMessage(&tampered); err != test.err {
+				t.Errorf("Expected %v for a tampered %s, got %v", test.err, test.name, err)
+			}
+		}
+	})
+	t.Run("Messages should not sign payloads.", func(t *testing.T) {
+		signed, err := w.SignMessage("", testPayload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Verify(signed.Signature, testPayload, legacy) {
+			t.Error("Expected the signature of a message not to sign the same payload")
+		}
+		if messageHash(testPayload) == testPayload {
+			t.Error("Expected the message hash to differ from the payload")
+		}
+	})
+	if _, err := w.SignMessage("winter", "hi"); err == nil {
+		t.Error("Expected an error for an address of another wallet")
+	}
+}
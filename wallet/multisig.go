@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	multisigPrefix  string = "multisig"
+	multisigSep     string = ":"
+	maxMultisigKeys int    = 15
+)
+
+// ErrInvalidMultisig returns ERROR if a multisig address or its signatures are malformed.
+var ErrInvalidMultisig = errors.New("invalid multisig address")
+
+// ErrNotMultisigSigner returns ERROR if the wallet is not one of the keys of a multisig address.
+var ErrNotMultisigSigner = errors.New("wallet is not a signer of the multisig address")
+
+// MultisigAddress returns the address of outputs spendable by any "required" of the given hex-encoded public keys.
+// A key can not be repeated, or its holder alone would count for more than one signature.
+func MultisigAddress(required int, addresses []string) (string, error) {
+	if required < 1 || required > len(addresses) || len(addresses) > maxMultisigKeys {
+		return "", ErrInvalidMultisig
+	}
+	keys := make(map[string]bool)
+	for _, address := range addresses {
+		publicKey, err := legacyPublicKey(address)
+		if err != nil {
+			return "", ErrInvalidMultisig
+		}
+		// unpadded and padded encodings of a key are the same key
+		key := encodePublicKey(publicKey)
+		if keys[key] {
+			return "", ErrInvalidMultisig
+		}
+		keys[key] = true
+	}
+	parts := append([]string{multisigPrefix, strconv.Itoa(required)}, addresses...)
+	return strings.Join(parts, multisigSep), nil
+}
+
+// IsMultisigAddress reports whether address is a multisig address.
+func IsMultisigAddress(address string) bool {
+	return strings.HasPrefix(address, multisigPrefix+multisigSep)
+}
+
+// ParseMultisigAddress returns the number of required signatures and the keys of a multisig address.
+func ParseMultisigAddress(address string) (int, []string, error) {
+	parts := strings.Split(address, multisigSep)
+	if len(parts) < 3 || parts[0] != multisigPrefix {
+		return 0, nil, ErrInvalidMultisig
+	}
+	required, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, nil, ErrInvalidMultisig
+	}
+	addresses := parts[2:]
+	if _, err := MultisigAddress(required, addresses); err != nil {
+		return 0, nil, err
+	}
+	return required, addresses, nil
+}
+
+// SignMultisig signs payload with the first key the wallet holds and puts the signature in its position.
+func SignMultisig(payload, address string, signatures []string, wallet *wallet) ([]string, error) {
+	_, addresses, err := ParseMultisigAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	signed := make([]string, len(addresses))
+	copy(signed, signatures)
+	for index, signer := range addresses {
+		if !wallet.HasAddress(signer) {
+			continue
+		}
+		signature, err := SignFor(payload, signer, wallet)
+		if err != nil {
+			return nil, err
+		}
+		signed[index] = signature
+		return signed, nil
+	}
+	return nil, ErrNotMultisigSigner
+}
+
+// CombineSignatures merges partial signature lists of the same multisig input.
+func CombineSignatures(a, b []string) ([]string, error) {
+	if len(a) != len(b) {
+		return nil, ErrInvalidMultisig
+	}
+	combined := make([]string, len(a))
+	for index := range a {
+		switch {
+		case a[index] == "":
+			combined[index] = b[index]
+		case b[index] == "" || a[index] == b[index]:
+			combined[index] = a[index]
+		default:
+			return nil, ErrInvalidMultisig
+		}
+	}
+	return combined, nil
+}
+
+// VerifyMultisig verifies that enough signatures of a multisig address sign payload.
+func VerifyMultisig(signatures []string, payload, address string) bool {
+	required, addresses, err := ParseMultisigAddress(address)
+	if err != nil || len(signatures) != len(addresses) {
+		return false
+	}
+	valid := 0
+	for index, signature := range signatures {
+		if signature == "" {
+			continue
+		}
+		if _, err := hex.DecodeString(signature); err != nil {
+			return false
+		}
+		if !Verify(signature, payload, addresses[index]) {
+			return false
+		}
+		valid++
+	}
+	return valid >= required
+}
@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+)
+
+func makeMultisigWallets(n int) []*wallet {
+	var wallets []*wallet
+	for len(wallets) < n {
+		wallets = append(wallets, newKeyWallet(createPrivateKey()))
+	}
+	return wallets
+}
+
+func signMultisig(t *testing.T, address string, w *wallet) []string {
+	signatures, err := SignMultisig(testPayload, address, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signatures
+}
+
+func TestMultisigAddress(t *testing.T) {
+	wallets := makeMultisigWallets(3)
+	addresses := []string{legacyAddress(wallets[0].privateKey), legacyAddress(wallets[1].privateKey), legacyAddress(wallets[2].privateKey)}
+	t.Run("Address should round trip.", func(t *testing.T) {
+		address, err := MultisigAddress(2, addresses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsMultisigAddress(address) {
+			t.Errorf("Expected %s to be a multisig address", address)
+		}
+		required, parsed, err := ParseMultisigAddress(address)
+		if err != nil || required != 2 || len(parsed) != 3 {
+			t.Errorf("Expected 2 of 3, got %d of %d (%v)", required, len(parsed), err)
+		}
+	})
+	t.Run("Address should reject invalid thresholds.", func(t *testing.T) {
+		for _, required := range []int{0, 4} {
+			if _, err := MultisigAddress(required, addresses); err == nil {
+				t.Errorf("Expected ERROR for %d of 3, got nil", required)
+			}
+		}
+	})
+	t.Run("Address should reject repeated keys.", func(t *testing.T) {
+		repeated := []string{addresses[0], addresses[0], addresses[1]}
+		if _, err := MultisigAddress(2, repeated); err != ErrInvalidMultisig {
+			t.Errorf("Expected %v, got %v", ErrInvalidMultisig, err)
+		}
+		address := strings.Join(append([]string{multisigPrefix, "2"}, repeated...), multisigSep)
+		if _, _, err := ParseMultisigAddress(address); err != ErrInvalidMultisig {
+			t.Errorf("Expected %v, got %v", ErrInvalidMultisig, err)
+		}
+	})
+	t.Run("Address should reject non-hex keys.", func(t *testing.T) {
+		if _, err := MultisigAddress(1, []string{"winter"}); err == nil {
+			t.Error("Expected ERROR, got nil")
+		}
+	})
+}
+
+func TestVerifyMultisig(t *testing.T) {
+	wallets := makeMultisigWallets(3)
+	addresses := []string{legacyAddress(wallets[0].privateKey), legacyAddress(wallets[1].privateKey), legacyAddress(wallets[2].privateKey)}
+	address, _ := MultisigAddress(2, addresses)
+
+	first := signMultisig(t, address, wallets[0])
+	if VerifyMultisig(first, testPayload, address) {
+		t.Error("One signature should not satisfy 2 of 3")
+	}
+	third := signMultisig(t, address, wallets[2])
+	combined, err := CombineSignatures(first, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMultisig(combined, testPayload, address) {
+		t.Error("Two combined signatures should satisfy 2 of 3")
+	}
+	if VerifyMultisig(combined, "765cd5fbc8bdb14616f299bb4e7952065cd5d153d3511e680d5ddd7cc74e1aae", address) {
+		t.Error("Signatures should not verify another payload")
+	}
+	if _, err := SignMultisig(testPayload, address, nil, makeTestWallet()); err != ErrNotMultisigSigner {
+		t.Errorf("Expected %v, got %v", ErrNotMultisigSigner, err)
+	}
+}
+
+func TestCombineSignatures(t *testing.T) {
+	t.Run("Conflicting signatures should not combine.", func(t *testing.T) {
+		if _, err := CombineSignatures([]string{"aa", ""}, []string{"bb", ""}); err == nil {
+			t.Error("Expected ERROR, got nil")
+		}
+	})
+	t.Run("Signature lists of different length should not combine.", func(t *testing.T) {
+		if _, err := CombineSignatures([]string{"aa"}, []string{"", ""}); err == nil {
+			t.Error("Expected ERROR, got nil")
+		}
+	})
+}
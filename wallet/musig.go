@@ -0,0 +1,306 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// muSigNonceSize is the size of a public nonce, two compressed points.
+const muSigNonceSize int = 2 * (1 + schnorrKeySize)
+
+var (
+	// ErrInvalidMuSig returns ERROR if the addresses of a MuSig address are not at least two different Schnorr addresses.
+	ErrInvalidMuSig = errors.New("invalid musig addresses")
+	// ErrInvalidNonce returns ERROR if a public nonce is malformed or the nonce of a signer is missing.
+	ErrInvalidNonce = errors.New("invalid musig nonce")
+	// ErrUnknownNonce returns ERROR if the wallet did not make a public nonce, or already signed with it.
+	ErrUnknownNonce = errors.New("unknown or used musig nonce")
+	// ErrInvalidPartialSignature returns ERROR if the partial signature of a signer is missing or does not verify.
+	ErrInvalidPartialSignature = errors.New("invalid partial signature")
+)
+
+// muSigKeys are the keys of a MuSig address with their coefficients, and the aggregate key Q.
+type muSigKeys struct {
+	addresses    []string
+	keys         [][]byte
+	coefficients []*btcec.ModNScalar
+	q            *btcec.JacobianPoint
+}
+
+// secretNonce is the secret half of a public nonce the wallet made for one of its addresses.
+type secretNonce struct {
+	address string
+	k1, k2  *btcec.ModNScalar
+}
+
+// aggregateKeys sorts the keys of addresses and aggregates them, each weighted by its hash with the list of all keys
+// so that no signer can choose its key to cancel out the others.
+func aggregateKeys(addresses []string) (*muSigKeys, error) {
+	if len(addresses) < 2 {
+		return nil, ErrInvalidMuSig
+	}
+	agg := &muSigKeys{}
+	for _, address := range addresses {
+		keyType, key, err := decodeAddress(address)
+		if err != nil || keyType != Schnorr {
+			return nil, ErrInvalidMuSig
+		}
+		agg.addresses = append(agg.addresses, address)
+		agg.keys = append(agg.keys, key)
+	}
+	sort.Sort(agg)
+	for i := 1; i < len(agg.keys); i++ {
+		if bytes.Equal(agg.keys[i-1], agg.keys[i]) {
+			return nil, ErrInvalidMuSig
+		}
+	}
+	list := taggedHash("MuSig/KeyAgg list", agg.keys...)
+	agg.q = new(btcec.JacobianPoint)
+	for _, key := range agg.keys {
+		a := hashScalar(taggedHash("MuSig/KeyAgg coefficient", list, key))
+		agg.coefficients = append(agg.coefficients, a)
+		p, _ := liftX(key)
+		agg.q = addPoints(agg.q, mulPoint(a, p))
+	}
+	if isInfinity(agg.q) {
+		return nil, ErrInvalidMuSig
+	}
+	return agg, nil
+}
+
+func (agg *muSigKeys) Len() int           { return len(agg.keys) }
+func (agg *muSigKeys) Less(i, j int) bool { return bytes.Compare(agg.keys[i], agg.keys[j]) < 0 }
+func (agg *muSigKeys) Swap(i, j int) {
+	agg.keys[i], agg.keys[j] = agg.keys[j], agg.keys[i]
+	agg.addresses[i], agg.addresses[j] = agg.addresses[j], agg.addresses[i]
+}
+
+// negated reports whether Q has an odd y, so the x-only aggregate key is -Q and every signer signs with its key negated.
+func (agg *muSigKeys) negated() bool {
+	return agg.q.Y.IsOdd()
+}
+
+func (agg *muSigKeys) index(address string) int {
+	for i, known := range agg.addresses {
+		if known == address {
+			return i
+		}
+	}
+	return -1
+}
+
+// MuSigAddress returns the Schnorr address of the aggregate key of the keys of the Schnorr addresses.
+// Outputs paid to it are spent with a single signature that only all of the keys together can make.
+func MuSigAddress(addresses []string) (string, error) {
+	agg, err := aggregateKeys(addresses)
+	if err != nil {
+		return "", err
+	}
+	return encodeAddress(network.Version, Schnorr, xBytes(agg.q)), nil
+}
+
+// compressPoint returns the 33-byte encoding of an affine point, the parity of y and x.
+func compressPoint(p *btcec.JacobianPoint) []byte {
+	return btcec.NewPublicKey(&p.X, &p.Y).SerializeCompressed()
+}
+
+func decompressPoint(encoded []byte) (*btcec.JacobianPoint, error) {
+	if len(encoded) != 1+schnorrKeySize || encoded[0]&^1 != 0x02 {
+		return nil, ErrInvalidNonce
+	}
+	key, err := btcec.ParsePubKey(encoded)
+	if err != nil {
+		return nil, ErrInvalidNonce
+	}
+	p := new(btcec.JacobianPoint)
+	key.AsJacobian(p)
+	return p, nil
+}
+
+// parseNonce returns the two points of a hex-encoded public nonce.
+func parseNonce(nonce string) (r1, r2 *btcec.JacobianPoint, err error) {
+	nonceBytes, err := hex.DecodeString(nonce)
+	if err != nil || len(nonceBytes) != muSigNonceSize {
+		return nil, nil, ErrInvalidNonce
+	}
+	if r1, err = decompressPoint(nonceBytes[:muSigNonceSize/2]); err != nil {
+		return nil, nil, err
+	}
+	if r2, err = decompressPoint(nonceBytes[muSigNonceSize/2:]); err != nil {
+		return nil, nil, err
+	}
+	return r1, r2, nil
+}
+
+// MuSigNonce makes a new nonce for the Schnorr address of the wallet, and returns its public half.
+// The secret half stays in memory until the wallet signs with it, so every nonce signs at most once.
+func (w *wallet) MuSigNonce(address string) (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.watch != nil {
+		return "", ErrWatchOnly
+	}
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	if _, ok := w.keys[address]; !ok {
+		return "", ErrUnknownAddress
+	}
+	if keyType, _ := AddressKeyType(address); keyType != Schnorr {
+		return "", ErrInvalidMuSig
+	}
+	k1, err := randomScalar()
+	if err != nil {
+		return "", err
+	}
+	k2, err := randomScalar()
+	if err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(append(compressPoint(mulBase(k1)), compressPoint(mulBase(k2))...))
+	if w.nonces == nil {
+		w.nonces = map[string]*secretNonce{}
+	}
+	w.nonces[nonce] = &secretNonce{address: address, k1: k1, k2: k2}
+	return nonce, nil
+}
+
+// muSigSession is what every signer of a payload computes from the keys and the public nonces of all signers.
+type muSigSession struct {
+	*muSigKeys
+	payload []byte
+	// b weighs the second nonce of every signer, binding the nonces to the keys, the payload and each other.
+	b *btcec.ModNScalar
+	// e is the BIP340 challenge of the signature.
+	e      *btcec.ModNScalar
+	r      *btcec.JacobianPoint
+	nonces [][2]*btcec.JacobianPoint
+}
+
+func newMuSigSession(addresses []string, nonces map[string]string, payload string) (*muSigSession, error) {
+	agg, err := aggregateKeys(addresses)
+	if err != nil {
+		return nil, err
+	}
+	payloadBytes, err := hex.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	session := &muSigSession{muSigKeys: agg, payload: payloadBytes}
+	r1, r2 := new(btcec.JacobianPoint), new(btcec.JacobianPoint)
+	for _, address := range agg.addresses {
+		nonce, ok := nonces[address]
+		if !ok {
+			return nil, fmt.Errorf("%w of %s", ErrInvalidNonce, address)
+		}
+		n1, n2, err := parseNonce(nonce)
+		if err != nil {
+			return nil, fmt.Errorf("%w of %s", err, address)
+		}
+		session.nonces = append(session.nonces, [2]*btcec.JacobianPoint{n1, n2})
+		r1, r2 = addPoints(r1, n1), addPoints(r2, n2)
+	}
+	if isInfinity(r1) || isInfinity(r2) {
+		return nil, ErrInvalidNonce
+	}
+	aggregateNonce := append(compressPoint(r1), compressPoint(r2)...)
+	session.b = hashScalar(taggedHash("MuSig/noncecoef", aggregateNonce, xBytes(agg.q), payloadBytes))
+	session.r = addPoints(r1, mulPoint(session.b, r2))
+	if isInfinity(session.r) {
+		return nil, ErrInvalidNonce
+	}
+	session.e = schnorrChallenge(xBytes(session.r), xBytes(agg.q), payloadBytes)
+	return session, nil
+}
+
+// challenge returns e·a·g of the signer at index i, where g negates keys when Q has an odd y.
+func (session *muSigSession) challenge(i int) *btcec.ModNScalar {
+	c := new(btcec.ModNScalar).Mul2(session.e, session.coefficients[i])
+	if session.negated() {
+		c.Negate()
+	}
+	return c
+}
+
+// MuSigSign signs payload as the Schnorr address of the wallet, one of the addresses of a MuSig address,
+// with the nonce the wallet made for it. nonces holds the public nonce of every address.
+func (w *wallet) MuSigSign(payload, address string, addresses []string, nonces map[string]string) (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.watch != nil {
+		return "", ErrWatchOnly
+	}
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	privateKey, ok := w.keys[address].(*schnorrKey)
+	if !ok {
+		return "", ErrUnknownAddress
+	}
+	session, err := newMuSigSession(addresses, nonces, payload)
+	if err != nil {
+		return "", err
+	}
+	i := session.index(address)
+	if i < 0 {
+		return "", ErrInvalidMuSig
+	}
+	secret, ok := w.nonces[nonces[address]]
+	if !ok || secret.address != address {
+		return "", ErrUnknownNonce
+	}
+	delete(w.nonces, nonces[address])
+	k := new(btcec.ModNScalar).Mul2(session.b, secret.k2).Add(secret.k1)
+	if session.r.Y.IsOdd() {
+		k.Negate()
+	}
+	// the x-only key of the address is the point with an even y
+	d := new(btcec.ModNScalar).Set(&privateKey.Key)
+	if mulBase(d).Y.IsOdd() {
+		d.Negate()
+	}
+	s := d.Mul(session.challenge(i)).Add(k).Bytes()
+	return hex.EncodeToString(s[:]), nil
+}
+
+// verifyPartial reports whether s is the partial signature of the signer at index i.
+func (session *muSigSession) verifyPartial(i int, s *btcec.ModNScalar) bool {
+	nonce := session.nonces[i]
+	r := addPoints(nonce[0], mulPoint(session.b, nonce[1]))
+	if session.r.Y.IsOdd() {
+		negatePoint(r)
+	}
+	p, _ := liftX(session.keys[i])
+	expected := addPoints(r, mulPoint(session.challenge(i), p))
+	actual := mulBase(s)
+	return actual.X.Equals(&expected.X) && actual.Y.Equals(&expected.Y)
+}
+
+// AggregateSignatures checks the partial signature of every address of a MuSig address, and sums them up
+// into the signature of payload by the MuSig address.
+func AggregateSignatures(payload string, addresses []string, nonces, partialSignatures map[string]string) (string, error) {
+	session, err := newMuSigSession(addresses, nonces, payload)
+	if err != nil {
+		return "", err
+	}
+	sum := new(btcec.ModNScalar)
+	for i, address := range session.addresses {
+		partialBytes, err := hex.DecodeString(partialSignatures[address])
+		if err != nil || len(partialBytes) != schnorrKeySize {
+			return "", fmt.Errorf("%w of %s", ErrInvalidPartialSignature, address)
+		}
+		s := new(btcec.ModNScalar)
+		if overflow := s.SetByteSlice(partialBytes); overflow || !session.verifyPartial(i, s) {
+			return "", fmt.Errorf("%w of %s", ErrInvalidPartialSignature, address)
+		}
+		sum.Add(s)
+	}
+	s := sum.Bytes()
+	signature := append([]byte{keyTypeTags[Schnorr]}, xBytes(session.r)...)
+	return hex.EncodeToString(append(signature, s[:]...)), nil
+}
@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func makeMuSigWallets(t *testing.T, n int) ([]*wallet, []string) {
+	var wallets []*wallet
+	var addresses []string
+	for len(wallets) < n {
+		privateKey, err := newPrivateKey(Schnorr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := newKeyWallet(privateKey)
+		wallets = append(wallets, w)
+		addresses = append(addresses, w.Address)
+	}
+	return wallets, addresses
+}
+
+func muSigNonces(t *testing.T, wallets []*wallet) map[string]string {
+	nonces := map[string]string{}
+	for _, w := range wallets {
+		nonce, err := w.MuSigNonce(w.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces[w.Address] = nonce
+	}
+	return nonces
+}
+
+func TestMuSigAddress(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	wallets, addresses := makeMuSigWallets(t, 3)
+	address, err := MuSigAddress(addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyType, _ := AddressKeyType(address); keyType != Schnorr {
+		t.Errorf("Expected a Schnorr address, got %s", keyType)
+	}
+	reordered, _ := MuSigAddress([]string{addresses[2], addresses[0], addresses[1]})
+	if reordered != address {
+		t.Errorf("Expected the address not to depend on the order of the keys, got %s and %s", address, reordered)
+	}
+	tests := [][]string{
+		addresses[:1],
+		{addresses[0], addresses[0]},
+		{addresses[0], makeTestWallet().Address},
+	}
+	for _, test := range tests {
+		if _, err := MuSigAddress(test); err != ErrInvalidMuSig {
+			t.Errorf("Expected %v for %v, got %v", ErrInvalidMuSig, test, err)
+		}
+	}
+	if _, err := wallets[0].MuSigNonce(wallets[1].Address); err != ErrUnknownAddress {
+		t.Errorf("Expected %v, got %v", ErrUnknownAddress, err)
+	}
+}
+
+func TestMuSigSign(t *testing.T) {
+	files = fakeLayer{fakeHasWalletFile: func() bool { return false }}
+	// run a few times, so that both parities of the aggregate key and nonce come up
+	for i := 0; i < 8; i++ {
+		wallets, addresses := makeMuSigWallets(t, 3)
+		address, _ := MuSigAddress(addresses)
+		publicKey, _ := AddressPublicKey(address)
+		nonces := muSigNonces(t, wallets)
+		partialSignatures := map[string]string{}
+		for _, w := range wallets {
+			partial, err := w.MuSigSign(testPayload, w.Address, addresses, nonces)
+			if err != nil {
+				t.Fatal(err)
+			}
+			partialSignatures[w.Address] = partial
+		}
+		signature, err := AggregateSignatures(testPayload, addresses, nonces, partialSignatures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(signature, testPayload, publicKey) {
+			t.Fatal("Expected the aggregate signature to verify by the aggregate key")
+		}
+		if _, err := wallets[0].MuSigSign(testPayload, wallets[0].Address, addresses, nonces); err != ErrUnknownNonce {
+			t.Errorf("Expected a used nonce to be rejected, got %v", err)
+		}
+		partial, _ := hex.DecodeString(partialSignatures[addresses[1]])
+		partial[0] ^= 1
+		partialSignatures[addresses[1]] = hex.EncodeToString(partial)
+		if _, err := AggregateSignatures(testPayload, addresses, nonces, partialSignatures); !errors.Is(err, ErrInvalidPartialSignature) {
+			t.Errorf("Expected %v, got %v", ErrInvalidPartialSignature, err)
+		}
+	}
+	t.Run("Nonces should be of every signer.", func(t *testing.T) {
+		wallets, addresses := makeMuSigWallets(t, 2)
+		nonces := muSigNonces(t, wallets[:1])
+		if _, err := wallets[0].MuSigSign(testPayload, wallets[0].Address, addresses, nonces); !errors.Is(err, ErrInvalidNonce) {
+			t.Errorf("Expected %v, got %v", ErrInvalidNonce, err)
+		}
+	})
+}
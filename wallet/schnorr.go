@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// schnorrKeySize is the size of x-only public keys, signatures are twice as long.
+const schnorrKeySize int = 32
+
+// ErrInvalidSchnorrKey returns ERROR if an x-only public key is not the x coordinate of a point of secp256k1.
+var ErrInvalidSchnorrKey = errors.New("invalid schnorr public key")
+
+// schnorrPublicKey is an x-only public key, the point of secp256k1 of that x with an even y.
+type schnorrPublicKey []byte
+
+// schnorrKey is a secp256k1 private key that signs BIP340 Schnorr signatures.
+type schnorrKey struct {
+	*btcec.PrivateKey
+}
+
+// Public returns the x-only public key of k.
+func (k *schnorrKey) Public() crypto.PublicKey {
+	return schnorrPublicKey(schnorr.SerializePubKey(k.PubKey()))
+}
+
+// Sign signs digest with BIP340, taking the auxiliary randomness from random.
+func (k *schnorrKey) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, err := io.ReadFull(random, aux); err != nil {
+		return nil, err
+	}
+	return schnorrSign(k.PrivateKey, digest, aux)
+}
+
+// taggedHash returns SHA256(SHA256(tag) || SHA256(tag) || data...), the domain-separated hash of BIP340.
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// liftX returns the point of secp256k1 of the x-only key x, the one with an even y.
+func liftX(x []byte) (*btcec.JacobianPoint, error) {
+	key, err := schnorr.ParsePubKey(x)
+	if err != nil {
+		return nil, ErrInvalidSchnorrKey
+	}
+	point := new(btcec.JacobianPoint)
+	key.AsJacobian(point)
+	return point, nil
+}
+
+// schnorrSign returns the BIP340 signature of the 32-byte message by privateKey, with the auxiliary randomness aux.
+func schnorrSign(privateKey *btcec.PrivateKey, message, aux []byte) ([]byte, error) {
+	var auxData [32]byte
+	copy(auxData[:], aux)
+	signature, err := schnorr.Sign(privateKey, message, schnorr.CustomNonce(auxData))
+	if err != nil {
+		return nil, err
+	}
+	return signature.Serialize(), nil
+}
+
+// schnorrChallenge returns the challenge e of BIP340 for the nonce point of x rx and the key of x px.
+func schnorrChallenge(rx, px, message []byte) *btcec.ModNScalar {
+	return hashScalar(taggedHash("BIP0340/challenge", rx, px, message))
+}
+
+// schnorrVerify verifies the BIP340 signature of message by the x-only publicKey.
+func schnorrVerify(publicKey, message, signature []byte) bool {
+	key, err := schnorr.ParsePubKey(publicKey)
+	if err != nil {
+		return false
+	}
+	parsed, err := schnorr.ParseSignature(signature)
+	if err != nil {
+		return false
+	}
+	return parsed.Verify(message, key)
+}
+
+// randomScalar returns a uniformly random non-zero scalar of secp256k1.
+func randomScalar() (*btcec.ModNScalar, error) {
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &privateKey.Key, nil
+}
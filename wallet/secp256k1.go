@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"crypto"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/josh3021/nomadcoin/utils"
+)
+
+// secp256k1Key is a secp256k1 private key that signs ECDSA signatures, r||s.
+type secp256k1Key struct {
+	*btcec.PrivateKey
+}
+
+// Public returns the public key of k.
+func (k *secp256k1Key) Public() crypto.PublicKey {
+	return k.PubKey()
+}
+
+// Sign signs digest with a deterministic RFC6979 nonce, so random is not read.
+func (k *secp256k1Key) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	// compact signatures are the recovery code followed by r||s
+	return ecdsa.SignCompact(k.PrivateKey, digest, true)[1:], nil
+}
+
+// secp256k1Verify verifies the r||s signature of payload by publicKey.
+func secp256k1Verify(publicKey *btcec.PublicKey, payload, signature []byte) bool {
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(signature[:utils.BigIntSize]) || s.SetByteSlice(signature[utils.BigIntSize:]) || r.IsZero() || s.IsZero() {
+		return false
+	}
+	return ecdsa.NewSignature(&r, &s).Verify(payload, publicKey)
+}
+
+// secp256k1FromBytes returns the secp256k1 private key of a 32-byte scalar, which has to be in [1, n-1].
+func secp256k1FromBytes(keyBytes []byte) (*btcec.PrivateKey, error) {
+	var d btcec.ModNScalar
+	if overflow := d.SetByteSlice(keyBytes); overflow || d.IsZero() {
+		return nil, ErrInvalidKey
+	}
+	return btcec.PrivKeyFromScalar(&d), nil
+}
+
+// isInfinity reports whether p is the point at infinity.
+func isInfinity(p *btcec.JacobianPoint) bool {
+	return (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero()
+}
+
+// addPoints returns p1+p2 in affine coordinates.
+func addPoints(p1, p2 *btcec.JacobianPoint) *btcec.JacobianPoint {
+	sum := new(btcec.JacobianPoint)
+	btcec.AddNonConst(p1, p2, sum)
+	sum.ToAffine()
+	return sum
+}
+
+// mulPoint returns k·p in affine coordinates.
+func mulPoint(k *btcec.ModNScalar, p *btcec.JacobianPoint) *btcec.JacobianPoint {
+	product := new(btcec.JacobianPoint)
+	btcec.ScalarMultNonConst(k, p, product)
+	product.ToAffine()
+	return product
+}
+
+// mulBase returns k·G in affine coordinates.
+func mulBase(k *btcec.ModNScalar) *btcec.JacobianPoint {
+	product := new(btcec.JacobianPoint)
+	btcec.ScalarBaseMultNonConst(k, product)
+	product.ToAffine()
+	return product
+}
+
+// negatePoint negates the affine point p in place.
+func negatePoint(p *btcec.JacobianPoint) {
+	p.Y.Negate(1).Normalize()
+}
+
+// xBytes returns the x coordinate of the affine point p.
+func xBytes(p *btcec.JacobianPoint) []byte {
+	x := p.X.Bytes()
+	return x[:]
+}
+
+// hashScalar returns hash, a 32-byte hash, as a scalar mod n.
+func hashScalar(hash []byte) *btcec.ModNScalar {
+	s := new(btcec.ModNScalar)
+	s.SetByteSlice(hash)
+	return s
+}
@@ -1,122 +1,641 @@
 package wallet
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
+	"math/big"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/josh3021/nomadcoin/utils"
 )
 
 type fileLayer interface {
-	hasWalletFile() bool
+	hasWalletFile(name string) bool
 	writeFile(name string, data []byte, perm fs.FileMode) error
 	readFile(name string) ([]byte, error)
+	readDir(name string) ([]string, error)
 }
 
 type layer struct{}
 
-func (layer) hasWalletFile() bool {
-	_, err := os.Stat(walletFilename)
+func (layer) hasWalletFile(name string) bool {
+	_, err := os.Stat(name)
 	return !os.IsNotExist(err)
 }
 
+// writeFile writes data to name.tmp, syncs it and renames it over name, so that a crash
+// leaves either the old file or the new one, never a truncated wallet.
 func (layer) writeFile(name string, data []byte, perm fs.FileMode) error {
-	return os.WriteFile(name, data, perm)
+	dirName := filepath.Dir(name)
+	if err := os.MkdirAll(dirName, 0700); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// the rename is only durable once the directory is synced
+	dirFile, err := os.Open(dirName)
+	if err != nil {
+		return err
+	}
+	defer dirFile.Close()
+	return dirFile.Sync()
 }
 
 func (layer) readFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
+func (layer) readDir(name string) ([]string, error) {
+	entries, err := os.ReadDir(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
 var files fileLayer = layer{}
 
 type wallet struct {
-	privateKey *ecdsa.PrivateKey
+	privateKey crypto.Signer
 	Address    string
+	hd         *hdState
+	watch      *watchState
+	keys       map[string]crypto.Signer
+	encryption *encryption
+	labels     map[string]*Label
+	nonces     map[string]*secretNonce
+	filename   string
+	m          sync.Mutex
 }
 
-const walletFilename string = "nomadcoin.wallet"
+// hdState is what an HD wallet file holds: the mnemonic, the key type and how many addresses were handed out.
+type hdState struct {
+	Mnemonic    string  `json:"mnemonic"`
+	KeyType     KeyType `json:"keyType,omitempty"`
+	NextReceive int     `json:"nextReceive"`
+	NextChange  int     `json:"nextChange"`
+	account     *extendedKey
+}
+
+const (
+	walletFilename string = "nomadcoin.wallet"
+	mnemonicWords  int    = 12
+)
+
+var (
+	// ErrUnknownAddress returns ERROR if the wallet does not hold the key of an address.
+	ErrUnknownAddress = errors.New("address does not belong to the wallet")
+	// ErrBackupExists returns ERROR if the backup of the wallet file would overwrite an older backup.
+	ErrBackupExists = errors.New("wallet backup already exists, try again in a second")
+)
 
 var w *wallet
 
+// dir is the directory of the node wallet file and of the named wallets, the working directory if empty.
+var dir string
+
+// SetDir keeps the node wallet file and the named wallets in path instead of the working directory.
+func SetDir(path string) {
+	dir = path
+}
+
+// inDir returns the path of name in the wallet directory.
+func inDir(name string) string {
+	return filepath.Join(dir, name)
+}
+
 func createPrivateKey() *ecdsa.PrivateKey {
 	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	utils.HandleErr(err)
 	return privateKey
 }
 
-func persistPrivateKey(privateKeyBytes []byte) {
-	err := files.writeFile(walletFilename, privateKeyBytes, 0644)
+func persistPrivateKey(filename string, privateKeyBytes []byte) {
+	err := files.writeFile(filename, privateKeyBytes, 0600)
 	utils.HandleErr(err)
 }
 
-func readWalletFile() []byte {
-	walletBytes, err := files.readFile(walletFilename)
+func readWalletFile(filename string) []byte {
+	walletBytes, err := files.readFile(filename)
 	utils.HandleErr(err)
 	return walletBytes
 }
 
-func marshalWalletBytes(privateKey *ecdsa.PrivateKey) []byte {
-	privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey)
+// marshalWalletBytes returns the wallet file bytes of a single-key wallet, DER for P256 keys.
+func marshalWalletBytes(privateKey crypto.Signer) []byte {
+	if privateKeyType(privateKey) != P256 {
+		return marshalPrivateKey(privateKey)
+	}
+	privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey.(*ecdsa.PrivateKey))
 	utils.HandleErr(err)
 	return privateKeyBytes
 }
 
-func parseWalletBytes(bytes []byte) *ecdsa.PrivateKey {
-	privateKey, err := x509.ParseECPrivateKey(bytes)
+func parseAddress(privateKey crypto.Signer) string {
+	address, err := AddressFromPublicKey(legacyAddress(privateKey))
 	utils.HandleErr(err)
+	return address
+}
+
+// legacyAddress returns the hex-encoded public key of privateKey, which was the address of P256 keys before checksummed addresses.
+func legacyAddress(privateKey crypto.Signer) string {
+	return encodePublicKey(privateKey.Public())
+}
+
+// unpaddedPublicKey returns the public key of privateKey as it was encoded before big ints had a fixed width.
+func unpaddedPublicKey(privateKey *ecdsa.PrivateKey) string {
+	return fmt.Sprintf("%x", append(privateKey.X.Bytes(), privateKey.Y.Bytes()...))
+}
+
+// keyAddresses returns the addresses of the public key of privateKey.
+func keyAddresses(privateKey crypto.Signer) []string {
+	return publicKeyAddresses(privateKey.Public())
+}
+
+// addKey remembers privateKey under all its addresses, and returns its address.
+func (w *wallet) addKey(privateKey crypto.Signer) string {
+	addresses := keyAddresses(privateKey)
+	for _, address := range addresses {
+		w.keys[address] = privateKey
+	}
+	return addresses[0]
+}
+
+// newKeyWallet returns a single-key wallet, the format used before HD wallets.
+func newKeyWallet(privateKey crypto.Signer) *wallet {
+	wallet := &wallet{privateKey: privateKey, keys: make(map[string]crypto.Signer)}
+	wallet.Address = wallet.addKey(privateKey)
+	return wallet
+}
+
+// newHDWallet returns the HD wallet of state, deriving every address it handed out.
+func newHDWallet(state *hdState) (*wallet, error) {
+	seed, err := seedFromMnemonic(state.Mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := ParseKeyType(string(state.KeyType))
+	if err != nil {
+		return nil, err
+	}
+	// P256 wallet files stay as they were before key types
+	state.KeyType = keyType
+	if keyType == P256 {
+		state.KeyType = ""
+	}
+	state.account = accountKey(seed, keyType)
+	if state.NextReceive < 1 {
+		state.NextReceive = 1
+	}
+	wallet := &wallet{hd: state, keys: make(map[string]crypto.Signer)}
+	wallet.privateKey = wallet.deriveKey(receiveChain, 0)
+	wallet.Address = parseAddress(wallet.privateKey)
+	for index := 1; index < state.NextReceive; index++ {
+		wallet.deriveKey(receiveChain, index)
+	}
+	for index := 0; index < state.NextChange; index++ {
+		wallet.deriveKey(changeChain, index)
+	}
+	return wallet, nil
+}
+
+// decodeWallet returns the wallet of plaintext wallet file bytes.
+func decodeWallet(walletBytes []byte) (*wallet, error) {
+	if len(walletBytes) > 0 && walletBytes[0] == '{' {
+		var watch watchState
+		if err := json.Unmarshal(walletBytes, &watch); err != nil {
+			return nil, err
+		}
+		if watch.WatchOnly {
+			return newWatchWallet(&watch)
+		}
+		var key keyState
+		if err := json.Unmarshal(walletBytes, &key); err != nil {
+			return nil, err
+		}
+		if key.PrivateKey != "" {
+			privateKey, err := key.privateKey()
+			if err != nil {
+				return nil, err
+			}
+			return newKeyWallet(privateKey), nil
+		}
+		var state hdState
+		if err := json.Unmarshal(walletBytes, &state); err != nil {
+			return nil, err
+		}
+		return newHDWallet(&state)
+	}
+	privateKey, err := x509.ParseECPrivateKey(walletBytes)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyWallet(privateKey), nil
+}
+
+func restoreWallet(walletBytes []byte) *wallet {
+	if isEncryptedWallet(walletBytes) {
+		wallet, err := newLockedWallet(walletBytes)
+		utils.HandleErr(err)
+		return wallet
+	}
+	wallet, err := decodeWallet(walletBytes)
+	utils.HandleErr(err)
+	return wallet
+}
+
+// encodeWallet returns the plaintext wallet file bytes of wallet.
+func encodeWallet(wallet *wallet) []byte {
+	if wallet.watch != nil {
+		return utils.ToJSON(wallet.watch)
+	}
+	if wallet.hd == nil {
+		return marshalWalletBytes(wallet.privateKey)
+	}
+	return utils.ToJSON(wallet.hd)
+}
+
+func persistWallet(wallet *wallet) {
+	if wallet.encryption == nil {
+		persistPrivateKey(wallet.file(), encodeWallet(wallet))
+		return
+	}
+	if wallet.isLocked() {
+		return
+	}
+	persistPrivateKey(wallet.file(), wallet.encryption.seal(wallet.Address, wallet.addresses(), encodeWallet(wallet)))
+}
+
+// file returns the name of the wallet file, the node wallet file by default.
+func (w *wallet) file() string {
+	if w.filename == "" {
+		return inDir(walletFilename)
+	}
+	return w.filename
+}
+
+// deriveKey derives the key at index of chain and remembers its address.
+func (w *wallet) deriveKey(chain uint32, index int) crypto.Signer {
+	privateKey := w.hd.account.derive(chain, uint32(index)).privateKey()
+	w.addKey(privateKey)
 	return privateKey
 }
 
-func parseAddress(privateKey *ecdsa.PrivateKey) string {
-	return utils.EncodeBigInts(privateKey.X, privateKey.Y)
+// Addresses returns every address of the wallet, followed by the legacy addresses of its keys.
+func (w *wallet) Addresses() []string {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return append([]string{}, w.encryption.file.Addresses...)
+	}
+	return w.addresses()
+}
+
+func (w *wallet) addresses() []string {
+	if w.watch != nil {
+		return w.watch.addresses()
+	}
+	privateKeys := []crypto.Signer{w.privateKey}
+	if w.hd != nil {
+		privateKeys = nil
+		for index := 0; index < w.hd.NextReceive; index++ {
+			privateKeys = append(privateKeys, w.hd.account.derive(receiveChain, uint32(index)).privateKey())
+		}
+		for index := 0; index < w.hd.NextChange; index++ {
+			privateKeys = append(privateKeys, w.hd.account.derive(changeChain, uint32(index)).privateKey())
+		}
+	}
+	var addresses, legacyAddresses []string
+	for _, privateKey := range privateKeys {
+		all := keyAddresses(privateKey)
+		addresses = append(addresses, all[0])
+		legacyAddresses = append(legacyAddresses, all[1:]...)
+	}
+	return append(addresses, legacyAddresses...)
+}
+
+// HasAddress reports whether the wallet holds the key of address, which watch-only wallets never do.
+func (w *wallet) HasAddress(address string) bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		for _, owned := range w.encryption.file.Addresses {
+			if owned == address {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := w.keys[address]
+	return ok
+}
+
+// NewAddress returns a fresh receiving address.
+func (w *wallet) NewAddress() (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	if w.watch != nil {
+		if len(w.watch.XPubs) == 0 {
+			return "", ErrWatchOnly
+		}
+		watched := w.watch.XPubs[0]
+		address := watched.addresses(receiveChain, watched.NextReceive)[0]
+		watched.NextReceive++
+		persistWallet(w)
+		return address, nil
+	}
+	if w.hd == nil {
+		return w.Address, nil
+	}
+	address := parseAddress(w.deriveKey(receiveChain, w.hd.NextReceive))
+	w.hd.NextReceive++
+	persistWallet(w)
+	return address, nil
+}
+
+// ChangeAddress returns the next unused change address, without handing it out.
+func (w *wallet) ChangeAddress() string {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.hd == nil {
+		// single-key and locked wallets
+		return w.Address
+	}
+	return parseAddress(w.deriveKey(changeChain, w.hd.NextChange))
+}
+
+// UseChangeAddress hands out the address returned by ChangeAddress.
+func (w *wallet) UseChangeAddress() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.hd == nil {
+		return
+	}
+	w.hd.NextChange++
+	persistWallet(w)
+}
+
+// Mnemonic returns the backup phrase of the wallet, or "" for single-key wallets.
+func (w *wallet) Mnemonic() (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	if w.watch != nil {
+		return "", ErrWatchOnly
+	}
+	if w.hd == nil {
+		return "", nil
+	}
+	return w.hd.Mnemonic, nil
+}
+
+// Scan hands out every address up to the last used one, stopping after GapLimit unused addresses per chain.
+func (w *wallet) Scan(used func(address string) bool) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return ErrWalletLocked
+	}
+	if w.watch != nil {
+		for _, watched := range w.watch.XPubs {
+			scanChains(used, []*int{&watched.NextReceive, &watched.NextChange}, watched.addresses)
+		}
+		persistWallet(w)
+		return nil
+	}
+	if w.hd == nil {
+		return nil
+	}
+	scanChains(used, []*int{&w.hd.NextReceive, &w.hd.NextChange}, func(chain uint32, index int) []string {
+		return keyAddresses(w.hd.account.derive(chain, uint32(index)).privateKey())
+	})
+	for index := 0; index < w.hd.NextReceive; index++ {
+		w.deriveKey(receiveChain, index)
+	}
+	for index := 0; index < w.hd.NextChange; index++ {
+		w.deriveKey(changeChain, index)
+	}
+	persistWallet(w)
+	return nil
+}
+
+// scanChains moves next of each chain past its last used address, stopping after GapLimit unused addresses.
+func scanChains(used func(address string) bool, next []*int, addressesAt func(chain uint32, index int) []string) {
+	for chain, nextIndex := range next {
+		for index, gap := 0, 0; gap < GapLimit; index++ {
+			if !usedAny(used, addressesAt(uint32(chain), index)) {
+				gap++
+				continue
+			}
+			gap = 0
+			if index >= *nextIndex {
+				*nextIndex = index + 1
+			}
+		}
+	}
+}
+
+func usedAny(used func(address string) bool, addresses []string) bool {
+	for _, address := range addresses {
+		if used(address) {
+			return true
+		}
+	}
+	return false
 }
 
 func Sign(payload string, wallet *wallet) string {
-	payloadBytes, err := hex.DecodeString(payload)
-	utils.HandleErr(err)
-	r, s, err := ecdsa.Sign(rand.Reader, wallet.privateKey, payloadBytes)
+	signature, err := SignFor(payload, wallet.Address, wallet)
 	utils.HandleErr(err)
-	// signature
-	return utils.EncodeBigInts(r, s)
+	return signature
 }
 
-// Verify verfies signature
-func Verify(signature, payload, address string) bool {
-	r, s, err := utils.RestoreBigInts(signature)
-	utils.HandleErr(err)
-	x, y, err := utils.RestoreBigInts(address)
-	utils.HandleErr(err)
-	publicKey := ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     x,
-		Y:     y,
+// SignFor signs payload with the key of address.
+func SignFor(payload, address string, wallet *wallet) (string, error) {
+	return wallet.SignFor(payload, address)
+}
+
+// SignFor signs payload with the key of address.
+func (w *wallet) SignFor(payload, address string) (string, error) {
+	w.m.Lock()
+	locked := w.isLocked()
+	privateKey, ok := w.keys[address]
+	w.m.Unlock()
+	if locked {
+		return "", ErrWalletLocked
+	}
+	if w.watch != nil {
+		return "", ErrWatchOnly
+	}
+	if !ok {
+		return "", ErrUnknownAddress
 	}
 	payloadBytes, err := hex.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	signature, err := signPayload(privateKey, payloadBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+// PublicKey returns the hex-encoded public key of address.
+func (w *wallet) PublicKey(address string) (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	privateKey, ok := w.keys[address]
+	if !ok {
+		return "", ErrUnknownAddress
+	}
+	// addresses from before big ints had a fixed width hash the unpadded public key
+	if ecdsaKey, ok := privateKey.(*ecdsa.PrivateKey); ok && privateKeyType(privateKey) == P256 {
+		if unpadded := unpaddedPublicKey(ecdsaKey); address == unpadded || address == mustAddress(unpadded) {
+			return unpadded, nil
+		}
+	}
+	return legacyAddress(privateKey), nil
+}
+
+func mustAddress(publicKey string) string {
+	address, err := AddressFromPublicKey(publicKey)
 	utils.HandleErr(err)
-	return ecdsa.Verify(&publicKey, payloadBytes, r, s)
+	return address
+}
+
+// Verify verfies signature of payload by the hex-encoded publicKey of any key type, and returns false for malformed input.
+// P256 signatures have to be of fixed width, so that a signature has one encoding.
+func Verify(signature, payload, publicKey string) bool {
+	return verify(signature, payload, publicKey, false)
+}
+
+// VerifyLegacy verifies like Verify, and also accepts the shorter P256 signatures from before big ints had a fixed width,
+// if any split of them verifies. It is for txs already on chain, as several splits can verify.
+func VerifyLegacy(signature, payload, publicKey string) bool {
+	return verify(signature, payload, publicKey, true)
+}
+
+func verify(signature, payload, publicKey string, legacy bool) bool {
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil || len(signatureBytes) == 0 {
+		return false
+	}
+	parsed, err := parsePublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	payloadBytes, err := hex.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	if publicKeyType(parsed) != P256 {
+		return verifyTagged(parsed, payloadBytes, signatureBytes)
+	}
+	if len(signatureBytes) > 2*utils.BigIntSize {
+		return false
+	}
+	key := parsed.(*ecdsa.PublicKey)
+	if len(signatureBytes) == 2*utils.BigIntSize {
+		r := new(big.Int).SetBytes(signatureBytes[:utils.BigIntSize])
+		s := new(big.Int).SetBytes(signatureBytes[utils.BigIntSize:])
+		return ecdsa.Verify(key, payloadBytes, r, s)
+	}
+	return legacy && splitBigInts(signatureBytes, func(r, s *big.Int) bool {
+		return ecdsa.Verify(key, payloadBytes, r, s)
+	})
+}
+
+// backupFilename returns the name of the backup of the wallet file taken at t.
+func backupFilename(t time.Time) string {
+	return fmt.Sprintf("%s.%d.bak", walletFilename, t.Unix())
+}
+
+// Recover replaces the wallet with the HD wallet of mnemonic, keeping a backup of the old wallet file
+// next to it, named after the time of the recovery so that older backups are never overwritten.
+// With a passphrase the new wallet file is encrypted and the wallet stays unlocked for DefaultUnlockTimeout.
+func Recover(mnemonic, passphrase string) (*wallet, error) {
+	recovered, err := newHDWallet(&hdState{Mnemonic: mnemonic, KeyType: defaultKeyType})
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != "" {
+		if recovered.encryption, err = newEncryption(passphrase); err != nil {
+			return nil, err
+		}
+		recovered.lockAfter(0)
+	}
+	if files.hasWalletFile(inDir(walletFilename)) {
+		backup := inDir(backupFilename(time.Now()))
+		if files.hasWalletFile(backup) {
+			return nil, ErrBackupExists
+		}
+		utils.HandleErr(files.writeFile(backup, readWalletFile(inDir(walletFilename)), 0600))
+	}
+	persistWallet(recovered)
+	w = recovered
+	return w, nil
 }
 
 // Wallet returns wallet (Initialize wallet if it does not initialized).
 func Wallet() *wallet {
 	if w == nil {
-		w = &wallet{}
-		if files.hasWalletFile() {
-			walletBytes := readWalletFile()
-			privateKey := parseWalletBytes(walletBytes)
-			w.privateKey = privateKey
+		if files.hasWalletFile(inDir(walletFilename)) {
+			w = restoreWallet(readWalletFile(inDir(walletFilename)))
 		} else {
-			privateKey := createPrivateKey()
-			privateKeyBytes := marshalWalletBytes(privateKey)
-			persistPrivateKey(privateKeyBytes)
-			w.privateKey = privateKey
+			mnemonic, err := NewMnemonic(mnemonicWords)
+			utils.HandleErr(err)
+			w, err = newHDWallet(&hdState{Mnemonic: mnemonic, KeyType: defaultKeyType})
+			utils.HandleErr(err)
+			persistWallet(w)
 		}
-		w.Address = parseAddress(w.privateKey)
 	}
 	return w
 }
@@ -1,11 +1,16 @@
 package wallet
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/hex"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/josh3021/nomadcoin/utils"
 )
@@ -20,7 +25,7 @@ type fakeLayer struct {
 	fakeHasWalletFile func() bool
 }
 
-func (f fakeLayer) hasWalletFile() bool {
+func (f fakeLayer) hasWalletFile(name string) bool {
 	return f.fakeHasWalletFile()
 }
 
@@ -29,18 +34,19 @@ func (fakeLayer) writeFile(name string, data []byte, perm fs.FileMode) error {
 }
 
 func (fakeLayer) readFile(name string) ([]byte, error) {
-	return x509.MarshalECPrivateKey(makeTestWallet().privateKey)
+	return marshalWalletBytes(makeTestWallet().privateKey), nil
+}
+
+func (fakeLayer) readDir(name string) ([]string, error) {
+	return nil, nil
 }
 
 func makeTestWallet() *wallet {
-	w := &wallet{}
 	b, err := hex.DecodeString(testKey)
 	utils.HandleErr(err)
 	pk, err := x509.ParseECPrivateKey(b)
 	utils.HandleErr(err)
-	w.privateKey = pk
-	w.Address = parseAddress(w.privateKey)
-	return w
+	return newKeyWallet(pk)
 }
 
 func TestSign(t *testing.T) {
@@ -55,19 +61,19 @@ func TestSign(t *testing.T) {
 func TestVerify(t *testing.T) {
 	w := makeTestWallet()
 	t.Run("Verify should have correct payload.", func(t *testing.T) {
-		v := Verify(testSignature, testPayload, w.Address)
+		v := Verify(testSignature, testPayload, legacyAddress(w.privateKey))
 		if !v {
 			t.Error("Verify should have correct payload.")
 		}
 	})
 	t.Run("Verify should return error if signature is invalid.", func(t *testing.T) {
-		v := Verify("b4a402235d90ecbf90c4c41c13614261dc4c0b311bbe9499537f67b9a22cfbae6edeb0da3e58cb77596f02623ab2eada22d272cc347c9514a029c97631a449f2", testPayload, w.Address)
+		v := Verify("b4a402235d90ecbf90c4c41c13614261dc4c0b311bbe9499537f67b9a22cfbae6edeb0da3e58cb77596f02623ab2eada22d272cc347c9514a029c97631a449f2", testPayload, legacyAddress(w.privateKey))
 		if v {
 			t.Error("Expected: false, got: true")
 		}
 	})
 	t.Run("Verify should return error if payload is invalid", func(t *testing.T) {
-		v := Verify(testSignature, "765cd5fbc8bdb14616f299bb4e7952065cd5d153d3511e680d5ddd7cc74e1aae", w.Address)
+		v := Verify(testSignature, "765cd5fbc8bdb14616f299bb4e7952065cd5d153d3511e680d5ddd7cc74e1aae", legacyAddress(w.privateKey))
 		if v {
 			t.Error("Expected: false, got: true")
 		}
@@ -100,6 +106,63 @@ func TestWallet(t *testing.T) {
 	})
 }
 
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wallets", walletFilename)
+	if err := (layer{}).writeFile(name, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// a temporary file left by a crash should not get in the way
+	if err := os.WriteFile(name+".tmp", []byte("half written"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (layer{}).writeFile(name, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != "new" {
+		t.Errorf("Expected new, got %s and %v", data, err)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected -rw-------, got %v and %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(name + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be renamed, got %v", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	defer func() { w = nil }()
+	layer := memoryLayer{}
+	files = layer
+	old := marshalWalletBytes(makeTestWallet().privateKey)
+	layer[walletFilename] = old
+	t.Run("Recovering should back up the old wallet file.", func(t *testing.T) {
+		if _, err := Recover(testMnemonic, ""); err != nil {
+			t.Fatal(err)
+		}
+		var backups []string
+		for name, data := range layer {
+			if strings.HasSuffix(name, ".bak") && bytes.Equal(data, old) {
+				backups = append(backups, name)
+			}
+		}
+		if len(backups) != 1 {
+			t.Errorf("Expected one backup of the old wallet file, got %v", backups)
+		}
+	})
+	t.Run("Recovering should not overwrite a backup.", func(t *testing.T) {
+		now := time.Now()
+		for _, at := range []time.Time{now, now.Add(time.Second)} {
+			layer[backupFilename(at)] = []byte("older backup")
+		}
+		if _, err := Recover(testMnemonic, ""); err != ErrBackupExists {
+			t.Errorf("Expected %v, got %v", ErrBackupExists, err)
+		}
+		if backup := layer[backupFilename(now)]; string(backup) != "older backup" {
+			t.Errorf("Expected the older backup to be kept, got %s", backup)
+		}
+	})
+}
+
 // func TestParseWalletBytes(t *testing.T) {
 // 	b, err := hex.DecodeString(testKey)
 // 	utils.HandleErr(err)
@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"crypto"
+	"errors"
+)
+
+var (
+	// ErrWatchOnly returns ERROR if a watch-only wallet is asked for something that needs private keys.
+	ErrWatchOnly = errors.New("wallet is watch-only")
+	// ErrNotWatchOnly returns ERROR if addresses are imported into a wallet that holds keys.
+	ErrNotWatchOnly = errors.New("wallet is not watch-only")
+	// ErrNoXPub returns ERROR if a wallet has no extended public key to export.
+	ErrNoXPub = errors.New("wallet has no extended public key")
+)
+
+// watchState is what a watch-only wallet file holds: imported addresses and extended public keys, no private keys.
+type watchState struct {
+	WatchOnly bool           `json:"watchOnly"`
+	Addresses []string       `json:"addresses"`
+	XPubs     []*watchedXPub `json:"xpubs"`
+}
+
+// watchedXPub is an imported account extended public key and how many of its addresses were handed out.
+type watchedXPub struct {
+	XPub        string `json:"xpub"`
+	NextReceive int    `json:"nextReceive"`
+	NextChange  int    `json:"nextChange"`
+	key         *extendedPublicKey
+}
+
+// newWatchWallet returns the watch-only wallet of state.
+func newWatchWallet(state *watchState) (*wallet, error) {
+	state.WatchOnly = true
+	for _, watched := range state.XPubs {
+		key, err := parseXPub(watched.XPub)
+		if err != nil {
+			return nil, err
+		}
+		watched.key = key
+		if watched.NextReceive < 1 {
+			watched.NextReceive = 1
+		}
+	}
+	wallet := &wallet{watch: state, keys: make(map[string]crypto.Signer)}
+	wallet.Address = state.firstAddress()
+	return wallet, nil
+}
+
+func (s *watchState) firstAddress() string {
+	if len(s.Addresses) > 0 {
+		return s.Addresses[0]
+	}
+	if len(s.XPubs) > 0 {
+		return s.XPubs[0].addresses(receiveChain, 0)[0]
+	}
+	return ""
+}
+
+// addresses returns the imported addresses and the addresses handed out of each extended public key,
+// followed by the legacy addresses of the derived keys.
+func (s *watchState) addresses() []string {
+	addresses := append([]string{}, s.Addresses...)
+	var legacyAddresses []string
+	for _, watched := range s.XPubs {
+		next := map[uint32]int{receiveChain: watched.NextReceive, changeChain: watched.NextChange}
+		for _, chain := range []uint32{receiveChain, changeChain} {
+			for index := 0; index < next[chain]; index++ {
+				all := watched.addresses(chain, index)
+				addresses = append(addresses, all[0])
+				legacyAddresses = append(legacyAddresses, all[1:]...)
+			}
+		}
+	}
+	return append(addresses, legacyAddresses...)
+}
+
+// addresses returns the addresses of the public key at index of chain.
+func (x *watchedXPub) addresses(chain uint32, index int) []string {
+	chainKey, err := x.key.child(chain)
+	if err != nil {
+		return nil
+	}
+	key, err := chainKey.child(uint32(index))
+	if err != nil {
+		return nil
+	}
+	return publicKeyAddresses(key.publicKey())
+}
+
+// IsWatchOnly reports whether the wallet only watches addresses whose keys live elsewhere.
+func (w *wallet) IsWatchOnly() bool {
+	return w.watch != nil
+}
+
+// ImportAddress starts watching address.
+func (w *wallet) ImportAddress(address string) error {
+	if err := ValidateAddress(address); err != nil {
+		return err
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.checkImport(); err != nil {
+		return err
+	}
+	for _, watched := range w.watch.Addresses {
+		if watched == address {
+			return nil
+		}
+	}
+	w.watch.Addresses = append(w.watch.Addresses, address)
+	w.Address = w.watch.firstAddress()
+	persistWallet(w)
+	return nil
+}
+
+// ImportXPub starts watching the addresses of the account extended public key xpub.
+// Its addresses are handed out by NewAddress and found by Scan.
+func (w *wallet) ImportXPub(xpub string) error {
+	key, err := parseXPub(xpub)
+	if err != nil {
+		return err
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.checkImport(); err != nil {
+		return err
+	}
+	for _, watched := range w.watch.XPubs {
+		if watched.XPub == xpub {
+			return nil
+		}
+	}
+	w.watch.XPubs = append(w.watch.XPubs, &watchedXPub{XPub: xpub, NextReceive: 1, key: key})
+	w.Address = w.watch.firstAddress()
+	persistWallet(w)
+	return nil
+}
+
+func (w *wallet) checkImport() error {
+	if w.watch == nil {
+		return ErrNotWatchOnly
+	}
+	if w.isLocked() {
+		return ErrWalletLocked
+	}
+	return nil
+}
+
+// ExtendedPublicKey returns the account extended public key of a P256 HD wallet, to watch it from elsewhere.
+func (w *wallet) ExtendedPublicKey() (string, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.isLocked() {
+		return "", ErrWalletLocked
+	}
+	if w.hd == nil || w.hd.account.keyType != P256 {
+		return "", ErrNoXPub
+	}
+	return w.hd.account.public(3, hdAccount+HardenedOffset).String(), nil
+}
@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXPub(t *testing.T) {
+	seed, _ := seedFromMnemonic(testMnemonic)
+	account := accountKey(seed, P256)
+	xpub := account.public(3, hdAccount+HardenedOffset)
+	t.Run("Public children should match private children.", func(t *testing.T) {
+		for _, path := range [][]uint32{{receiveChain, 0}, {receiveChain, 7}, {changeChain, 3}} {
+			chainKey, _ := xpub.child(path[0])
+			key, err := chainKey.child(path[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := legacyAddress(account.derive(path...).privateKey())
+			if encodePublicKey(key.publicKey()) != expected {
+				t.Errorf("Expected the public key of %v", path)
+			}
+		}
+		if _, err := xpub.child(HardenedOffset); err != ErrHardenedDerivation {
+			t.Errorf("Expected %v, got %v", ErrHardenedDerivation, err)
+		}
+	})
+	t.Run("Extended public key should round trip.", func(t *testing.T) {
+		parsed, err := parseXPub(xpub.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parsed, xpub) {
+			t.Errorf("Expected %v, got %v", xpub, parsed)
+		}
+		if !strings.HasPrefix(xpub.String(), "xpub") {
+			t.Errorf("Expected a mainnet xpub, got %s", xpub)
+		}
+	})
+	t.Run("Extended public key should be validated.", func(t *testing.T) {
+		encoded := xpub.String()
+		typo := encoded[:20] + "z" + encoded[21:]
+		if typo == encoded {
+			typo = encoded[:20] + "y" + encoded[21:]
+		}
+		SetNetwork(TestNet.Name)
+		testnet := xpub.String()
+		SetNetwork(MainNet.Name)
+		tests := []struct {
+			xpub string
+			err  error
+		}{
+			{typo, ErrChecksum},
+			{testnet, ErrWrongNetwork},
+			{"xpub", ErrInvalidXPub},
+		}
+		for _, test := range tests {
+			if _, err := parseXPub(test.xpub); err != test.err {
+				t.Errorf("Expected %v for %s, got %v", test.err, test.xpub, err)
+			}
+		}
+	})
+}
+
+func TestWatchOnlyWallet(t *testing.T) {
+	layer := memoryLayer{}
+	files = layer
+	hd, _ := newHDWallet(&hdState{Mnemonic: testMnemonic})
+	hd.filename = "hd.wallet"
+	xpub, err := hd.ExtendedPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch, _ := newWatchWallet(&watchState{})
+	watch.filename = "watch.wallet"
+	t.Run("Imported xpub should watch the addresses of the wallet.", func(t *testing.T) {
+		if err := watch.ImportXPub(xpub); err != nil {
+			t.Fatal(err)
+		}
+		if watch.Address != hd.Address {
+			t.Errorf("Expected %s, got %s", hd.Address, watch.Address)
+		}
+		if address := newAddress(t, watch); address != newAddress(t, hd) {
+			t.Errorf("Expected the next receiving address of the wallet, got %s", address)
+		}
+		used := map[string]bool{hd.ChangeAddress(): true}
+		watch.Scan(func(address string) bool { return used[address] })
+		hd.UseChangeAddress()
+		if !reflect.DeepEqual(watch.Addresses(), hd.Addresses()) {
+			t.Errorf("Expected %v, got %v", hd.Addresses(), watch.Addresses())
+		}
+	})
+	t.Run("Imported addresses should be watched.", func(t *testing.T) {
+		other := makeTestWallet()
+		if err := watch.ImportAddress(other.Address); err != nil {
+			t.Fatal(err)
+		}
+		if err := watch.ImportAddress("winter"); err != ErrInvalidAddress {
+			t.Errorf("Expected %v, got %v", ErrInvalidAddress, err)
+		}
+		if err := hd.ImportAddress(other.Address); err != ErrNotWatchOnly {
+			t.Errorf("Expected %v, got %v", ErrNotWatchOnly, err)
+		}
+		if addresses := watch.Addresses(); addresses[0] != other.Address || watch.HasAddress(other.Address) {
+			t.Errorf("Expected %s to be watched without its key, got %v", other.Address, addresses)
+		}
+	})
+	t.Run("Watch-only wallet should not sign.", func(t *testing.T) {
+		if _, err := SignFor(testPayload, watch.Address, watch); err != ErrWatchOnly {
+			t.Errorf("Expected %v, got %v", ErrWatchOnly, err)
+		}
+		if _, err := watch.Mnemonic(); err != ErrWatchOnly {
+			t.Errorf("Expected %v, got %v", ErrWatchOnly, err)
+		}
+		if _, err := watch.ExtendedPublicKey(); err != ErrNoXPub {
+			t.Errorf("Expected %v, got %v", ErrNoXPub, err)
+		}
+	})
+	t.Run("Watch-only wallet should be restored from its file.", func(t *testing.T) {
+		restored := restoreWallet(layer["watch.wallet"])
+		if !restored.IsWatchOnly() || !reflect.DeepEqual(restored.Addresses(), watch.Addresses()) {
+			t.Errorf("Expected %v, got %v", watch.Addresses(), restored.Addresses())
+		}
+	})
+	t.Run("Encrypted watch-only wallet should stay watch-only once unlocked.", func(t *testing.T) {
+		if err := watch.Encrypt(testPassphrase); err != nil {
+			t.Fatal(err)
+		}
+		restored := restoreWallet(layer["watch.wallet"])
+		if err := restored.Unlock(testPassphrase, 0); err != nil {
+			t.Fatal(err)
+		}
+		defer restored.Lock()
+		if !restored.IsWatchOnly() || !reflect.DeepEqual(restored.Addresses(), watch.Addresses()) {
+			t.Errorf("Expected %v, got %v", watch.Addresses(), restored.Addresses())
+		}
+	})
+}
+
+func TestLabels(t *testing.T) {
+	layer := memoryLayer{}
+	files = layer
+	w := makeTestWallet()
+	w.filename = "labels.wallet"
+	if err := w.SetLabel(w.Address, "savings", "do not spend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetLabel("winter", "typo", ""); err != ErrInvalidAddress {
+		t.Errorf("Expected %v, got %v", ErrInvalidAddress, err)
+	}
+	restored := makeTestWallet()
+	restored.filename = "labels.wallet"
+	labels, err := restored.Labels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Label{{Address: w.Address, Label: "savings", Note: "do not spend"}}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected %v, got %v", expected, labels)
+	}
+	w.SetLabel(w.Address, "", "")
+	if labels, _ := w.Labels(); len(labels) != 0 {
+		t.Errorf("Expected no labels, got %v", labels)
+	}
+}